@@ -2,9 +2,6 @@ package acme
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/pem"
 	"strings"
 	"time"
@@ -15,7 +12,6 @@ import (
 	"github.com/hydeant/pritunl-zero/certificate"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/errortypes"
-	"github.com/hydeant/pritunl-zero/settings"
 	"golang.org/x/crypto/acme"
 )
 
@@ -34,50 +30,33 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 		return
 	}
 
-	var acctKey *rsa.PrivateKey
+	acctKey, err := getAccountKey(db, cert)
+	if err != nil {
+		return
+	}
 
-	if cert.AcmeAccount != "" {
-		acctBlock, _ := pem.Decode([]byte(cert.AcmeAccount))
-		if acctBlock == nil {
-			err = &errortypes.ParseError{
-				errors.New("acme: Failed to decode account key"),
-			}
-			return
-		}
+	acct := &acme.Account{}
 
-		acctKey, err = x509.ParsePKCS1PrivateKey(acctBlock.Bytes)
-		if err != nil {
-			err = &errortypes.ParseError{
-				errors.Wrap(err, "acme: Failed to parse account key"),
-			}
-			return
-		}
-	} else {
-		acctKey, err = rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			err = &errortypes.ReadError{
-				errors.Wrap(err, "acme: Failed to generate account key"),
-			}
+	if cert.AcmeEmail != "" {
+		acct.Contact = []string{"mailto:" + cert.AcmeEmail}
+	}
+
+	if cert.AcmeEabKid != "" {
+		eabKey, e := cert.EabKey()
+		if e != nil {
+			err = e
 			return
 		}
 
-		acctBlock := &pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(acctKey),
-		}
-
-		cert.AcmeAccount = string(pem.EncodeToMemory(acctBlock))
-		err = cert.CommitFields(db, set.NewSet("acme_account"))
-		if err != nil {
-			return
+		acct.ExternalAccountBinding = &acme.ExternalAccountBinding{
+			KID: cert.AcmeEabKid,
+			Key: eabKey,
 		}
 	}
 
-	acct := &acme.Account{}
-
-	client := &acme.Client{
-		DirectoryURL: AcmeDirectory,
-		Key:          acctKey,
+	client, err := newClient(cert, acctKey)
+	if err != nil {
+		return
 	}
 
 	_, err = client.Register(context.Background(), acct, prompt)
@@ -113,6 +92,18 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 
 	authzUrls := order.AuthzURLs
 
+	chalType := "http-01"
+	var dnsProvider DnsProvider
+	if cert.AcmeAuth == certificate.AcmeDns {
+		chalType = "dns-01"
+
+		dnsProvider, err = newDnsProvider(cert)
+		if err != nil {
+			revoke(client, authzUrls)
+			return
+		}
+	}
+
 	for _, authzUrl := range authzUrls {
 		authz, e := client.GetAuthorization(
 			context.Background(), authzUrl)
@@ -129,7 +120,7 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 
 		var authzChal *acme.Challenge
 		for _, c := range authz.Challenges {
-			if c.Type == "http-01" {
+			if c.Type == chalType {
 				authzChal = c
 				break
 			}
@@ -139,12 +130,24 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 			revoke(client, authzUrls)
 
 			err = &errortypes.RequestError{
-				errors.New(
-					"acme: Authorization HTTP challenge not available"),
+				errors.Newf(
+					"acme: Authorization %s challenge not available",
+					chalType,
+				),
 			}
 			return
 		}
 
+		if dnsProvider != nil {
+			err = dnsChallenge(client, dnsProvider, authz, authzChal)
+			if err != nil {
+				revoke(client, authzUrls)
+				return
+			}
+
+			continue
+		}
+
 		resp, e := client.HTTP01ChallengeResponse(authzChal.Token)
 		if e != nil {
 			revoke(client, authzUrls)
@@ -205,19 +208,9 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 		return
 	}
 
-	var csr []byte
-	var keyPem []byte
-
-	if settings.System.AcmeKeyAlgorithm == "ec" {
-		csr, keyPem, err = newEcCsr(cert.AcmeDomains)
-		if err != nil {
-			return
-		}
-	} else {
-		csr, keyPem, err = newRsaCsr(cert.AcmeDomains)
-		if err != nil {
-			return
-		}
+	csr, keyPem, err := newCsr(cert)
+	if err != nil {
+		return
 	}
 
 	derChain, _, err := client.CreateOrderCert(
@@ -0,0 +1,132 @@
+package acme
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/certificate"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/node"
+	"github.com/hydeant/pritunl-zero/service"
+)
+
+func autoDomains(db *database.Database) (domains set.Set, err error) {
+	domains = set.NewSet()
+
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	serviceIds := []primitive.ObjectID{}
+	for _, nde := range nodes {
+		if !nde.AutoCertificates {
+			continue
+		}
+
+		serviceIds = append(serviceIds, nde.Services...)
+	}
+
+	if len(serviceIds) == 0 {
+		return
+	}
+
+	srvcs, err := service.GetMulti(db, serviceIds)
+	if err != nil {
+		return
+	}
+
+	for _, srvc := range srvcs {
+		for _, domain := range srvc.Domains {
+			// HTTP challenges cannot validate wildcard domains
+			if domain.Wildcard() {
+				continue
+			}
+
+			domains.Add(domain.Domain)
+		}
+	}
+
+	return
+}
+
+// Issue certificates for domains on services assigned to nodes with
+// automatic certificates and remove certificates for unused domains
+func SyncAuto(db *database.Database) (err error) {
+	domains, err := autoDomains(db)
+	if err != nil {
+		return
+	}
+
+	certs, err := certificate.GetAuto(db)
+	if err != nil {
+		return
+	}
+
+	existing := set.NewSet()
+	for _, cert := range certs {
+		if len(cert.AcmeDomains) == 1 &&
+			domains.Contains(cert.AcmeDomains[0]) &&
+			!existing.Contains(cert.AcmeDomains[0]) {
+
+			existing.Add(cert.AcmeDomains[0])
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"certificate_id": cert.Id.Hex(),
+			"domains":        cert.AcmeDomains,
+		}).Info("acme: Removing unused automatic certificate")
+
+		err = certificate.Remove(db, cert.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	for domainInf := range domains.Iter() {
+		domain := domainInf.(string)
+		if existing.Contains(domain) {
+			continue
+		}
+
+		cert := &certificate.Certificate{
+			Name:        domain,
+			Type:        certificate.LetsEncrypt,
+			Auto:        true,
+			AcmeDomains: []string{domain},
+		}
+
+		errData, e := cert.Validate(db)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			logrus.WithFields(logrus.Fields{
+				"domain":     domain,
+				"error_code": errData.Error,
+				"error_msg":  errData.Message,
+			}).Error("acme: Invalid automatic certificate domain")
+			continue
+		}
+
+		err = cert.Insert(db)
+		if err != nil {
+			return
+		}
+
+		// Failed certificates are retried by the renew task
+		e = Generate(db, cert)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"certificate_id": cert.Id.Hex(),
+				"domain":         domain,
+				"error":          e,
+			}).Error("acme: Failed to generate automatic certificate")
+		}
+	}
+
+	return
+}
@@ -1,6 +1,5 @@
 package acme
 
 const (
-	AcmeDirectory = "https://acme-v02.api.letsencrypt.org/directory"
-	AcmePath      = "/.well-known/acme-challenge/"
+	AcmePath = "/.well-known/acme-challenge/"
 )
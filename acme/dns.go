@@ -0,0 +1,144 @@
+package acme
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/certificate"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/utils"
+	"golang.org/x/crypto/acme"
+)
+
+// DNS provider used to publish DNS-01 challenge records, the fqdn is
+// always fully qualified with a trailing dot
+type DnsProvider interface {
+	Create(fqdn, value string) error
+	Remove(fqdn, value string) error
+}
+
+// Exec provider runs the configured hook with present or cleanup followed
+// by the record name and value, the hook path is only configurable in
+// the server settings
+type dnsExec struct {
+	path string
+}
+
+func (d *dnsExec) run(action, fqdn, value string) (err error) {
+	output, err := utils.ExecCombinedOutput("", d.path, action, fqdn, value)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"action": action,
+			"fqdn":   fqdn,
+			"output": output,
+			"error":  err,
+		}).Error("acme: DNS exec hook failed")
+		return
+	}
+
+	return
+}
+
+func (d *dnsExec) Create(fqdn, value string) error {
+	return d.run("present", fqdn, value)
+}
+
+func (d *dnsExec) Remove(fqdn, value string) error {
+	return d.run("cleanup", fqdn, value)
+}
+
+func newDnsProvider(cert *certificate.Certificate) (
+	provider DnsProvider, err error) {
+
+	switch cert.AcmeDnsProvider {
+	case certificate.DnsRfc2136:
+		provider = &dnsRfc2136{
+			server:       cert.AcmeDnsServer,
+			keyName:      cert.AcmeDnsKeyName,
+			keySecret:    cert.AcmeDnsKeySecret,
+			keyAlgorithm: cert.AcmeDnsKeyAlgorithm,
+		}
+		break
+	case certificate.DnsExec:
+		if settings.Acme.DnsExec == "" {
+			err = &errortypes.ReadError{
+				errors.New("acme: DNS exec hook not configured"),
+			}
+			return
+		}
+
+		provider = &dnsExec{
+			path: settings.Acme.DnsExec,
+		}
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.Newf("acme: Unknown DNS provider '%s'",
+				cert.AcmeDnsProvider),
+		}
+		return
+	}
+
+	return
+}
+
+func dnsChallengeFqdn(domain string) string {
+	domain = strings.TrimPrefix(domain, "*.")
+	return "_acme-challenge." + strings.TrimSuffix(domain, ".") + "."
+}
+
+func dnsChallenge(client *acme.Client, provider DnsProvider,
+	authz *acme.Authorization, chal *acme.Challenge) (err error) {
+
+	value, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Challenge response failed"),
+		}
+		return
+	}
+
+	fqdn := dnsChallengeFqdn(authz.Identifier.Value)
+
+	err = provider.Create(fqdn, value)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := provider.Remove(fqdn, value)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"fqdn":  fqdn,
+				"error": e,
+			}).Error("acme: Failed to remove DNS challenge record")
+		}
+	}()
+
+	time.Sleep(time.Duration(settings.Acme.DnsDelay) * time.Second)
+
+	_, err = client.Accept(context.Background(), chal)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization accept failed"),
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(settings.Acme.DnsTimeout)*time.Second)
+	defer cancel()
+
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization wait failed"),
+		}
+		return
+	}
+
+	return
+}
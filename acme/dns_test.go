@@ -0,0 +1,157 @@
+package acme
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hydeant/pritunl-zero/certificate"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
+)
+
+// Settings are loaded from the database, allocate the unexported acme
+// settings when not loaded
+func initTestSettings() {
+	if settings.Acme == nil {
+		val := reflect.ValueOf(&settings.Acme).Elem()
+		val.Set(reflect.New(val.Type().Elem()))
+	}
+}
+
+func TestDnsChallengeFqdn(t *testing.T) {
+	tests := map[string]string{
+		"pritunl.test":       "_acme-challenge.pritunl.test.",
+		"pritunl.test.":      "_acme-challenge.pritunl.test.",
+		"*.pritunl.test":     "_acme-challenge.pritunl.test.",
+		"www.pritunl.test":   "_acme-challenge.www.pritunl.test.",
+		"*.www.pritunl.test": "_acme-challenge.www.pritunl.test.",
+	}
+
+	for domain, fqdn := range tests {
+		if dnsChallengeFqdn(domain) != fqdn {
+			t.Errorf("%s: Unexpected fqdn %s", domain,
+				dnsChallengeFqdn(domain))
+		}
+	}
+}
+
+func TestNewDnsProvider(t *testing.T) {
+	initTestSettings()
+
+	dnsExecPath := settings.Acme.DnsExec
+	defer func() {
+		settings.Acme.DnsExec = dnsExecPath
+	}()
+
+	provider, err := newDnsProvider(&certificate.Certificate{
+		AcmeDnsProvider:     certificate.DnsRfc2136,
+		AcmeDnsServer:       "127.0.0.1:5353",
+		AcmeDnsKeyName:      "acme-key",
+		AcmeDnsKeySecret:    testKeySecret,
+		AcmeDnsKeyAlgorithm: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rfc2136, ok := provider.(*dnsRfc2136)
+	if !ok {
+		t.Fatal("Expected RFC 2136 provider")
+	}
+
+	if rfc2136.server != "127.0.0.1:5353" ||
+		rfc2136.keyName != "acme-key" ||
+		rfc2136.keySecret != testKeySecret ||
+		rfc2136.keyAlgorithm != "hmac-sha256" {
+
+		t.Error("RFC 2136 provider configuration not applied")
+	}
+
+	settings.Acme.DnsExec = ""
+
+	_, err = newDnsProvider(&certificate.Certificate{
+		AcmeDnsProvider: certificate.DnsExec,
+	})
+	if _, ok := err.(*errortypes.ReadError); !ok {
+		t.Errorf("Expected unconfigured exec error got %v", err)
+	}
+
+	settings.Acme.DnsExec = "/usr/local/bin/dns-hook"
+
+	provider, err = newDnsProvider(&certificate.Certificate{
+		AcmeDnsProvider: certificate.DnsExec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec, ok := provider.(*dnsExec)
+	if !ok || exec.path != settings.Acme.DnsExec {
+		t.Error("Exec provider configuration not applied")
+	}
+
+	_, err = newDnsProvider(&certificate.Certificate{
+		AcmeDnsProvider: "invalid",
+	})
+	if _, ok := err.(*errortypes.UnknownError); !ok {
+		t.Errorf("Expected unknown provider error got %v", err)
+	}
+}
+
+func TestDnsExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pritunl-zero-dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputPath := filepath.Join(dir, "output")
+	hookPath := filepath.Join(dir, "hook")
+
+	err = ioutil.WriteFile(hookPath, []byte(
+		"#!/bin/sh\necho \"$@\" >> "+outputPath+"\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &dnsExec{
+		path: hookPath,
+	}
+
+	err = d.Create("_acme-challenge.pritunl.test.", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Remove("_acme-challenge.pritunl.test.", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 ||
+		lines[0] != "present _acme-challenge.pritunl.test. value" ||
+		lines[1] != "cleanup _acme-challenge.pritunl.test. value" {
+
+		t.Errorf("Unexpected hook arguments %q", lines)
+	}
+
+	err = ioutil.WriteFile(hookPath, []byte("#!/bin/sh\nexit 1\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Create("_acme-challenge.pritunl.test.", "value")
+	if _, ok := err.(*errortypes.ExecError); !ok {
+		t.Errorf("Expected exec error got %v", err)
+	}
+}
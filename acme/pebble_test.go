@@ -0,0 +1,258 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hydeant/pritunl-zero/certificate"
+	"golang.org/x/crypto/acme"
+)
+
+// Pebble must be started with PEBBLE_VA_ALWAYS_VALID=1, the CA bundle is
+// the pebble.minica.pem certificate from the Pebble repository
+const (
+	pebbleDirectoryEnv = "PEBBLE_DIRECTORY"
+	pebbleCaBundleEnv  = "PEBBLE_CA_BUNDLE"
+)
+
+func newTestCaBundle(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templ := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName: "Test CA",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	certBytes, err := x509.CreateCertificate(
+		rand.Reader, templ, templ, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certBytes,
+	}))
+}
+
+func TestNewClientCaBundle(t *testing.T) {
+	acctKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := &certificate.Certificate{
+		AcmeDirectory: "https://acme.test/directory",
+		AcmeCaBundle:  newTestCaBundle(t),
+	}
+
+	client, err := newClient(cert, acctKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.DirectoryURL != cert.AcmeDirectory {
+		t.Errorf("Unexpected directory %s", client.DirectoryURL)
+	}
+
+	if client.HTTPClient == nil {
+		t.Fatal("CA bundle not applied to client")
+	}
+
+	transport := client.HTTPClient.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil ||
+		transport.TLSClientConfig.RootCAs == nil {
+
+		t.Error("CA bundle missing from client roots")
+	}
+
+	cert.AcmeCaBundle = "invalid"
+
+	_, err = newClient(cert, acctKey)
+	if err == nil {
+		t.Error("Invalid CA bundle accepted")
+	}
+}
+
+func pebbleCertificate(t *testing.T,
+	keyAlgorithm string) *certificate.Certificate {
+
+	directory := os.Getenv(pebbleDirectoryEnv)
+	if directory == "" {
+		t.Skipf("%s not set", pebbleDirectoryEnv)
+	}
+
+	cert := &certificate.Certificate{
+		Type:          certificate.LetsEncrypt,
+		AcmeDirectory: directory,
+		AcmeDomains: []string{
+			fmt.Sprintf("renew-%d.pritunl.test", time.Now().UnixNano()),
+		},
+		AcmeKeyAlgorithm: keyAlgorithm,
+	}
+
+	caPath := os.Getenv(pebbleCaBundleEnv)
+	if caPath != "" {
+		caBundle, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert.AcmeCaBundle = string(caBundle)
+	}
+
+	return cert
+}
+
+func pebbleIssue(t *testing.T, client *acme.Client,
+	cert *certificate.Certificate) *x509.Certificate {
+
+	ctx := context.Background()
+
+	order, err := client.AuthorizeOrder(
+		ctx, acme.DomainIDs(cert.AcmeDomains...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, authzUrl := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authz.Status != acme.StatusPending {
+			continue
+		}
+
+		var authzChal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "http-01" {
+				authzChal = c
+				break
+			}
+		}
+
+		if authzChal == nil {
+			t.Fatal("Authorization http-01 challenge not available")
+		}
+
+		_, err = client.Accept(ctx, authzChal)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.WaitAuthorization(ctx, authz.URI)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, _, err := newCsr(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derChain, _, err := client.CreateOrderCert(
+		ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(derChain[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf
+}
+
+func TestPebbleRenew(t *testing.T) {
+	curves := map[string]elliptic.Curve{
+		certificate.EcP256: elliptic.P256(),
+		certificate.EcP384: elliptic.P384(),
+	}
+
+	for _, keyAlgorithm := range []string{
+		certificate.Rsa,
+		certificate.EcP256,
+		certificate.EcP384,
+	} {
+		cert := pebbleCertificate(t, keyAlgorithm)
+
+		acctKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client, err := newClient(cert, acctKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.Register(
+			context.Background(), &acme.Account{}, prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		issued := pebbleIssue(t, client, cert)
+		renewed := pebbleIssue(t, client, cert)
+
+		if issued.SerialNumber.Cmp(renewed.SerialNumber) == 0 {
+			t.Errorf("%s: Renewal returned the same certificate",
+				keyAlgorithm)
+		}
+
+		for _, leaf := range []*x509.Certificate{issued, renewed} {
+			err = leaf.VerifyHostname(cert.AcmeDomains[0])
+			if err != nil {
+				t.Errorf("%s: %s", keyAlgorithm, err)
+			}
+
+			switch pubKey := leaf.PublicKey.(type) {
+			case *rsa.PublicKey:
+				if keyAlgorithm != certificate.Rsa {
+					t.Errorf("%s: Unexpected RSA certificate key",
+						keyAlgorithm)
+				}
+				break
+			case *ecdsa.PublicKey:
+				if pubKey.Curve != curves[keyAlgorithm] {
+					t.Errorf("%s: Unexpected EC certificate key",
+						keyAlgorithm)
+				}
+				break
+			default:
+				t.Errorf("%s: Unknown certificate key", keyAlgorithm)
+			}
+		}
+	}
+}
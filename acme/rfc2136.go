@@ -0,0 +1,144 @@
+package acme
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/miekg/dns"
+)
+
+// RFC 2136 dynamic update provider with optional TSIG authentication
+type dnsRfc2136 struct {
+	server       string
+	keyName      string
+	keySecret    string
+	keyAlgorithm string
+}
+
+func (d *dnsRfc2136) addr() string {
+	if _, _, err := net.SplitHostPort(d.server); err != nil {
+		return net.JoinHostPort(d.server, "53")
+	}
+	return d.server
+}
+
+func (d *dnsRfc2136) client() (client *dns.Client) {
+	client = &dns.Client{
+		Net:     "tcp",
+		Timeout: 10 * time.Second,
+	}
+
+	if d.keyName != "" {
+		client.TsigSecret = map[string]string{
+			dns.Fqdn(d.keyName): d.keySecret,
+		}
+	}
+
+	return
+}
+
+func (d *dnsRfc2136) exchange(msg *dns.Msg) (resp *dns.Msg, err error) {
+	if d.keyName != "" {
+		msg.SetTsig(dns.Fqdn(d.keyName), dns.Fqdn(d.keyAlgorithm),
+			300, time.Now().Unix())
+	}
+
+	resp, _, err = d.client().Exchange(msg, d.addr())
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: DNS server request failed"),
+		}
+		return
+	}
+
+	return
+}
+
+// Find zone containing fqdn from the SOA record returned by the server
+func (d *dnsRfc2136) zone(fqdn string) (zone string, err error) {
+	name := fqdn
+
+	for {
+		msg := &dns.Msg{}
+		msg.SetQuestion(name, dns.TypeSOA)
+
+		resp, e := d.exchange(msg)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if resp.Rcode == dns.RcodeSuccess ||
+			resp.Rcode == dns.RcodeNameError {
+
+			for _, rr := range append(resp.Answer, resp.Ns...) {
+				if soa, ok := rr.(*dns.SOA); ok {
+					zone = soa.Hdr.Name
+					return
+				}
+			}
+		}
+
+		i := strings.Index(name, ".")
+		if i == -1 || i == len(name)-1 {
+			break
+		}
+		name = name[i+1:]
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.Newf("acme: Failed to find DNS zone for '%s'", fqdn),
+	}
+	return
+}
+
+func (d *dnsRfc2136) update(fqdn, value string, remove bool) (err error) {
+	zone, err := d.zone(fqdn)
+	if err != nil {
+		return
+	}
+
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   fqdn,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    60,
+		},
+		Txt: []string{value},
+	}
+
+	msg := &dns.Msg{}
+	msg.SetUpdate(zone)
+	if remove {
+		msg.Remove([]dns.RR{rr})
+	} else {
+		msg.Insert([]dns.RR{rr})
+	}
+
+	resp, err := d.exchange(msg)
+	if err != nil {
+		return
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		err = &errortypes.RequestError{
+			errors.Newf("acme: DNS update failed with '%s'",
+				dns.RcodeToString[resp.Rcode]),
+		}
+		return
+	}
+
+	return
+}
+
+func (d *dnsRfc2136) Create(fqdn, value string) error {
+	return d.update(fqdn, value, false)
+}
+
+func (d *dnsRfc2136) Remove(fqdn, value string) error {
+	return d.update(fqdn, value, true)
+}
@@ -0,0 +1,266 @@
+package acme
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/miekg/dns"
+)
+
+const (
+	testZone      = "pritunl.test."
+	testKeyName   = "acme-key."
+	testKeySecret = "c2VjcmV0LWtleS1mb3ItdGVzdHM="
+)
+
+type testDnsServer struct {
+	server  *dns.Server
+	addr    string
+	lock    sync.Mutex
+	refuse  bool
+	updates []*dns.Msg
+}
+
+func (s *testDnsServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+
+	if req.IsTsig() != nil {
+		if w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeNotAuth
+			w.WriteMsg(resp)
+			return
+		}
+
+		resp.SetTsig(testKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	switch req.Opcode {
+	case dns.OpcodeQuery:
+		name := req.Question[0].Name
+		if name == testZone {
+			resp.Answer = append(resp.Answer, &dns.SOA{
+				Hdr: dns.RR_Header{
+					Name:   testZone,
+					Rrtype: dns.TypeSOA,
+					Class:  dns.ClassINET,
+					Ttl:    60,
+				},
+				Ns:   "ns." + testZone,
+				Mbox: "admin." + testZone,
+			})
+		} else {
+			resp.Rcode = dns.RcodeNameError
+		}
+		break
+	case dns.OpcodeUpdate:
+		s.lock.Lock()
+		s.updates = append(s.updates, req)
+		refuse := s.refuse
+		s.lock.Unlock()
+
+		if refuse {
+			resp.Rcode = dns.RcodeRefused
+		}
+		break
+	}
+
+	w.WriteMsg(resp)
+}
+
+func newTestDnsServer(t *testing.T) *testDnsServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testDnsServer{
+		addr: listener.Addr().String(),
+	}
+
+	started := make(chan bool)
+	s.server = &dns.Server{
+		Listener: listener,
+		Handler:  s,
+		TsigSecret: map[string]string{
+			testKeyName: testKeySecret,
+		},
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+		NotifyStartedFunc: func() {
+			close(started)
+		},
+	}
+
+	go s.server.ActivateAndServe()
+	<-started
+
+	t.Cleanup(func() {
+		s.server.Shutdown()
+	})
+
+	return s
+}
+
+func TestRfc2136Addr(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":       "127.0.0.1:53",
+		"127.0.0.1:5353":  "127.0.0.1:5353",
+		"ns.pritunl.test": "ns.pritunl.test:53",
+		"::1":             "[::1]:53",
+		"[::1]:5353":      "[::1]:5353",
+	}
+
+	for server, addr := range tests {
+		d := &dnsRfc2136{
+			server: server,
+		}
+
+		if d.addr() != addr {
+			t.Errorf("%s: Unexpected address %s", server, d.addr())
+		}
+	}
+}
+
+func TestRfc2136Client(t *testing.T) {
+	d := &dnsRfc2136{}
+
+	if d.client().TsigSecret != nil {
+		t.Error("TSIG secret set without key")
+	}
+
+	d = &dnsRfc2136{
+		keyName:   "acme-key",
+		keySecret: testKeySecret,
+	}
+
+	if d.client().TsigSecret[testKeyName] != testKeySecret {
+		t.Error("TSIG secret not set for fully qualified key name")
+	}
+}
+
+func TestRfc2136Update(t *testing.T) {
+	s := newTestDnsServer(t)
+
+	d := &dnsRfc2136{
+		server:       s.addr,
+		keyName:      "acme-key",
+		keySecret:    testKeySecret,
+		keyAlgorithm: dns.HmacSHA256,
+	}
+
+	fqdn := dnsChallengeFqdn("www.pritunl.test")
+
+	err := d.Create(fqdn, "create-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Remove(fqdn, "create-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.updates) != 2 {
+		t.Fatalf("Expected 2 updates got %d", len(s.updates))
+	}
+
+	for i, update := range s.updates {
+		if update.IsTsig() == nil {
+			t.Errorf("Update %d not signed", i)
+		}
+
+		if len(update.Question) != 1 || update.Question[0].Name != testZone {
+			t.Errorf("Update %d has unexpected zone", i)
+		}
+
+		if len(update.Ns) != 1 {
+			t.Fatalf("Update %d has %d records", i, len(update.Ns))
+		}
+
+		rr, ok := update.Ns[0].(*dns.TXT)
+		if !ok {
+			t.Fatalf("Update %d record is not TXT", i)
+		}
+
+		if rr.Hdr.Name != "_acme-challenge.www.pritunl.test." ||
+			len(rr.Txt) != 1 || rr.Txt[0] != "create-value" {
+
+			t.Errorf("Update %d has unexpected record %s", i, rr)
+		}
+
+		if i == 0 && rr.Hdr.Class != dns.ClassINET {
+			t.Errorf("Create has unexpected class %d", rr.Hdr.Class)
+		}
+		if i == 1 && rr.Hdr.Class != dns.ClassNONE {
+			t.Errorf("Remove has unexpected class %d", rr.Hdr.Class)
+		}
+	}
+}
+
+func TestRfc2136UpdateUnsigned(t *testing.T) {
+	s := newTestDnsServer(t)
+
+	d := &dnsRfc2136{
+		server: s.addr,
+	}
+
+	err := d.Create("_acme-challenge.pritunl.test.", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.updates) != 1 || s.updates[0].IsTsig() != nil {
+		t.Error("Expected unsigned update")
+	}
+}
+
+func TestRfc2136Errors(t *testing.T) {
+	s := newTestDnsServer(t)
+
+	d := &dnsRfc2136{
+		server:       s.addr,
+		keyName:      testKeyName,
+		keySecret:    "d3Jvbmctc2VjcmV0",
+		keyAlgorithm: dns.HmacSHA256,
+	}
+
+	err := d.Create("_acme-challenge.pritunl.test.", "value")
+	if err == nil {
+		t.Error("Update with invalid TSIG secret succeeded")
+	}
+
+	d.keySecret = testKeySecret
+
+	err = d.Create("_acme-challenge.other.test.", "value")
+	if _, ok := err.(*errortypes.NotFoundError); !ok {
+		t.Errorf("Expected zone not found error got %v", err)
+	}
+
+	s.refuse = true
+
+	err = d.Create("_acme-challenge.pritunl.test.", "value")
+	if _, ok := err.(*errortypes.RequestError); !ok {
+		t.Errorf("Expected refused update error got %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	d = &dnsRfc2136{
+		server: addr,
+	}
+
+	err = d.Create("_acme-challenge.pritunl.test.", "value")
+	if _, ok := err.(*errortypes.RequestError); !ok {
+		t.Errorf("Expected request error got %v", err)
+	}
+}
@@ -2,18 +2,24 @@ package acme
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"net/http"
 	"strings"
 
+	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/certificate"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
 	"golang.org/x/crypto/acme"
 )
 
@@ -64,6 +70,148 @@ func GetChallenge(token string) (challenge *Challenge, err error) {
 	return
 }
 
+// Load the certificate account key or generate a new account key using
+// the certificate key algorithm, RSA account keys are 2048 bits and EC
+// account keys use P-256
+func getAccountKey(db *database.Database, cert *certificate.Certificate) (
+	acctKey crypto.Signer, err error) {
+
+	if cert.AcmeAccount != "" {
+		acctBlock, _ := pem.Decode([]byte(cert.AcmeAccount))
+		if acctBlock == nil {
+			err = &errortypes.ParseError{
+				errors.New("acme: Failed to decode account key"),
+			}
+			return
+		}
+
+		switch acctBlock.Type {
+		case "EC PRIVATE KEY":
+			acctKey, err = x509.ParseECPrivateKey(acctBlock.Bytes)
+			break
+		default:
+			acctKey, err = x509.ParsePKCS1PrivateKey(acctBlock.Bytes)
+		}
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "acme: Failed to parse account key"),
+			}
+			return
+		}
+
+		return
+	}
+
+	var acctBlock *pem.Block
+
+	switch cert.AcmeKeyAlgorithm {
+	case certificate.EcP256, certificate.EcP384:
+		key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "acme: Failed to generate account key"),
+			}
+			return
+		}
+
+		keyByt, e := x509.MarshalECPrivateKey(key)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "acme: Failed to parse account key"),
+			}
+			return
+		}
+
+		acctKey = key
+		acctBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyByt,
+		}
+		break
+	default:
+		key, e := rsa.GenerateKey(rand.Reader, 2048)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "acme: Failed to generate account key"),
+			}
+			return
+		}
+
+		acctKey = key
+		acctBlock = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}
+	}
+
+	cert.AcmeAccount = string(pem.EncodeToMemory(acctBlock))
+	err = cert.CommitFields(db, set.NewSet("acme_account"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ACME client for the certificate directory, the certificate CA bundle is
+// trusted when set
+func newClient(cert *certificate.Certificate, acctKey crypto.Signer) (
+	client *acme.Client, err error) {
+
+	client = &acme.Client{
+		DirectoryURL: cert.Directory(),
+		Key:          acctKey,
+	}
+
+	if cert.AcmeCaBundle != "" {
+		pool, e := cert.CaPool()
+		if e != nil {
+			err = e
+			return
+		}
+
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					MinVersion: tls.VersionTLS12,
+					RootCAs:    pool,
+				},
+			},
+		}
+	}
+
+	return
+}
+
+// Create the certificate request using the certificate key algorithm or
+// the system default
+func newCsr(cert *certificate.Certificate) (
+	csr []byte, keyPem []byte, err error) {
+
+	keyAlgorithm := cert.AcmeKeyAlgorithm
+	if keyAlgorithm == "" {
+		if settings.System.AcmeKeyAlgorithm == "ec" {
+			keyAlgorithm = certificate.EcP384
+		} else {
+			keyAlgorithm = certificate.Rsa
+		}
+	}
+
+	switch keyAlgorithm {
+	case certificate.EcP256:
+		csr, keyPem, err = newEcCsr(cert.AcmeDomains, elliptic.P256())
+		break
+	case certificate.EcP384:
+		csr, keyPem, err = newEcCsr(cert.AcmeDomains, elliptic.P384())
+		break
+	default:
+		csr, keyPem, err = newRsaCsr(cert.AcmeDomains)
+	}
+
+	return
+}
+
 func newRsaCsr(domains []string) (csr []byte, keyPem []byte, err error) {
 	key, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
@@ -103,9 +251,11 @@ func newRsaCsr(domains []string) (csr []byte, keyPem []byte, err error) {
 	return
 }
 
-func newEcCsr(domains []string) (csr []byte, keyPem []byte, err error) {
+func newEcCsr(domains []string, curve elliptic.Curve) (
+	csr []byte, keyPem []byte, err error) {
+
 	key, err := ecdsa.GenerateKey(
-		elliptic.P384(),
+		curve,
 		rand.Reader,
 	)
 	if err != nil {
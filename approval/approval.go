@@ -0,0 +1,160 @@
+// Two-person approval of SSH certificate requests.
+package approval
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/agent"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+type Approval struct {
+	Id            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	ChallengeId   string               `bson:"challenge_id" json:"-"`
+	UserId        primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Username      string               `bson:"username" json:"username"`
+	AuthorityIds  []primitive.ObjectID `bson:"authority_ids" json:"authority_ids"`
+	ApproverRoles [][]string           `bson:"approver_roles" json:"approver_roles"`
+	PubKey        string               `bson:"pub_key" json:"pub_key"`
+	RemoteAddr    string               `bson:"remote_addr" json:"remote_addr"`
+	Agent         *agent.Agent         `bson:"agent" json:"agent"`
+	State         string               `bson:"state" json:"state"`
+	ApproverId    primitive.ObjectID   `bson:"approver_id,omitempty" json:"approver_id"`
+	Timestamp     time.Time            `bson:"timestamp" json:"timestamp"`
+	Expires       time.Time            `bson:"expires" json:"expires"`
+}
+
+func (a *Approval) Expired() bool {
+	return time.Now().After(a.Expires)
+}
+
+// User has an approver role for each authority requiring approval and is
+// not the requester
+func (a *Approval) CanApprove(usr *user.User) bool {
+	if usr.Disabled || usr.Id == a.UserId || len(a.ApproverRoles) == 0 {
+		return false
+	}
+
+	for _, roles := range a.ApproverRoles {
+		if !hasRole(usr, roles) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Answer the pending approval, only one answer will be accepted
+func (a *Approval) answer(db *database.Database, approver *user.User,
+	state string) (errData *errortypes.ErrorData, err error) {
+
+	if !a.CanApprove(approver) {
+		errData = &errortypes.ErrorData{
+			Error:   "approver_invalid",
+			Message: "User is not permitted to answer this request",
+		}
+		return
+	}
+
+	if a.State != Pending {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_answered",
+			Message: "Request has already been answered",
+		}
+		return
+	}
+
+	if a.Expired() {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_expired",
+			Message: "Request has expired",
+		}
+		return
+	}
+
+	coll := db.SshApprovals()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":   a.Id,
+		"state": Pending,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state":       state,
+			"approver_id": approver.Id,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_answered",
+			Message: "Request has already been answered",
+		}
+		return
+	}
+
+	a.State = state
+	a.ApproverId = approver.Id
+
+	return
+}
+
+func (a *Approval) Approve(db *database.Database, approver *user.User) (
+	errData *errortypes.ErrorData, err error) {
+
+	errData, err = a.answer(db, approver, Approved)
+	return
+}
+
+func (a *Approval) Deny(db *database.Database, approver *user.User) (
+	errData *errortypes.ErrorData, err error) {
+
+	errData, err = a.answer(db, approver, Denied)
+	return
+}
+
+// Return an approved request to pending when the certificate could not be
+// issued
+func (a *Approval) Rollback(db *database.Database) (err error) {
+	coll := db.SshApprovals()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   a.Id,
+		"state": Approved,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state": Pending,
+		},
+		"$unset": &bson.M{
+			"approver_id": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	a.State = Pending
+	a.ApproverId = primitive.NilObjectID
+
+	return
+}
+
+func (a *Approval) Insert(db *database.Database) (err error) {
+	coll := db.SshApprovals()
+
+	_, err = coll.InsertOne(db, a)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,69 @@
+package approval
+
+import (
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+func TestCanApprove(t *testing.T) {
+	apprvl := &Approval{
+		UserId: primitive.NewObjectID(),
+		ApproverRoles: [][]string{
+			[]string{"prod-approver", "security"},
+			[]string{"db-approver"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		usr     *user.User
+		approve bool
+	}{
+		{
+			"requester",
+			&user.User{
+				Id:    apprvl.UserId,
+				Roles: []string{"prod-approver", "db-approver"},
+			},
+			false,
+		},
+		{
+			"all_authorities",
+			&user.User{
+				Id:    primitive.NewObjectID(),
+				Roles: []string{"security", "db-approver"},
+			},
+			true,
+		},
+		{
+			"single_authority",
+			&user.User{
+				Id:    primitive.NewObjectID(),
+				Roles: []string{"db-approver"},
+			},
+			false,
+		},
+		{
+			"disabled",
+			&user.User{
+				Id:       primitive.NewObjectID(),
+				Roles:    []string{"prod-approver", "db-approver"},
+				Disabled: true,
+			},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		if apprvl.CanApprove(test.usr) != test.approve {
+			t.Errorf("%s: Expected approve %t", test.name, test.approve)
+		}
+	}
+
+	apprvl.ApproverRoles = [][]string{}
+	if apprvl.CanApprove(tests[1].usr) {
+		t.Error("Approval without approver roles approved")
+	}
+}
@@ -0,0 +1,13 @@
+package approval
+
+import (
+	"time"
+)
+
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Denied   = "denied"
+
+	Timeout = 5 * time.Minute
+)
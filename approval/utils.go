@@ -0,0 +1,122 @@
+package approval
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/hydeant/pritunl-zero/agent"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+func hasRole(usr *user.User, roles []string) bool {
+	for _, role := range roles {
+		for _, usrRole := range usr.Roles {
+			if role == usrRole {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func Get(db *database.Database, apprvlId primitive.ObjectID) (
+	apprvl *Approval, err error) {
+
+	coll := db.SshApprovals()
+	apprvl = &Approval{}
+
+	err = coll.FindOneId(apprvlId, apprvl)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Get the pending requests the user can approve
+func GetPending(db *database.Database, usr *user.User) (
+	apprvls []*Approval, err error) {
+
+	coll := db.SshApprovals()
+	apprvls = []*Approval{}
+
+	if usr.Disabled || len(usr.Roles) == 0 {
+		return
+	}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"state": Pending,
+		"expires": &bson.M{
+			"$gt": time.Now(),
+		},
+		"user_id": &bson.M{
+			"$ne": usr.Id,
+		},
+	}, &options.FindOptions{
+		Sort: &bson.D{
+			{"timestamp", -1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		apprvl := &Approval{}
+		err = cursor.Decode(apprvl)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		if !apprvl.CanApprove(usr) {
+			continue
+		}
+
+		apprvls = append(apprvls, apprvl)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func New(db *database.Database, chalId string, usr *user.User,
+	authrIds []primitive.ObjectID, approverRoles [][]string,
+	agnt *agent.Agent, pubKey, remoteAddr string) (
+	apprvl *Approval, err error) {
+
+	now := time.Now()
+
+	apprvl = &Approval{
+		Id:            primitive.NewObjectID(),
+		ChallengeId:   chalId,
+		UserId:        usr.Id,
+		Username:      usr.Username,
+		AuthorityIds:  authrIds,
+		ApproverRoles: approverRoles,
+		PubKey:        pubKey,
+		RemoteAddr:    remoteAddr,
+		Agent:         agnt,
+		State:         Pending,
+		Timestamp:     now,
+		Expires:       now.Add(Timeout),
+	}
+
+	err = apprvl.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -20,6 +20,7 @@ const (
 	ProxyDeviceApprove         = "proxy_device_approve"
 	ProxyDeviceRegisterRequest = "proxy_device_register_request"
 	ProxyDeviceRegister        = "proxy_device_register"
+	ProxyUpstreamVerifyFailed  = "proxy_upstream_verify_failed"
 
 	UserLogin                 = "user_login"
 	UserLoginFailed           = "user_login_failed"
@@ -43,4 +44,21 @@ const (
 	OktaDeny             = "okta_deny"
 	SshApprove           = "ssh_approve"
 	SshDeny              = "ssh_deny"
+	SshServiceApprove    = "ssh_service_approve"
+	SshApprovalRequest   = "ssh_approval_request"
+	SshApprovalGrant     = "ssh_approval_grant"
+	SshApprovalDeny      = "ssh_approval_deny"
+	SshTerminalStart     = "ssh_terminal_start"
+	SshTerminalEnd       = "ssh_terminal_end"
+	SshBastionApprove    = "ssh_bastion_approve"
+
+	AuthorityRotateStart   = "authority_rotate_start"
+	AuthorityRotateCancel  = "authority_rotate_cancel"
+	AuthorityRotatePromote = "authority_rotate_promote"
+	AuthorityRotateRetire  = "authority_rotate_retire"
+
+	HsmAgentOffline = "hsm_agent_offline"
+
+	RevocationCreate = "revocation_create"
+	RevocationRemove = "revocation_remove"
 )
@@ -110,3 +110,48 @@ func New(db *database.Database, r *http.Request,
 
 	return
 }
+
+// Audit entry for user actions not initiated by a web request
+func NewUser(db *database.Database, userId primitive.ObjectID, typ string,
+	fields Fields) (err error) {
+
+	if settings.System.Demo {
+		return
+	}
+
+	adt := &Audit{
+		User:      userId,
+		Timestamp: time.Now(),
+		Type:      typ,
+		Fields:    fields,
+	}
+
+	err = adt.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Audit entry for scheduled actions not initiated by a user request
+func NewSystem(db *database.Database, typ string, fields Fields) (
+	err error) {
+
+	if settings.System.Demo {
+		return
+	}
+
+	adt := &Audit{
+		Timestamp: time.Now(),
+		Type:      typ,
+		Fields:    fields,
+	}
+
+	err = adt.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
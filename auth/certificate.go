@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+const (
+	CertMatchEmail     = "email"
+	CertMatchDns       = "dns"
+	CertMatchUri       = "uri"
+	CertMatchSubjectCn = "subject_cn"
+)
+
+var (
+	CertMatches = set.NewSet(
+		CertMatchEmail,
+		CertMatchDns,
+		CertMatchUri,
+		CertMatchSubjectCn,
+	)
+	adminCasLock = sync.Mutex{}
+	adminCasPem  = ""
+	adminCas     *x509.CertPool
+)
+
+func ParseAdminCertificateCas(data string) (
+	pool *x509.CertPool, err error) {
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(data)) {
+		pool = nil
+		err = &errortypes.ParseError{
+			errors.New("auth: Failed to parse admin certificate CAs"),
+		}
+		return
+	}
+
+	return
+}
+
+// Trusted CAs for admin client certificates, nil when admin client
+// certificates are not required
+func AdminCertificatePool() (pool *x509.CertPool, err error) {
+	if !settings.Auth.AdminCertificate {
+		return
+	}
+
+	casPem := settings.Auth.AdminCertificateCas
+
+	adminCasLock.Lock()
+	defer adminCasLock.Unlock()
+
+	if adminCas != nil && casPem == adminCasPem {
+		pool = adminCas
+		return
+	}
+
+	pool, err = ParseAdminCertificateCas(casPem)
+	if err != nil {
+		return
+	}
+
+	adminCas = pool
+	adminCasPem = casPem
+
+	return
+}
+
+func certIdentities(cert *x509.Certificate, match string) (
+	identities []string) {
+
+	identities = []string{}
+
+	switch match {
+	case CertMatchDns:
+		identities = append(identities, cert.DNSNames...)
+		break
+	case CertMatchUri:
+		for _, uri := range cert.URIs {
+			identities = append(identities, uri.String())
+		}
+		break
+	case CertMatchSubjectCn:
+		if cert.Subject.CommonName != "" {
+			identities = append(identities, cert.Subject.CommonName)
+		}
+		break
+	default:
+		identities = append(identities, cert.EmailAddresses...)
+	}
+
+	return
+}
+
+// Verify the request client certificate is signed by a CA in the pool
+// and maps to the admin username
+func VerifyAdminCertificate(r *http.Request, usr *user.User,
+	pool *x509.CertPool, match string) (errData *errortypes.ErrorData) {
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "admin_certificate_missing",
+			Message: "Client certificate required",
+		}
+		return
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(intermediate)
+	}
+
+	_, e := cert.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if e != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "admin_certificate_invalid",
+			Message: "Client certificate not trusted",
+		}
+		return
+	}
+
+	for _, identity := range certIdentities(cert, match) {
+		if strings.EqualFold(identity, usr.Username) {
+			return
+		}
+	}
+
+	errData = &errortypes.ErrorData{
+		Error:   "admin_certificate_mismatch",
+		Message: "Client certificate does not match user",
+	}
+	return
+}
+
+func AdminCertificateCheck(r *http.Request, usr *user.User) (
+	errData *errortypes.ErrorData, err error) {
+
+	pool, err := AdminCertificatePool()
+	if err != nil || pool == nil {
+		return
+	}
+
+	errData = VerifyAdminCertificate(
+		r, usr, pool, settings.Auth.AdminCertificateMatch)
+
+	return
+}
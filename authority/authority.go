@@ -1,9 +1,11 @@
 package authority
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
@@ -26,6 +28,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
@@ -58,36 +61,53 @@ type Info struct {
 }
 
 type Authority struct {
-	Id                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name               string             `bson:"name" json:"name"`
-	Type               string             `bson:"type" json:"type"`
-	Info               *Info              `bson:"info" json:"info"`
-	MatchRoles         bool               `bson:"match_roles" json:"match_roles"`
-	Roles              []string           `bson:"roles" json:"roles"`
-	Expire             int                `bson:"expire" json:"expire"`
-	HostExpire         int                `bson:"host_expire" json:"host_expire"`
-	PrivateKey         string             `bson:"private_key" json:"-"`
-	PublicKey          string             `bson:"public_key" json:"public_key"`
-	PublicKeyPem       string             `bson:"public_key_pem" json:"public_key_pem"`
-	RootCertificate    string             `bson:"root_certificate" json:"root_certificate"`
-	ProxyJump          string             `bson:"-" json:"proxy_jump"`
-	ProxyPrivateKey    string             `bson:"proxy_private_key" json:"-"`
-	ProxyPublicKey     string             `bson:"proxy_public_key" json:"proxy_public_key"`
-	ProxyHosting       bool               `bson:"proxy_hosting" json:"proxy_hosting"`
-	ProxyHostname      string             `bson:"proxy_hostname" json:"proxy_hostname"`
-	ProxyPort          int                `bson:"proxy_port" json:"proxy_port"`
-	HostDomain         string             `bson:"host_domain" json:"host_domain"`
-	HostSubnets        []string           `bson:"host_subnets" json:"host_subnets"`
-	HostMatches        []string           `bson:"host_matches" json:"host_matches"`
-	HostProxy          string             `bson:"host_proxy" json:"host_proxy"`
-	HostCertificates   bool               `bson:"host_certificates" json:"host_certificates"`
-	StrictHostChecking bool               `bson:"strict_host_checking" json:"strict_host_checking"`
-	HostTokens         []string           `bson:"host_tokens" json:"host_tokens"`
-	HsmToken           string             `bson:"hsm_token" json:"hsm_token"`
-	HsmSecret          string             `bson:"hsm_secret" json:"hsm_secret"`
-	HsmSerial          string             `bson:"hsm_serial" json:"hsm_serial"`
-	HsmStatus          string             `bson:"hsm_status" json:"hsm_status"`
-	HsmTimestamp       time.Time          `bson:"hsm_timestamp" json:"hsm_timestamp"`
+	Id                     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name                   string             `bson:"name" json:"name"`
+	Type                   string             `bson:"type" json:"type"`
+	Info                   *Info              `bson:"info" json:"info"`
+	MatchRoles             bool               `bson:"match_roles" json:"match_roles"`
+	Roles                  []string           `bson:"roles" json:"roles"`
+	Expire                 int                `bson:"expire" json:"expire"`
+	HostExpire             int                `bson:"host_expire" json:"host_expire"`
+	ServiceCertificates    bool               `bson:"service_certificates" json:"service_certificates"`
+	ServiceExpire          int                `bson:"service_expire" json:"service_expire"`
+	RequireApproval        bool               `bson:"require_approval" json:"require_approval"`
+	ApproverRoles          []string           `bson:"approver_roles" json:"approver_roles"`
+	PrivateKey             string             `bson:"private_key" json:"-"`
+	PublicKey              string             `bson:"public_key" json:"public_key"`
+	PublicKeyPem           string             `bson:"public_key_pem" json:"public_key_pem"`
+	RootCertificate        string             `bson:"root_certificate" json:"root_certificate"`
+	ProxyJump              string             `bson:"-" json:"proxy_jump"`
+	ProxyPrivateKey        string             `bson:"proxy_private_key" json:"-"`
+	ProxyPublicKey         string             `bson:"proxy_public_key" json:"proxy_public_key"`
+	ProxyHosting           bool               `bson:"proxy_hosting" json:"proxy_hosting"`
+	ProxyHostname          string             `bson:"proxy_hostname" json:"proxy_hostname"`
+	ProxyPort              int                `bson:"proxy_port" json:"proxy_port"`
+	ProxyRecording         bool               `bson:"proxy_recording" json:"proxy_recording"`
+	HostDomain             string             `bson:"host_domain" json:"host_domain"`
+	HostSubnets            []string           `bson:"host_subnets" json:"host_subnets"`
+	HostMatches            []string           `bson:"host_matches" json:"host_matches"`
+	HostProxy              string             `bson:"host_proxy" json:"host_proxy"`
+	HostCertificates       bool               `bson:"host_certificates" json:"host_certificates"`
+	StrictHostChecking     bool               `bson:"strict_host_checking" json:"strict_host_checking"`
+	HostTokens             []string           `bson:"host_tokens" json:"host_tokens"`
+	HsmToken               string             `bson:"hsm_token" json:"hsm_token"`
+	HsmSecret              string             `bson:"hsm_secret" json:"hsm_secret"`
+	HsmSerial              string             `bson:"hsm_serial" json:"hsm_serial"`
+	HsmStatus              string             `bson:"hsm_status" json:"hsm_status"`
+	HsmTimestamp           time.Time          `bson:"hsm_timestamp" json:"hsm_timestamp"`
+	HsmAlerted             bool               `bson:"hsm_alerted" json:"-"`
+	HsmAgents              []*HsmAgent        `bson:"hsm_agents" json:"hsm_agents"`
+	NextPrivateKey         string             `bson:"next_private_key" json:"-"`
+	NextPublicKey          string             `bson:"next_public_key" json:"next_public_key"`
+	PreviousPublicKey      string             `bson:"previous_public_key" json:"previous_public_key"`
+	RotationStatus         string             `bson:"rotation_status" json:"rotation_status"`
+	RotationTimestamp      time.Time          `bson:"rotation_timestamp" json:"rotation_timestamp"`
+	RotationGrace          int                `bson:"rotation_grace" json:"rotation_grace"`
+	RotationRetire         int                `bson:"rotation_retire" json:"rotation_retire"`
+	PrincipalTemplates     []string           `bson:"principal_templates" json:"principal_templates"`
+	HostPrincipalTemplates []string           `bson:"host_principal_templates" json:"host_principal_templates"`
+	RoleTemplates          []*RoleTemplate    `bson:"role_templates" json:"role_templates"`
 }
 
 func (a *Authority) GetDomain(hostname string) string {
@@ -149,6 +169,47 @@ func (a *Authority) GenerateEcPrivateKey() (err error) {
 	return
 }
 
+func (a *Authority) GenerateEd25519PrivateKey() (err error) {
+	privKeyBytes, pubKeyBytes, err := GenerateEd25519Key()
+	if err != nil {
+		return
+	}
+
+	a.Info = &Info{
+		KeyAlg: "ED25519",
+	}
+	a.PrivateKey = strings.TrimSpace(string(privKeyBytes))
+	a.PublicKey = strings.TrimSpace(string(pubKeyBytes))
+
+	err = a.SetPublicKeyPem()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Authority) GeneratePrivateKey(algorithm string) (err error) {
+	switch algorithm {
+	case Rsa4096, "":
+		err = a.GenerateRsaPrivateKey()
+		break
+	case EcP384:
+		err = a.GenerateEcPrivateKey()
+		break
+	case Ed25519:
+		err = a.GenerateEd25519PrivateKey()
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.Newf("authority: Unknown key algorithm '%s'", algorithm),
+		}
+		return
+	}
+
+	return
+}
+
 func (a *Authority) GenerateHsmToken() (err error) {
 	a.PublicKey = ""
 
@@ -191,20 +252,127 @@ func (a *Authority) GetBastionDomain() string {
 	return hostProxy[0]
 }
 
-func (a *Authority) GetCertAuthority() string {
+func (a *Authority) GetCertAuthorities() (certAuthrs []string) {
+	certAuthrs = []string{}
+
 	if a.HostDomain == "" {
-		return ""
+		return
 	}
-	return fmt.Sprintf("@cert-authority *.%s %s", a.HostDomain, a.PublicKey)
+
+	for _, pubKey := range a.TrustedPublicKeys() {
+		certAuthrs = append(certAuthrs, fmt.Sprintf(
+			"@cert-authority *.%s %s", a.HostDomain, pubKey))
+	}
+
+	return
 }
 
-func (a *Authority) GetBastionCertAuthority() string {
+// Known hosts lines for the host matches and subnets, authorities without
+// a host domain or matches permit all hosts
+func (a *Authority) GetMatchCertAuthorities() (certAuthrs []string) {
+	certAuthrs = []string{}
+	patterns := []string{}
+
+	for _, match := range a.HostMatches {
+		patterns = append(patterns, match)
+	}
+
+	for _, hostSubnet := range a.HostSubnets {
+		match, e := parseSubnetMatch(hostSubnet)
+		if e != nil {
+			continue
+		}
+
+		patterns = append(patterns, match)
+	}
+
+	if len(patterns) == 0 {
+		if a.HostDomain != "" {
+			return
+		}
+
+		patterns = append(patterns, "*")
+		bastionDomain := a.GetBastionDomain()
+		if bastionDomain != "" {
+			patterns = append(patterns, "!"+bastionDomain)
+		}
+	}
+
+	for _, pubKey := range a.TrustedPublicKeys() {
+		certAuthrs = append(certAuthrs, fmt.Sprintf(
+			"@cert-authority %s %s", strings.Join(patterns, ","), pubKey))
+	}
+
+	return
+}
+
+// Check the fields written to user ssh configurations cannot start a new
+// directive
+func (a *Authority) validateConfig() (errData *errortypes.ErrorData) {
+	if strings.IndexFunc(a.Name, unicode.IsControl) != -1 {
+		errData = &errortypes.ErrorData{
+			Error:   "authority_name_invalid",
+			Message: "Authority name cannot contain control characters",
+		}
+		return
+	}
+
+	if !validConfigValue(a.HostDomain) {
+		errData = &errortypes.ErrorData{
+			Error:   "host_domain_invalid",
+			Message: "Authority host domain is invalid",
+		}
+		return
+	}
+
+	for _, match := range a.HostMatches {
+		if match == "" || !validConfigValue(match) {
+			errData = &errortypes.ErrorData{
+				Error:   "host_matches_invalid",
+				Message: "Authority host matches are invalid",
+			}
+			return
+		}
+	}
+
+	if !validConfigValue(a.HostProxy) {
+		errData = &errortypes.ErrorData{
+			Error:   "host_proxy_invalid",
+			Message: "Authority host proxy is invalid",
+		}
+		return
+	}
+
+	if !validConfigValue(a.ProxyHostname) {
+		errData = &errortypes.ErrorData{
+			Error:   "proxy_hostname_invalid",
+			Message: "Authority proxy hostname is invalid",
+		}
+		return
+	}
+
+	return
+}
+
+// Authority can be written to user ssh configurations
+func (a *Authority) ConfigSafe() bool {
+	return a.validateConfig() == nil
+}
+
+func (a *Authority) GetBastionCertAuthorities() (certAuthrs []string) {
+	certAuthrs = []string{}
+
 	bastionDomain := a.GetBastionDomain()
 	if bastionDomain == "" {
-		return ""
+		return
+	}
+
+	for _, pubKey := range a.TrustedPublicKeys() {
+		certAuthrs = append(certAuthrs, fmt.Sprintf(
+			"@cert-authority %s %s", bastionDomain, pubKey))
 	}
 
-	return fmt.Sprintf("@cert-authority %s %s", bastionDomain, a.PublicKey)
+	return
 }
 
 func (a *Authority) UserHasAccess(usr *user.User) bool {
@@ -326,8 +494,8 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 	return true
 }
 
-func (a *Authority) createCertificateLocal(
-	usr *user.User, sshPubKey string) (
+func (a *Authority) createCertificateLocal(usr *user.User,
+	sshPubKey, remoteAddr string, restrict *restriction) (
 	cert *ssh.Certificate, certMarshaled string, err error) {
 
 	privateKey, err := ParsePemKey(a.PrivateKey)
@@ -347,74 +515,80 @@ func (a *Authority) createCertificateLocal(
 	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
 	serial := serialHash.Sum64()
 
-	expire := a.Expire
-	if expire == 0 {
-		expire = 600
+	cert, err = a.newUserCertificate(usr, pubKey, remoteAddr, restrict)
+	if err != nil {
+		return
 	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
+	cert.Serial = serial
 
-	if len(usr.Roles) == 0 {
-		err = &errortypes.AuthenticationError{
-			errors.Wrap(err, "authority: User has no roles"),
-		}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
 		return
 	}
 
-	roles := usr.Roles
-	if a.JumpProxy() != "" {
-		hasBastion := false
+	err = cert.SignCert(rand.Reader, signer)
+	if err != nil {
+		return
+	}
 
-		for _, role := range roles {
-			if role == "bastion" {
-				hasBastion = true
-				break
-			}
-		}
+	certMarshaled = string(MarshalCertificate(cert, comment))
 
-		if !hasBastion {
-			roles = append(usr.Roles, "bastion")
-		}
-	}
+	return
+}
 
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		Serial:          serial,
-		CertType:        ssh.UserCert,
-		KeyId:           usr.Id.Hex(),
-		ValidPrincipals: roles,
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-		Permissions: ssh.Permissions{
-			Extensions: map[string]string{
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-port-forwarding":  "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			},
-		},
+// Signature algorithm requested from the HSM, empty when the HSM has not
+// reported the authority public key
+func (a *Authority) hsmSigAlg() string {
+	if a.PublicKey == "" {
+		return ""
 	}
 
-	signer, err := ssh.NewSignerFromKey(privateKey)
+	pubKey, err := ParseSshPubKey(a.PublicKey)
 	if err != nil {
+		return ""
+	}
+
+	return getSigAlg(pubKey)
+}
+
+// Verify the certificate returned by the HSM was signed by the authority
+// key for the requested public key
+func (a *Authority) verifyHsmCertificate(cert *ssh.Certificate,
+	pubKey ssh.PublicKey) (err error) {
+
+	if !bytes.Equal(cert.Key.Marshal(), pubKey.Marshal()) {
+		err = &errortypes.VerificationError{
+			errors.New("authority: HSM certificate key mismatch"),
+		}
 		return
 	}
 
-	err = cert.SignCert(rand.Reader, signer)
+	if a.PublicKey == "" {
+		return
+	}
+
+	authrPubKey, _, _, _, err := ssh.ParseAuthorizedKey(
+		[]byte(a.PublicKey))
 	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse ssh public key"),
+		}
 		return
 	}
 
-	certMarshaled = string(MarshalCertificate(cert, comment))
+	if !bytes.Equal(cert.SignatureKey.Marshal(), authrPubKey.Marshal()) {
+		err = &errortypes.VerificationError{
+			errors.New("authority: HSM certificate signature key mismatch"),
+		}
+		return
+	}
 
 	return
 }
 
 func (a *Authority) createCertificateHsm(db *database.Database,
-	usr *user.User, sshPubKey string) (cert *ssh.Certificate,
-	certMarshaled string, err error) {
+	usr *user.User, sshPubKey, remoteAddr string, restrict *restriction) (
+	cert *ssh.Certificate, certMarshaled string, err error) {
 
 	pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshPubKey))
 	if err != nil {
@@ -424,63 +598,61 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 		return
 	}
 
-	expire := a.Expire
-	if expire == 0 {
-		expire = 600
+	cert, err = a.newUserCertificate(usr, pubKey, remoteAddr, restrict)
+	if err != nil {
+		return
 	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
 
-	if len(usr.Roles) == 0 {
-		err = &errortypes.AuthenticationError{
-			errors.Wrap(err, "authority: User has no roles"),
-		}
+	cert, certMarshaled, err = a.signCertificateHsm(
+		db, cert, pubKey, comment)
+	if err != nil {
 		return
 	}
 
-	roles := usr.Roles
-	if a.JumpProxy() != "" {
-		hasBastion := false
+	return
+}
 
-		for _, role := range roles {
-			if role == "bastion" {
-				hasBastion = true
-				break
-			}
-		}
+// Sign the certificate with the HSM agents, each agent is tried in turn
+// until one returns a signed certificate
+func (a *Authority) signCertificateHsm(db *database.Database,
+	unsigned *ssh.Certificate, pubKey ssh.PublicKey, comment string) (
+	cert *ssh.Certificate, certMarshaled string, err error) {
 
-		if !hasBastion {
-			roles = append(usr.Roles, "bastion")
-		}
+	certData, err := utils.MarshalSshCertificate(unsigned)
+	if err != nil {
+		return
 	}
 
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		CertType:        ssh.UserCert,
-		KeyId:           usr.Id.Hex(),
-		ValidPrincipals: roles,
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-		Permissions: ssh.Permissions{
-			Extensions: map[string]string{
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-port-forwarding":  "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			},
-		},
-	}
+	for _, agnt := range a.hsmSigners() {
+		crt, crtMarshaled, e := a.signCertificateHsmAgent(
+			db, agnt, certData, pubKey, comment)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"authority_id": a.Id.Hex(),
+				"hsm_agent":    agnt.Name,
+				"hsm_serial":   agnt.Serial,
+				"error":        e,
+			}).Error("authority: Error getting hsm certificate")
+			continue
+		}
 
-	certData, err := utils.MarshalSshCertificate(cert)
-	if err != nil {
+		cert = crt
+		certMarshaled = crtMarshaled
 		return
 	}
 
+	return
+}
+
+func (a *Authority) signCertificateHsmAgent(db *database.Database,
+	agnt *HsmAgent, certData []byte, pubKey ssh.PublicKey,
+	comment string) (cert *ssh.Certificate, certMarshaled string,
+	err error) {
+
 	data := SshRequest{
-		Serial:      a.HsmSerial,
-		Certificate: certData,
+		Serial:             agnt.Serial,
+		SignatureAlgorithm: a.hsmSigAlg(),
+		Certificate:        certData,
 	}
 
 	cipData, err := json.Marshal(data)
@@ -497,7 +669,7 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 	}
 
 	encKeyHash := sha256.New()
-	encKeyHash.Write([]byte(a.HsmSecret))
+	encKeyHash.Write([]byte(agnt.Secret))
 	cipKey := encKeyHash.Sum(nil)
 
 	cipIv, err := utils.RandBytes(aes.BlockSize)
@@ -516,7 +688,7 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 	mode := cipher.NewCBCEncrypter(block, cipIv)
 	mode.CryptBlocks(cipData, cipData)
 
-	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
+	hashFunc := hmac.New(sha512.New, []byte(agnt.Secret))
 	hashFunc.Write(cipData)
 	rawSignature := hashFunc.Sum(nil)
 	sig := base64.StdEncoding.EncodeToString(rawSignature)
@@ -524,7 +696,7 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 	payloadId := primitive.NewObjectID().Hex()
 	payload := &HsmPayload{
 		Id:        payloadId,
-		Token:     a.HsmToken,
+		Token:     agnt.Token,
 		Iv:        cipIv,
 		Signature: sig,
 		Type:      "ssh_certificate",
@@ -588,7 +760,7 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 				}
 
 				payloadData, e := UnmarshalPayload(
-					a.HsmToken, a.HsmSecret, msg.Data)
+					agnt.Token, agnt.Secret, msg.Data)
 				if e != nil {
 					eventErr = e
 					return false
@@ -614,6 +786,12 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 					return false
 				}
 
+				e = a.verifyHsmCertificate(cert, pubKey)
+				if e != nil {
+					eventErr = e
+					return false
+				}
+
 				certMarshaled = string(MarshalCertificate(cert, comment))
 
 				return false
@@ -629,9 +807,7 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 	if eventErr != nil {
 		cert = nil
 		certMarshaled = ""
-		logrus.WithFields(logrus.Fields{
-			"error": eventErr,
-		}).Error("authority: Error getting hsm certificate")
+		err = eventErr
 		return
 	}
 
@@ -639,13 +815,15 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 }
 
 func (a *Authority) CreateCertificate(db *database.Database, usr *user.User,
-	sshPubKey string) (cert *ssh.Certificate, certMarshaled string,
-	err error) {
+	sshPubKey, remoteAddr string) (cert *ssh.Certificate,
+	certMarshaled string, err error) {
 
 	if a.Type == PritunlHsm {
-		cert, certMarshaled, err = a.createCertificateHsm(db, usr, sshPubKey)
+		cert, certMarshaled, err = a.createCertificateHsm(
+			db, usr, sshPubKey, remoteAddr, nil)
 	} else {
-		cert, certMarshaled, err = a.createCertificateLocal(usr, sshPubKey)
+		cert, certMarshaled, err = a.createCertificateLocal(
+			usr, sshPubKey, remoteAddr, nil)
 	}
 
 	return
@@ -672,23 +850,8 @@ func (a *Authority) createHostCertificate(
 	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
 	serial := serialHash.Sum64()
 
-	expire := a.HostExpire
-	if expire == 0 {
-		expire = 600
-	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
-
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		Serial:          serial,
-		CertType:        ssh.HostCert,
-		KeyId:           hostname,
-		ValidPrincipals: []string{domain},
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-	}
+	cert = a.newHostCertificate(hostname, domain, pubKey)
+	cert.Serial = serial
 
 	signer, err := ssh.NewSignerFromKey(privateKey)
 	if err != nil {
@@ -717,185 +880,14 @@ func (a *Authority) createHostCertificateHsm(db *database.Database,
 		return
 	}
 
-	expire := a.HostExpire
-	if expire == 0 {
-		expire = 600
-	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
-
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		CertType:        ssh.HostCert,
-		KeyId:           hostname,
-		ValidPrincipals: []string{domain},
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-	}
-
-	certData, err := utils.MarshalSshCertificate(cert)
-	if err != nil {
-		return
-	}
-
-	data := SshRequest{
-		Serial:      a.HsmSerial,
-		Certificate: certData,
-	}
-
-	cipData, err := json.Marshal(data)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to marshal certificate"),
-		}
-		return
-	}
-
-	pad := 16 - len(cipData)%16
-	for i := 0; i < pad; i++ {
-		cipData = append(cipData, 0)
-	}
-
-	encKeyHash := sha256.New()
-	encKeyHash.Write([]byte(a.HsmSecret))
-	cipKey := encKeyHash.Sum(nil)
-
-	cipIv, err := utils.RandBytes(aes.BlockSize)
-	if err != nil {
-		return
-	}
-
-	block, err := aes.NewCipher(cipKey)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to load cipher"),
-		}
-		return
-	}
-
-	mode := cipher.NewCBCEncrypter(block, cipIv)
-	mode.CryptBlocks(cipData, cipData)
-
-	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
-	hashFunc.Write(cipData)
-	rawSignature := hashFunc.Sum(nil)
-	sig := base64.StdEncoding.EncodeToString(rawSignature)
-
-	payloadId := primitive.NewObjectID().Hex()
-	payload := &HsmPayload{
-		Id:        payloadId,
-		Token:     a.HsmToken,
-		Iv:        cipIv,
-		Signature: sig,
-		Type:      "ssh_certificate",
-		Data:      cipData,
-	}
-
-	waiter := sync.WaitGroup{}
-	waiter.Add(1)
-
-	timeout := time.Duration(settings.System.HsmResponseTimeout) * time.Second
-	start := time.Now()
-	var eventErr error
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logrus.WithFields(logrus.Fields{
-					"error": errors.New(fmt.Sprintf("%s", r)),
-				}).Error("authority: Parse hsm panic")
+	cert = a.newHostCertificate(hostname, domain, pubKey)
 
-				eventErr = &errortypes.UnknownError{
-					errors.New("authority: Parse hsm panic"),
-				}
-			}
-			waiter.Done()
-		}()
-
-		event.SubscribeType([]string{"pritunl_hsm_recv"}, 5*time.Second,
-			func() event.CustomEvent {
-				return &HsmEvent{}
-			},
-			func(msgInf event.CustomEvent, e error) bool {
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				if msgInf == nil || msgInf.GetData() == nil {
-					if time.Since(start) < timeout {
-						return true
-					}
-
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				msg := msgInf.(*HsmEvent)
-
-				if msg.Data.Id != payloadId ||
-					msg.Data.Type != "ssh_certificate" {
-
-					if time.Since(start) < timeout {
-						return true
-					}
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				payloadData, e := UnmarshalPayload(
-					a.HsmToken, a.HsmSecret, msg.Data)
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				respData := &SshResponse{}
-				e = json.Unmarshal(payloadData, respData)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				cert, e = utils.UnmarshalSshCertificate(
-					respData.Certificate)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				certMarshaled = string(MarshalCertificate(cert, comment))
-
-				return false
-			})
-	}()
-
-	err = event.Publish(db, "pritunl_hsm_send", payload)
+	cert, certMarshaled, err = a.signCertificateHsm(
+		db, cert, pubKey, comment)
 	if err != nil {
 		return
 	}
 
-	waiter.Wait()
-	if eventErr != nil {
-		cert = nil
-		certMarshaled = ""
-		logrus.WithFields(logrus.Fields{
-			"error": eventErr,
-		}).Error("authority: Error getting hsm certificate")
-		return
-	}
-
 	return
 }
 
@@ -1153,8 +1145,16 @@ func (a *Authority) TokenDelete(token string) (err error) {
 func (a *Authority) HandleHsmStatus(db *database.Database,
 	payload *HsmPayload) (err error) {
 
+	agnt := a.GetHsmAgent(payload.Token)
+	if agnt == nil {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: Unknown hsm agent token"),
+		}
+		return
+	}
+
 	payloadData, err := UnmarshalPayload(
-		a.HsmToken, a.HsmSecret, payload)
+		agnt.Token, agnt.Secret, payload)
 	if err != nil {
 		return
 	}
@@ -1169,28 +1169,69 @@ func (a *Authority) HandleHsmStatus(db *database.Database,
 	}
 
 	sendEvent := false
-	fields := set.NewSet("hsm_timestamp", "hsm_status")
-	a.HsmTimestamp = time.Now()
 
 	status := Disconnected
 	if respData.Status == "online" {
 		status = Connected
 	}
 
-	if a.HsmStatus != status {
+	if agnt.Status != status {
 		sendEvent = true
 	}
-	a.HsmStatus = status
+	agnt.Status = status
+	agnt.Timestamp = time.Now()
+	agnt.Alerted = false
+
+	err = a.commitHsmAgent(db, agnt)
+	if err != nil {
+		return
+	}
+
+	// Additional agents must hold the same key as the primary agent
+	if !agnt.Id.IsZero() && a.PublicKey != "" &&
+		respData.SshPublicKey != "" &&
+		a.PublicKey != respData.SshPublicKey {
+
+		logrus.WithFields(logrus.Fields{
+			"authority_id": a.Id.Hex(),
+			"hsm_agent":    agnt.Name,
+			"hsm_serial":   agnt.Serial,
+		}).Error("authority: HSM agent public key does not match authority")
+
+		agnt.Status = Disconnected
+		err = a.commitHsmAgent(db, agnt)
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "authority.change")
+
+		return
+	}
+
+	fields := set.NewSet()
+	if a.PublicKey != respData.SshPublicKey &&
+		(agnt.Id.IsZero() || a.PublicKey == "") {
 
-	if a.PublicKey != respData.SshPublicKey {
 		sendEvent = true
 		fields.Add("public_key")
 		a.PublicKey = respData.SshPublicKey
+
+		if a.PublicKey != "" {
+			err = a.SetPublicKeyPem()
+			if err != nil {
+				return
+			}
+			fields.Add("public_key_pem")
+			fields.Add("info")
+		}
 	}
 
-	err = a.CommitFields(db, fields)
-	if err != nil {
-		return
+	if fields.Len() > 0 {
+		err = a.CommitFields(db, fields)
+		if err != nil {
+			return
+		}
 	}
 
 	if sendEvent {
@@ -1221,7 +1262,8 @@ func (a *Authority) ValidateHsmSignature(
 
 	timestamp := time.Unix(timestampInt, 0)
 
-	if token == "" || token != a.HsmToken {
+	agnt := a.GetHsmAgent(token)
+	if agnt == nil {
 		err = &errortypes.AuthenticationError{
 			errors.New("authority: Invalid authentication token"),
 		}
@@ -1245,7 +1287,7 @@ func (a *Authority) ValidateHsmSignature(
 	}
 
 	authString := strings.Join([]string{
-		a.HsmToken,
+		agnt.Token,
 		strconv.FormatInt(timestamp.Unix(), 10),
 		nonc,
 		method,
@@ -1257,7 +1299,7 @@ func (a *Authority) ValidateHsmSignature(
 		return
 	}
 
-	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
+	hashFunc := hmac.New(sha512.New, []byte(agnt.Secret))
 	hashFunc.Write([]byte(authString))
 	rawSignature := hashFunc.Sum(nil)
 	testSig := base64.StdEncoding.EncodeToString(rawSignature)
@@ -1281,6 +1323,29 @@ func (a *Authority) Export(passphrase string) (encKey string, err error) {
 		return
 	}
 
+	// Ed25519 keys are exported in the OpenSSH format which is not
+	// supported by legacy PEM encryption
+	if block.Type == "PRIVATE KEY" {
+		privateKey, e := ParsePemKey(a.PrivateKey)
+		if e != nil {
+			err = e
+			return
+		}
+
+		encBlock, e := ssh.MarshalPrivateKeyWithPassphrase(
+			privateKey, "", []byte(passphrase))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "authority: Failed to encrypt private key"),
+			}
+			return
+		}
+
+		encKey = string(pem.EncodeToMemory(encBlock))
+
+		return
+	}
+
 	encBlock, err := x509.EncryptPEMBlock(
 		rand.Reader,
 		block.Type,
@@ -1348,12 +1413,57 @@ func (a *Authority) GetMatches() (matches []string, err error) {
 	return
 }
 
+// Check if the host is within the authority host domain, matches or subnets,
+// all hosts are permitted when none are configured
+func (a *Authority) HostPermitted(host string) bool {
+	if a.HostDomain == "" && len(a.HostMatches) == 0 &&
+		len(a.HostSubnets) == 0 {
+
+		return true
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if a.HostDomain != "" && strings.HasSuffix(
+		host, "."+strings.ToLower(a.HostDomain)) &&
+		host != strings.ToLower(a.GetBastionDomain()) {
+
+		return true
+	}
+
+	for _, match := range a.HostMatches {
+		if utils.Match(strings.ToLower(match), host) {
+			return true
+		}
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	if ip != nil {
+		for _, hostSubnet := range a.HostSubnets {
+			_, subnet, err := net.ParseCIDR(hostSubnet)
+			if err != nil {
+				continue
+			}
+
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func (a *Authority) SetPublicKeyPem() (err error) {
 	pubKey, err := ParseSshPubKey(a.PublicKey)
 	if err != nil {
 		return
 	}
 
+	a.Info = &Info{
+		KeyAlg: getKeyAlg(pubKey),
+	}
+
 	switch pubKey := pubKey.(type) {
 	case *rsa.PublicKey:
 		keyBytes := x509.MarshalPKCS1PublicKey(pubKey)
@@ -1382,6 +1492,23 @@ func (a *Authority) SetPublicKeyPem() (err error) {
 
 		a.PublicKeyPem = strings.TrimSpace(string(pem.EncodeToMemory(block)))
 
+		break
+	case ed25519.PublicKey:
+		keyBytes, e := x509.MarshalPKIXPublicKey(pubKey)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "authority: Failed to parse public key"),
+			}
+			return
+		}
+
+		block := &pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: keyBytes,
+		}
+
+		a.PublicKeyPem = strings.TrimSpace(string(pem.EncodeToMemory(block)))
+
 		break
 	}
 
@@ -1407,6 +1534,15 @@ func (a *Authority) Validate(db *database.Database) (
 		a.HostSubnets = []string{}
 	}
 
+	if a.HsmAgents == nil {
+		a.HsmAgents = []*HsmAgent{}
+	}
+
+	errData = a.validateConfig()
+	if errData != nil {
+		return
+	}
+
 	switch a.Type {
 	case Local:
 		a.HsmToken = ""
@@ -1423,6 +1559,7 @@ func (a *Authority) Validate(db *database.Database) (
 		break
 	case PritunlHsm:
 		a.PrivateKey = ""
+		a.clearRotation()
 
 		if a.HsmSerial == "" {
 			errData = &errortypes.ErrorData{
@@ -1476,6 +1613,37 @@ func (a *Authority) Validate(db *database.Database) (
 		a.HostExpire = 15
 	}
 
+	if a.ApproverRoles == nil {
+		a.ApproverRoles = []string{}
+	}
+
+	if a.RequireApproval && len(a.ApproverRoles) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "approver_roles_required",
+			Message: "Approver roles are required for approval",
+		}
+		return
+	}
+
+	if !a.ServiceCertificates {
+		a.ServiceExpire = 0
+	} else if a.ServiceExpire < 1 {
+		a.ServiceExpire = DefaultServiceExpire
+	} else if a.ServiceExpire > a.Expire {
+		a.ServiceExpire = a.Expire
+	}
+
+	if a.RotationGrace < 1 {
+		a.RotationGrace = DefaultRotationGrace
+	}
+
+	// Retired keys must remain trusted until all certificates signed by
+	// the key have expired
+	minRetire := (utils.Max(a.Expire, a.HostExpire) + 59) / 60
+	if a.RotationRetire < minRetire {
+		a.RotationRetire = minRetire
+	}
+
 	if a.HostDomain == "" && len(a.HostSubnets) == 0 &&
 		len(a.HostMatches) == 0 {
 
@@ -1490,6 +1658,7 @@ func (a *Authority) Validate(db *database.Database) (
 	if !a.ProxyHosting {
 		a.ProxyPort = 0
 		a.ProxyHostname = ""
+		a.ProxyRecording = false
 	} else {
 		a.HostProxy = ""
 		if a.ProxyHostname == "" {
@@ -1526,6 +1695,11 @@ func (a *Authority) Validate(db *database.Database) (
 		a.HostTokens = []string{}
 	}
 
+	errData = a.validateTemplates()
+	if errData != nil {
+		return
+	}
+
 	for _, hostSubnet := range a.HostSubnets {
 		_, e := parseSubnetMatch(hostSubnet)
 		if e != nil {
@@ -1563,9 +1737,15 @@ func (a *Authority) Format() {
 
 func (a *Authority) Json() {
 	if a.Type == PritunlHsm {
-		if time.Since(a.HsmTimestamp) > 45*time.Second {
+		if time.Since(a.HsmTimestamp) > HsmHeartbeatTimeout {
 			a.HsmStatus = Disconnected
 		}
+
+		for _, agnt := range a.HsmAgents {
+			if !agnt.Online() {
+				agnt.Status = Disconnected
+			}
+		}
 	}
 
 	a.ProxyJump = a.JumpProxy()
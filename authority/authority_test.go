@@ -0,0 +1,124 @@
+package authority
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/user"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEd25519Certificate(t *testing.T) {
+	authr := &Authority{
+		Id: primitive.NewObjectID(),
+	}
+
+	err := authr.GeneratePrivateKey(Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authr.Info == nil || authr.Info.KeyAlg != "ED25519" {
+		t.Error("Authority key algorithm not set")
+	}
+
+	authrKey, _, _, _, err := ssh.ParseAuthorizedKey(
+		[]byte(authr.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authrKey.Type() != ssh.KeyAlgoED25519 {
+		t.Fatalf("Unexpected authority key type %s", authrKey.Type())
+	}
+
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userSigner, err := ssh.NewSignerFromKey(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey := strings.TrimSpace(string(
+		ssh.MarshalAuthorizedKey(userSigner.PublicKey()))) + " test"
+
+	usr := &user.User{
+		Id:       primitive.NewObjectID(),
+		Username: "test",
+		Roles:    []string{"admin"},
+	}
+
+	_, certStr, err := authr.createCertificateLocal(usr, pubKey, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if comment != "test" {
+		t.Errorf("Unexpected certificate comment %q", comment)
+	}
+
+	cert, ok := certKey.(*ssh.Certificate)
+	if !ok {
+		t.Fatal("Signed key is not a certificate")
+	}
+
+	if cert.Signature.Format != ssh.KeyAlgoED25519 {
+		t.Errorf("Unexpected signature format %s", cert.Signature.Format)
+	}
+
+	if cert.KeyId != usr.Id.Hex() {
+		t.Errorf("Unexpected certificate key id %s", cert.KeyId)
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(authrKey.Marshal())
+		},
+	}
+
+	err = checker.CheckCert("admin", cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !checker.IsUserAuthority(cert.SignatureKey) {
+		t.Error("Certificate not signed by authority")
+	}
+
+	err = checker.CheckCert("root", cert)
+	if err == nil {
+		t.Error("Certificate valid for principal not in roles")
+	}
+
+	err = authr.verifyHsmCertificate(cert, userSigner.PublicKey())
+	if err != nil {
+		t.Error(err)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, userSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("test")
+	sig, err := certSigner.Sign(rand.Reader, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cert.Key.Verify(data, sig)
+	if err != nil {
+		t.Error(err)
+	}
+}
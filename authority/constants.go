@@ -1,8 +1,32 @@
 package authority
 
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
 	Local        = "local"
 	PritunlHsm   = "pritunl_hsm"
 	Connected    = "connected"
 	Disconnected = "disconnected"
+
+	Rsa4096 = "rsa4096"
+	EcP384  = "ecp384"
+	Ed25519 = "ed25519"
+
+	RotationStaged   = "staged"
+	RotationPromoted = "promoted"
+
+	DefaultRotationGrace = 24
+	DefaultServiceExpire = 60
+
+	HsmHeartbeatTimeout = 45 * time.Second
+)
+
+var KeyAlgorithms = set.NewSet(
+	Rsa4096,
+	EcP384,
+	Ed25519,
 )
@@ -17,8 +17,9 @@ import (
 )
 
 type SshRequest struct {
-	Serial      string `json:"serial"`
-	Certificate []byte `json:"certificate"`
+	Serial             string `json:"serial"`
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Certificate        []byte `json:"certificate"`
 }
 
 type SshResponse struct {
@@ -0,0 +1,297 @@
+package authority
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+var hsmCounter uint64
+
+// Additional HSM agent holding a copy of the authority key, the primary
+// agent is configured with the hsm fields of the authority
+type HsmAgent struct {
+	Id        primitive.ObjectID `bson:"id" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Token     string             `bson:"token" json:"token"`
+	Secret    string             `bson:"secret" json:"-"`
+	Serial    string             `bson:"serial" json:"serial"`
+	Status    string             `bson:"status" json:"status"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	Alerted   bool               `bson:"alerted" json:"-"`
+}
+
+func (h *HsmAgent) Online() bool {
+	return h.Status == Connected &&
+		time.Since(h.Timestamp) <= HsmHeartbeatTimeout
+}
+
+func (a *Authority) primaryHsmAgent() *HsmAgent {
+	return &HsmAgent{
+		Name:      "primary",
+		Token:     a.HsmToken,
+		Secret:    a.HsmSecret,
+		Serial:    a.HsmSerial,
+		Status:    a.HsmStatus,
+		Timestamp: a.HsmTimestamp,
+		Alerted:   a.HsmAlerted,
+	}
+}
+
+// Get the primary and additional HSM agents
+func (a *Authority) GetHsmAgents() (agnts []*HsmAgent) {
+	agnts = []*HsmAgent{}
+
+	if a.HsmToken != "" {
+		agnts = append(agnts, a.primaryHsmAgent())
+	}
+
+	for _, agnt := range a.HsmAgents {
+		if agnt.Token != "" {
+			agnts = append(agnts, agnt)
+		}
+	}
+
+	return
+}
+
+func (a *Authority) GetHsmAgent(token string) *HsmAgent {
+	if token == "" {
+		return nil
+	}
+
+	for _, agnt := range a.GetHsmAgents() {
+		if agnt.Token == token {
+			return agnt
+		}
+	}
+
+	return nil
+}
+
+// Agents to send a signing request to in order, online agents are
+// rotated between requests. Agents without a recent heartbeat are skipped
+// to avoid waiting for the response timeout of each, when no agent is
+// online only the most recently seen agent is used
+func (a *Authority) hsmSigners() (agnts []*HsmAgent) {
+	agnts = []*HsmAgent{}
+	var lastAgnt *HsmAgent
+
+	for _, agnt := range a.GetHsmAgents() {
+		if agnt.Online() {
+			agnts = append(agnts, agnt)
+		}
+
+		if lastAgnt == nil || agnt.Timestamp.After(lastAgnt.Timestamp) {
+			lastAgnt = agnt
+		}
+	}
+
+	if len(agnts) == 0 && lastAgnt != nil {
+		agnts = append(agnts, lastAgnt)
+	}
+
+	if len(agnts) > 1 {
+		offset := int(atomic.AddUint64(&hsmCounter, 1) % uint64(len(agnts)))
+		agnts = append(agnts[offset:], agnts[:offset]...)
+	}
+
+	return
+}
+
+// Store the agent status, the primary agent is stored on the authority
+func (a *Authority) commitHsmAgent(db *database.Database,
+	agnt *HsmAgent) (err error) {
+
+	if agnt.Id.IsZero() {
+		a.HsmStatus = agnt.Status
+		a.HsmTimestamp = agnt.Timestamp
+		a.HsmAlerted = agnt.Alerted
+
+		err = a.CommitFields(db, set.NewSet(
+			"hsm_status", "hsm_timestamp", "hsm_alerted"))
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	coll := db.Authorities()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":           a.Id,
+		"hsm_agents.id": agnt.Id,
+	}, &bson.M{
+		"$set": &bson.M{
+			"hsm_agents.$.status":    agnt.Status,
+			"hsm_agents.$.timestamp": agnt.Timestamp,
+			"hsm_agents.$.alerted":   agnt.Alerted,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Update the status of the agent with the token, a connected status
+// records a heartbeat
+func (a *Authority) SetHsmAgentStatus(db *database.Database,
+	token, status string) (agnt *HsmAgent, err error) {
+
+	agnt = a.GetHsmAgent(token)
+	if agnt == nil {
+		return
+	}
+
+	agnt.Status = status
+	if status == Connected {
+		agnt.Timestamp = time.Now()
+		agnt.Alerted = false
+	}
+
+	err = a.commitHsmAgent(db, agnt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Authority) NewHsmAgent(db *database.Database,
+	name, serial string) (agnt *HsmAgent, err error) {
+
+	agnt = &HsmAgent{
+		Id:     primitive.NewObjectID(),
+		Name:   name,
+		Serial: serial,
+		Status: Disconnected,
+	}
+
+	agnt.Token, err = utils.RandStr(32)
+	if err != nil {
+		return
+	}
+
+	agnt.Secret, err = utils.RandStr(64)
+	if err != nil {
+		return
+	}
+
+	coll := db.Authorities()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": a.Id,
+	}, &bson.M{
+		"$push": &bson.M{
+			"hsm_agents": agnt,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	a.HsmAgents = append(a.HsmAgents, agnt)
+
+	return
+}
+
+func (a *Authority) RemoveHsmAgent(db *database.Database,
+	agntId primitive.ObjectID) (err error) {
+
+	coll := db.Authorities()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": a.Id,
+	}, &bson.M{
+		"$pull": &bson.M{
+			"hsm_agents": &bson.M{
+				"id": agntId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	agnts := []*HsmAgent{}
+	for _, agnt := range a.HsmAgents {
+		if agnt.Id != agntId {
+			agnts = append(agnts, agnt)
+		}
+	}
+	a.HsmAgents = agnts
+
+	return
+}
+
+// Alert once for each HSM agent that stopped sending heartbeats
+func HsmCheck(db *database.Database) (err error) {
+	authrs, err := GetAll(db)
+	if err != nil {
+		return
+	}
+
+	changed := false
+
+	for _, authr := range authrs {
+		if authr.Type != PritunlHsm {
+			continue
+		}
+
+		for _, agnt := range authr.GetHsmAgents() {
+			if agnt.Alerted || agnt.Timestamp.IsZero() ||
+				time.Since(agnt.Timestamp) <= HsmHeartbeatTimeout {
+
+				continue
+			}
+
+			agnt.Status = Disconnected
+			agnt.Alerted = true
+
+			err = authr.commitHsmAgent(db, agnt)
+			if err != nil {
+				return
+			}
+			changed = true
+
+			logrus.WithFields(logrus.Fields{
+				"authority_id":   authr.Id.Hex(),
+				"authority_name": authr.Name,
+				"hsm_agent":      agnt.Name,
+				"hsm_serial":     agnt.Serial,
+				"last_heartbeat": agnt.Timestamp,
+			}).Error("authority: HSM agent stopped sending heartbeats")
+
+			err = audit.NewSystem(db, audit.HsmAgentOffline, audit.Fields{
+				"authority_id":   authr.Id.Hex(),
+				"authority_name": authr.Name,
+				"hsm_agent":      agnt.Name,
+				"hsm_serial":     agnt.Serial,
+				"last_heartbeat": agnt.Timestamp,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "authority.change")
+	}
+
+	return
+}
@@ -0,0 +1,66 @@
+package authority
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+func TestHsmSigners(t *testing.T) {
+	now := time.Now()
+
+	authr := &Authority{
+		HsmToken:     "primary",
+		HsmStatus:    Connected,
+		HsmTimestamp: now.Add(-5 * time.Minute),
+		HsmAgents: []*HsmAgent{
+			&HsmAgent{
+				Id:        primitive.NewObjectID(),
+				Token:     "online1",
+				Status:    Connected,
+				Timestamp: now,
+			},
+			&HsmAgent{
+				Id:        primitive.NewObjectID(),
+				Token:     "offline",
+				Status:    Disconnected,
+				Timestamp: now,
+			},
+			&HsmAgent{
+				Id:        primitive.NewObjectID(),
+				Token:     "online2",
+				Status:    Connected,
+				Timestamp: now.Add(-10 * time.Second),
+			},
+		},
+	}
+
+	for i := 0; i < 4; i++ {
+		agnts := authr.hsmSigners()
+		if len(agnts) != 2 {
+			t.Fatalf("Expected 2 online agents got %d", len(agnts))
+		}
+
+		for _, agnt := range agnts {
+			if agnt.Token != "online1" && agnt.Token != "online2" {
+				t.Errorf("Offline agent %s used", agnt.Token)
+			}
+		}
+	}
+
+	for _, agnt := range authr.HsmAgents {
+		agnt.Timestamp = now.Add(-10 * time.Minute)
+	}
+	authr.HsmAgents[1].Timestamp = now.Add(-2 * time.Minute)
+
+	agnts := authr.hsmSigners()
+	if len(agnts) != 1 || agnts[0].Token != "offline" {
+		t.Errorf("Expected most recent agent when none online")
+	}
+
+	authr = &Authority{}
+	if len(authr.hsmSigners()) != 0 {
+		t.Error("Unexpected agents for authority without agents")
+	}
+}
@@ -0,0 +1,249 @@
+package authority
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+)
+
+var rotationFields = set.NewSet(
+	"private_key",
+	"public_key",
+	"public_key_pem",
+	"root_certificate",
+	"info",
+	"next_private_key",
+	"next_public_key",
+	"previous_public_key",
+	"rotation_status",
+	"rotation_timestamp",
+)
+
+// Public keys trusted for the authority, includes the staged key and the
+// previous key during a rotation
+func (a *Authority) TrustedPublicKeys() (pubKeys []string) {
+	pubKeys = []string{}
+
+	for _, pubKey := range []string{
+		a.PublicKey,
+		a.NextPublicKey,
+		a.PreviousPublicKey,
+	} {
+		pubKey = strings.TrimSpace(pubKey)
+		if pubKey != "" {
+			pubKeys = append(pubKeys, pubKey)
+		}
+	}
+
+	return
+}
+
+func (a *Authority) keyAlgorithm() (algorithm string, err error) {
+	privateKey, err := ParsePemKey(a.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	switch privateKey.(type) {
+	case *rsa.PrivateKey:
+		algorithm = Rsa4096
+		break
+	case *ecdsa.PrivateKey:
+		algorithm = EcP384
+		break
+	case ed25519.PrivateKey:
+		algorithm = Ed25519
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.New("authority: Unknown private key type"),
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Authority) clearRotation() {
+	a.NextPrivateKey = ""
+	a.NextPublicKey = ""
+	a.PreviousPublicKey = ""
+	a.RotationStatus = ""
+	a.RotationTimestamp = time.Time{}
+}
+
+// Stage a new key with the same algorithm as the current key, the staged
+// key is published but not used for signing until the grace period ends
+func (a *Authority) RotateStart() (errData *errortypes.ErrorData,
+	err error) {
+
+	if a.Type != Local {
+		errData = &errortypes.ErrorData{
+			Error:   "rotation_unsupported",
+			Message: "Key rotation is only supported on local authorities",
+		}
+		return
+	}
+
+	if a.RotationStatus != "" {
+		errData = &errortypes.ErrorData{
+			Error:   "rotation_active",
+			Message: "Key rotation already in progress",
+		}
+		return
+	}
+
+	algorithm, err := a.keyAlgorithm()
+	if err != nil {
+		return
+	}
+
+	var privKeyBytes, pubKeyBytes []byte
+	switch algorithm {
+	case EcP384:
+		privKeyBytes, pubKeyBytes, err = GenerateEcKey()
+		break
+	case Ed25519:
+		privKeyBytes, pubKeyBytes, err = GenerateEd25519Key()
+		break
+	default:
+		privKeyBytes, pubKeyBytes, err = GenerateRsaKey()
+	}
+	if err != nil {
+		return
+	}
+
+	a.NextPrivateKey = strings.TrimSpace(string(privKeyBytes))
+	a.NextPublicKey = strings.TrimSpace(string(pubKeyBytes))
+	a.RotationStatus = RotationStaged
+	a.RotationTimestamp = time.Now()
+
+	return
+}
+
+// Cancel a staged rotation, a promoted rotation can not be canceled
+func (a *Authority) RotateCancel() (errData *errortypes.ErrorData) {
+	if a.RotationStatus != RotationStaged {
+		errData = &errortypes.ErrorData{
+			Error:   "rotation_not_staged",
+			Message: "No staged key rotation to cancel",
+		}
+		return
+	}
+
+	a.clearRotation()
+
+	return
+}
+
+func (a *Authority) rotatePromote(db *database.Database) (err error) {
+	previousPublicKey := a.PublicKey
+
+	a.PrivateKey = a.NextPrivateKey
+	a.PublicKey = a.NextPublicKey
+	a.PublicKeyPem = ""
+	a.RootCertificate = ""
+
+	err = a.SetPublicKeyPem()
+	if err != nil {
+		return
+	}
+
+	err = a.CreateRootCertificate(db)
+	if err != nil {
+		return
+	}
+
+	a.NextPrivateKey = ""
+	a.NextPublicKey = ""
+	a.PreviousPublicKey = previousPublicKey
+	a.RotationStatus = RotationPromoted
+	a.RotationTimestamp = time.Now()
+
+	return
+}
+
+func (a *Authority) rotateRetire() {
+	a.clearRotation()
+}
+
+// Advance key rotations, staged keys are promoted to signing after the
+// grace period and previous keys are retired after the retire period
+func RotateCheck(db *database.Database) (err error) {
+	authrs, err := GetAll(db)
+	if err != nil {
+		return
+	}
+
+	changed := false
+
+	for _, authr := range authrs {
+		typ := ""
+
+		switch authr.RotationStatus {
+		case RotationStaged:
+			if time.Since(authr.RotationTimestamp) < time.Duration(
+				authr.RotationGrace)*time.Hour {
+
+				continue
+			}
+
+			err = authr.rotatePromote(db)
+			if err != nil {
+				return
+			}
+			typ = audit.AuthorityRotatePromote
+
+			break
+		case RotationPromoted:
+			if time.Since(authr.RotationTimestamp) < time.Duration(
+				authr.RotationRetire)*time.Hour {
+
+				continue
+			}
+
+			authr.rotateRetire()
+			typ = audit.AuthorityRotateRetire
+
+			break
+		default:
+			continue
+		}
+
+		err = authr.CommitFields(db, rotationFields)
+		if err != nil {
+			return
+		}
+		changed = true
+
+		logrus.WithFields(logrus.Fields{
+			"authority_id": authr.Id.Hex(),
+			"action":       typ,
+		}).Info("authority: Key rotation advanced")
+
+		err = audit.NewSystem(db, typ, audit.Fields{
+			"authority_id":   authr.Id.Hex(),
+			"authority_name": authr.Name,
+			"public_key":     authr.PublicKey,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "authority.change")
+	}
+
+	return
+}
@@ -0,0 +1,80 @@
+package authority
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/user"
+	"golang.org/x/crypto/ssh"
+)
+
+// Limits requested for a service certificate
+type restriction struct {
+	Principals []string
+	Expire     int
+}
+
+// Filter the permitted principals to the requested principals, all
+// permitted principals are used when none are requested
+func (r *restriction) principals(permitted []string) (
+	principals []string, err error) {
+
+	if len(r.Principals) == 0 {
+		principals = permitted
+		return
+	}
+
+	permittedSet := set.NewSet()
+	for _, principal := range permitted {
+		permittedSet.Add(principal)
+	}
+
+	principals = []string{}
+	for _, principal := range r.Principals {
+		if !permittedSet.Contains(principal) {
+			err = &errortypes.AuthenticationError{
+				errors.Newf("authority: Principal '%s' not permitted",
+					principal),
+			}
+			return
+		}
+
+		principals = append(principals, principal)
+	}
+
+	return
+}
+
+// Create a user certificate without a challenge for service accounts, the
+// expire in minutes is limited by the authority service expire
+func (a *Authority) CreateServiceCertificate(db *database.Database,
+	usr *user.User, sshPubKey, remoteAddr string, principals []string,
+	expire int) (cert *ssh.Certificate, certMarshaled string, err error) {
+
+	if !a.ServiceCertificates || a.ServiceExpire < 1 {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: Service certificates not enabled"),
+		}
+		return
+	}
+
+	if expire < 1 || expire > a.ServiceExpire {
+		expire = a.ServiceExpire
+	}
+
+	restrict := &restriction{
+		Principals: principals,
+		Expire:     expire,
+	}
+
+	if a.Type == PritunlHsm {
+		cert, certMarshaled, err = a.createCertificateHsm(
+			db, usr, sshPubKey, remoteAddr, restrict)
+	} else {
+		cert, certMarshaled, err = a.createCertificateLocal(
+			usr, sshPubKey, remoteAddr, restrict)
+	}
+
+	return
+}
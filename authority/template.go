@@ -0,0 +1,362 @@
+package authority
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/user"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	templateVar      = regexp.MustCompile(`\{[a-z_]+(:[a-z0-9_]+)?\}`)
+	userTemplateVars = set.NewSet("{username}", "{username_local}",
+		"{user_id}", "{role}", "{attr:}")
+	hostTemplateVars   = set.NewSet("{hostname}", "{domain}")
+	defaultPermissions = map[string]string{
+		"permit-X11-forwarding":   "",
+		"permit-agent-forwarding": "",
+		"permit-port-forwarding":  "",
+		"permit-pty":              "",
+		"permit-user-rc":          "",
+	}
+)
+
+// Certificate options for users with the role, options from all matching
+// roles are combined and the first matching force command is used
+type RoleTemplate struct {
+	Role                  string `bson:"role" json:"role"`
+	PermitX11Forwarding   bool   `bson:"permit_x11_forwarding" json:"permit_x11_forwarding"`
+	PermitAgentForwarding bool   `bson:"permit_agent_forwarding" json:"permit_agent_forwarding"`
+	PermitPortForwarding  bool   `bson:"permit_port_forwarding" json:"permit_port_forwarding"`
+	PermitPty             bool   `bson:"permit_pty" json:"permit_pty"`
+	PermitUserRc          bool   `bson:"permit_user_rc" json:"permit_user_rc"`
+	ForceCommand          string `bson:"force_command" json:"force_command"`
+	SourceAddress         bool   `bson:"source_address" json:"source_address"`
+}
+
+func validateTemplates(templates []string, vars set.Set) bool {
+	for _, template := range templates {
+		if template == "" || strings.ContainsAny(template, " \t\r\n,") ||
+			strings.ContainsAny(
+				templateVar.ReplaceAllString(template, ""), "{}") {
+
+			return false
+		}
+
+		for _, match := range templateVar.FindAllString(template, -1) {
+			// Attribute variables are validated by prefix {attr:name}
+			i := strings.Index(match, ":")
+			if i != -1 {
+				match = match[:i+1] + "}"
+			}
+
+			if !vars.Contains(match) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Expanded values are set by users and identity providers, principals
+// with separators, whitespace or unexpanded variables are skipped
+func validPrincipal(principal string) bool {
+	if principal == "" || templateVar.MatchString(principal) {
+		return false
+	}
+
+	for _, c := range principal {
+		if c == ',' || c == '{' || c == '}' ||
+			unicode.IsSpace(c) || unicode.IsControl(c) {
+
+			return false
+		}
+	}
+
+	return true
+}
+
+func expandTemplates(templates []string, replacer *strings.Replacer,
+	roles []string) (principals []string) {
+
+	principals = []string{}
+	principalsSet := set.NewSet()
+
+	add := func(principal string) {
+		if !validPrincipal(principal) || principalsSet.Contains(principal) {
+			return
+		}
+		principalsSet.Add(principal)
+		principals = append(principals, principal)
+	}
+
+	for _, template := range templates {
+		template = replacer.Replace(template)
+
+		if strings.Contains(template, "{role}") {
+			for _, role := range roles {
+				add(strings.Replace(template, "{role}", role, -1))
+			}
+		} else {
+			add(template)
+		}
+	}
+
+	return
+}
+
+func (a *Authority) userPrincipals(usr *user.User) (principals []string) {
+	roles := usr.Roles
+
+	if len(a.PrincipalTemplates) == 0 {
+		principals = append([]string{}, roles...)
+	} else {
+		replacements := []string{
+			"{username}", usr.Username,
+			"{username_local}", strings.SplitN(usr.Username, "@", 2)[0],
+			"{user_id}", usr.Id.Hex(),
+		}
+
+		// Missing and invalid attributes are left unexpanded and the
+		// principal skipped
+		for name, value := range usr.Attributes {
+			if !validPrincipal(value) {
+				continue
+			}
+			replacements = append(replacements, "{attr:"+name+"}", value)
+		}
+
+		replacer := strings.NewReplacer(replacements...)
+
+		principals = expandTemplates(a.PrincipalTemplates, replacer, roles)
+	}
+
+	if a.JumpProxy() != "" {
+		hasBastion := false
+
+		for _, principal := range principals {
+			if principal == "bastion" {
+				hasBastion = true
+				break
+			}
+		}
+
+		if !hasBastion {
+			principals = append(principals, "bastion")
+		}
+	}
+
+	return
+}
+
+func (a *Authority) userPermissions(usr *user.User, remoteAddr string) (
+	permissions ssh.Permissions) {
+
+	permissions = ssh.Permissions{
+		CriticalOptions: map[string]string{},
+		Extensions:      map[string]string{},
+	}
+
+	matched := false
+	sourceAddress := false
+	forceCommand := ""
+
+	for _, roleTemplate := range a.RoleTemplates {
+		hasRole := false
+		for _, role := range usr.Roles {
+			if role == roleTemplate.Role {
+				hasRole = true
+				break
+			}
+		}
+
+		if !hasRole {
+			continue
+		}
+		matched = true
+
+		if roleTemplate.PermitX11Forwarding {
+			permissions.Extensions["permit-X11-forwarding"] = ""
+		}
+		if roleTemplate.PermitAgentForwarding {
+			permissions.Extensions["permit-agent-forwarding"] = ""
+		}
+		if roleTemplate.PermitPortForwarding {
+			permissions.Extensions["permit-port-forwarding"] = ""
+		}
+		if roleTemplate.PermitPty {
+			permissions.Extensions["permit-pty"] = ""
+		}
+		if roleTemplate.PermitUserRc {
+			permissions.Extensions["permit-user-rc"] = ""
+		}
+		if roleTemplate.SourceAddress {
+			sourceAddress = true
+		}
+		if forceCommand == "" {
+			forceCommand = roleTemplate.ForceCommand
+		}
+	}
+
+	if !matched {
+		for extension, value := range defaultPermissions {
+			permissions.Extensions[extension] = value
+		}
+		return
+	}
+
+	if forceCommand != "" {
+		permissions.CriticalOptions["force-command"] = forceCommand
+	}
+
+	if sourceAddress && remoteAddr != "" {
+		permissions.CriticalOptions["source-address"] = remoteAddr
+	}
+
+	return
+}
+
+// Unsigned user certificate with the authority templates applied, the
+// restriction is only set for service certificates
+func (a *Authority) newUserCertificate(usr *user.User,
+	pubKey ssh.PublicKey, remoteAddr string, restrict *restriction) (
+	cert *ssh.Certificate, err error) {
+
+	if len(usr.Roles) == 0 {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: User has no roles"),
+		}
+		return
+	}
+
+	expire := a.Expire
+	if expire == 0 {
+		expire = 600
+	}
+	if restrict != nil {
+		expire = restrict.Expire
+	}
+	validAfter := time.Now().Add(-3 * time.Minute).Unix()
+	validBefore := time.Now().Add(
+		time.Duration(expire) * time.Minute).Unix()
+
+	principals := a.userPrincipals(usr)
+	if restrict != nil {
+		principals, err = restrict.principals(principals)
+		if err != nil {
+			return
+		}
+	}
+	if len(principals) == 0 {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: User has no principals"),
+		}
+		return
+	}
+
+	cert = &ssh.Certificate{
+		Key:             pubKey,
+		CertType:        ssh.UserCert,
+		KeyId:           usr.Id.Hex(),
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(validAfter),
+		ValidBefore:     uint64(validBefore),
+		Permissions:     a.userPermissions(usr, remoteAddr),
+	}
+
+	return
+}
+
+// Unsigned host certificate with the authority host templates applied
+func (a *Authority) newHostCertificate(hostname, domain string,
+	pubKey ssh.PublicKey) (cert *ssh.Certificate) {
+
+	expire := a.HostExpire
+	if expire == 0 {
+		expire = 600
+	}
+	validAfter := time.Now().Add(-3 * time.Minute).Unix()
+	validBefore := time.Now().Add(
+		time.Duration(expire) * time.Minute).Unix()
+
+	principals := []string{domain}
+	if len(a.HostPrincipalTemplates) > 0 {
+		replacer := strings.NewReplacer(
+			"{hostname}", hostname,
+			"{domain}", domain,
+		)
+
+		principals = expandTemplates(
+			a.HostPrincipalTemplates, replacer, nil)
+	}
+
+	cert = &ssh.Certificate{
+		Key:             pubKey,
+		CertType:        ssh.HostCert,
+		KeyId:           hostname,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(validAfter),
+		ValidBefore:     uint64(validBefore),
+	}
+
+	return
+}
+
+func (a *Authority) validateTemplates() (errData *errortypes.ErrorData) {
+	if a.PrincipalTemplates == nil {
+		a.PrincipalTemplates = []string{}
+	}
+
+	if a.HostPrincipalTemplates == nil {
+		a.HostPrincipalTemplates = []string{}
+	}
+
+	if a.RoleTemplates == nil {
+		a.RoleTemplates = []*RoleTemplate{}
+	}
+
+	if !validateTemplates(a.PrincipalTemplates, userTemplateVars) {
+		errData = &errortypes.ErrorData{
+			Error:   "principal_template_invalid",
+			Message: "Principal template is invalid",
+		}
+		return
+	}
+
+	if !validateTemplates(a.HostPrincipalTemplates, hostTemplateVars) {
+		errData = &errortypes.ErrorData{
+			Error:   "host_principal_template_invalid",
+			Message: "Host principal template is invalid",
+		}
+		return
+	}
+
+	roles := set.NewSet()
+	for _, roleTemplate := range a.RoleTemplates {
+		if roleTemplate.Role == "" || roles.Contains(roleTemplate.Role) {
+			errData = &errortypes.ErrorData{
+				Error:   "role_template_role_invalid",
+				Message: "Role template role is invalid or duplicated",
+			}
+			return
+		}
+		roles.Add(roleTemplate.Role)
+
+		if strings.ContainsAny(roleTemplate.ForceCommand, "\r\n") {
+			errData = &errortypes.ErrorData{
+				Error:   "role_template_command_invalid",
+				Message: "Role template force command is invalid",
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package authority
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"{username}", true},
+		{"{username_local}-{role}", true},
+		{"{attr:unix_user}", true},
+		{"{attr:unix_user}-{user_id}", true},
+		{"{attr}", false},
+		{"{attr:Unix}", false},
+		{"{hostname}", false},
+		{"{username} admin", false},
+		{"{username},admin", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		valid := validateTemplates([]string{test.template}, userTemplateVars)
+		if valid != test.valid {
+			t.Errorf("%q: Expected valid %t", test.template, test.valid)
+		}
+	}
+}
+
+func TestUserPrincipalsAttributes(t *testing.T) {
+	authr := &Authority{
+		PrincipalTemplates: []string{
+			"{attr:unix_user}",
+			"{attr:group}-{role}",
+			"{attr:missing}",
+			"{attr:comma}",
+			"{attr:space}",
+			"{attr:control}",
+			"{attr:brace}",
+			"{username_local}",
+		},
+	}
+
+	usr := &user.User{
+		Id:       primitive.NewObjectID(),
+		Username: "test user@pritunl.test",
+		Roles:    []string{"dev", "ops"},
+		Attributes: map[string]string{
+			"unix_user": "jdoe",
+			"group":     "eng",
+			"comma":     "jdoe,root",
+			"space":     "jdoe root",
+			"control":   "jdoe\nroot",
+			"brace":     "{role}",
+		},
+	}
+
+	principals := authr.userPrincipals(usr)
+
+	expected := []string{"jdoe", "eng-dev", "eng-ops"}
+	if strings.Join(principals, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected principals %v", principals)
+	}
+
+	principalsSet := set.NewSet()
+	for _, principal := range principals {
+		principalsSet.Add(principal)
+	}
+
+	for _, principal := range []string{"root", "jdoe root", "test user"} {
+		if principalsSet.Contains(principal) {
+			t.Errorf("Invalid principal %q expanded", principal)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -13,6 +14,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"unicode"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson"
@@ -22,6 +24,12 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// Value cannot be split into several ssh configuration arguments or lines
+func validConfigValue(val string) bool {
+	return !strings.ContainsAny(val, " \t\r\n\"'") &&
+		strings.IndexFunc(val, unicode.IsControl) == -1
+}
+
 func parseSubnetMatch(subnetMatch string) (
 	match string, err error) {
 
@@ -210,6 +218,42 @@ func GenerateEcKey() (encodedPriv, encodedPub []byte, err error) {
 	return
 }
 
+func GenerateEd25519Key() (encodedPriv, encodedPub []byte, err error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to generate ed25519 key"),
+		}
+		return
+	}
+
+	pubKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse ed25519 key"),
+		}
+		return
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal ed25519 key"),
+		}
+		return
+	}
+
+	block := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyBytes,
+	}
+
+	encodedPriv = pem.EncodeToMemory(block)
+	encodedPub = MarshalPublicKey(pubKey)
+
+	return
+}
+
 func ParsePemKey(data string) (key crypto.PrivateKey, err error) {
 	block, _ := pem.Decode([]byte(data))
 	if block == nil {
@@ -238,6 +282,15 @@ func ParsePemKey(data string) (key crypto.PrivateKey, err error) {
 			return
 		}
 		break
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "authority: Failed to parse pkcs8 key"),
+			}
+			return
+		}
+		break
 	default:
 		err = &errortypes.ParseError{
 			errors.Newf("authority: Unknown key type '%s'", block.Type),
@@ -248,6 +301,41 @@ func ParsePemKey(data string) (key crypto.PrivateKey, err error) {
 	return
 }
 
+func getKeyAlg(pubKey crypto.PublicKey) string {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", pubKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "EC " + strings.Replace(
+			pubKey.Curve.Params().Name, "-", "", 1)
+	case ed25519.PublicKey:
+		return "ED25519"
+	default:
+		return "Unknown"
+	}
+}
+
+// SSH signature algorithm used by the authority key, RSA keys use SHA-512
+func getSigAlg(pubKey crypto.PublicKey) string {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		return ssh.KeyAlgoRSASHA512
+	case *ecdsa.PublicKey:
+		switch pubKey.Curve.Params().BitSize {
+		case 256:
+			return ssh.KeyAlgoECDSA256
+		case 521:
+			return ssh.KeyAlgoECDSA521
+		default:
+			return ssh.KeyAlgoECDSA384
+		}
+	case ed25519.PublicKey:
+		return ssh.KeyAlgoED25519
+	default:
+		return ""
+	}
+}
+
 func ParseSshPubKey(data string) (pubKey crypto.PublicKey, err error) {
 	sshPubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(data))
 	if err != nil {
@@ -290,8 +378,22 @@ func GetHsmToken(db *database.Database, token string) (
 	coll := db.Authorities()
 	authr = &Authority{}
 
+	if token == "" {
+		err = &database.NotFoundError{
+			errors.New("authority: Empty hsm token"),
+		}
+		return
+	}
+
 	err = coll.FindOne(db, &bson.M{
-		"hsm_token": token,
+		"$or": []*bson.M{
+			&bson.M{
+				"hsm_token": token,
+			},
+			&bson.M{
+				"hsm_agents.token": token,
+			},
+		},
 	}).Decode(authr)
 	if err != nil {
 		err = database.ParseError(err)
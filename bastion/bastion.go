@@ -2,38 +2,34 @@ package bastion
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
-	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/hydeant/pritunl-zero/authority"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/revocation"
 	"github.com/hydeant/pritunl-zero/settings"
 	"github.com/hydeant/pritunl-zero/ssh"
-	"github.com/hydeant/pritunl-zero/utils"
+	"github.com/hydeant/pritunl-zero/user"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 )
 
 type Bastion struct {
 	Authority  primitive.ObjectID
-	Container  string
 	authr      *authority.Authority
 	certExpire time.Time
 	state      bool
 	kill       bool
-	path       string
+	listener   net.Listener
+	srv        *server
 }
 
 func (b *Bastion) syncCert() {
-	defer func() {
-		os.RemoveAll(b.path)
-	}()
-
 	for {
 		if !b.state {
 			return
@@ -54,27 +50,61 @@ func (b *Bastion) syncCert() {
 	}
 }
 
+func (b *Bastion) syncRevoked() {
+	for {
+		time.Sleep(30 * time.Second)
+
+		if !b.state {
+			return
+		}
+
+		err := b.updateRevoked(nil)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("bastion: Bastion revocation update error")
+		}
+	}
+}
+
+func (b *Bastion) updateRevoked(db *database.Database) (err error) {
+	if db == nil {
+		db = database.GetDatabase()
+		defer db.Close()
+	}
+
+	revoked, err := revocation.GetRevoked(db, b.Authority)
+	if err != nil {
+		return
+	}
+
+	b.srv.setRevoked(revoked)
+
+	return
+}
+
 func (b *Bastion) wait() {
 	defer func() {
-		os.RemoveAll(b.path)
 		b.state = false
 		delete(state, b.Authority)
 	}()
 
-	output, err := utils.ExecOutput("", "docker", "wait", b.Container)
-	if b.state && err != nil {
-		err = &errortypes.RequestError{
-			errors.Wrapf(err, "bastion: Failed to exec docker"),
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if !b.kill {
+				logrus.WithFields(logrus.Fields{
+					"authority_id": b.Authority.Hex(),
+					"error":        err,
+				}).Error("bastion: Bastion server listener error")
+			}
+			break
 		}
-		return
-	}
 
-	output = strings.TrimSpace(output)
-	if output != "0" && output != "137" {
-		logrus.WithFields(logrus.Fields{
-			"exit_code": output,
-		}).Error("bastion: Bastion process error")
+		go b.srv.handleConn(conn)
 	}
+
+	b.srv.closeConns()
 }
 
 func (b *Bastion) renewHost(db *database.Database) (err error) {
@@ -90,22 +120,18 @@ func (b *Bastion) renewHost(db *database.Database) (err error) {
 	cert, e := ssh.NewBastionHostCertificate(db,
 		b.authr.ProxyHostname, b.authr.ProxyPublicKey, b.authr)
 	if e != nil {
-		b.state = false
-		os.RemoveAll(b.path)
 		err = e
 		return
 	}
 
-	hostCertPath := filepath.Join(b.path, "ssh_host_rsa_key-cert.pub")
-
 	if len(cert.Certificates) == 0 || len(cert.CertificatesInfo) == 0 {
 		err = &errortypes.UnknownError{
-			errors.Wrapf(err, "bastion: Missing host certificate"),
+			errors.New("bastion: Missing host certificate"),
 		}
 		return
 	}
 
-	err = utils.CreateWrite(hostCertPath, cert.Certificates[0], 0644)
+	err = b.srv.loadHostCert(cert.Certificates[0])
 	if err != nil {
 		return
 	}
@@ -122,15 +148,14 @@ func (b *Bastion) Start(db *database.Database,
 		"authority_id": b.Authority.Hex(),
 	}).Info("bastion: Starting bastion server")
 
-	if b.state || b.path != "" {
+	if b.state || b.listener != nil {
 		err = &errortypes.UnknownError{
-			errors.Wrapf(err, "bastion: Bastion server already running"),
+			errors.New("bastion: Bastion server already running"),
 		}
 		return
 	}
 
 	b.authr = authr
-	b.path = utils.GetTempPath()
 
 	if authr.ProxyPublicKey == "" || authr.ProxyPrivateKey == "" {
 		err = authr.GenerateRsaProxyPrivateKey()
@@ -147,54 +172,41 @@ func (b *Bastion) Start(db *database.Database,
 		}
 	}
 
-	b.state = true
+	b.srv, err = newServer(authr)
+	if err != nil {
+		return
+	}
 
-	err = utils.ExistsMkdir(b.path, 0755)
+	err = b.updateRevoked(db)
 	if err != nil {
-		b.state = false
-		os.RemoveAll(b.path)
 		return
 	}
 
+	b.state = true
+
 	if !settings.System.DisableBastionHostCertificates {
 		err = b.renewHost(db)
 		if err != nil {
 			b.state = false
-			os.RemoveAll(b.path)
 			return
 		}
 	}
 
-	output, err := utils.ExecOutput("",
-		"docker",
-		"run",
-		"--rm",
-		"-d",
-		"-u", "bastion",
-		"--name", DockerGetName(authr.Id),
-		"-p", fmt.Sprintf("%d:9722", authr.ProxyPort),
-		"-v", fmt.Sprintf("%s:/ssh_mount", b.path),
-		"-e", fmt.Sprintf(
-			"BASTION_TRUSTED=%s", authr.PublicKey),
-		"-e", fmt.Sprintf(
-			"BASTION_HOST_KEY=%s", authr.ProxyPrivateKey),
-		"-e", fmt.Sprintf(
-			"BASTION_HOST_PUB_KEY=%s", authr.ProxyPublicKey),
-		settings.System.BastionDockerImage,
-	)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", authr.ProxyPort))
 	if err != nil {
 		b.state = false
-		os.RemoveAll(b.path)
-
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "bastion: Failed to listen on bastion port"),
+		}
 		return
 	}
-
-	b.Container = strings.TrimSpace(output)
+	b.listener = listener
 
 	if !settings.System.DisableBastionHostCertificates {
 		go b.syncCert()
 	}
 
+	go b.syncRevoked()
 	go b.wait()
 
 	return
@@ -210,19 +222,18 @@ func (b *Bastion) Stop() (err error) {
 		"authority_id": b.Authority.Hex(),
 	}).Info("bastion: Stopping bastion server")
 
-	_, err = utils.ExecOutputLogged(nil,
-		"docker", "stop", "-t", "3", b.Container)
-	if err != nil {
+	if b.listener == nil {
+		b.state = false
 		return
 	}
 
-	go func() {
-		time.Sleep(15 * time.Second)
-
-		if b.state {
-			utils.ExecOutputLogged(nil, "docker", "kill", b.Container)
+	err = b.listener.Close()
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "bastion: Failed to close bastion listener"),
 		}
-	}()
+		return
+	}
 
 	return
 }
@@ -236,9 +247,39 @@ func (b *Bastion) Diff(authr *authority.Authority) bool {
 		b.authr.ProxyPrivateKey != authr.ProxyPrivateKey ||
 		b.authr.HostCertificates != authr.HostCertificates ||
 		b.authr.ProxyPort != authr.ProxyPort ||
-		b.authr.PublicKey != authr.PublicKey {
+		b.authr.ProxyRecording != authr.ProxyRecording ||
+		b.authr.PublicKey != authr.PublicKey ||
+		b.authr.NextPublicKey != authr.NextPublicKey ||
+		b.authr.PreviousPublicKey != authr.PreviousPublicKey ||
+		b.authr.HostDomain != authr.HostDomain ||
+		strings.Join(b.authr.HostMatches, ",") !=
+			strings.Join(authr.HostMatches, ",") ||
+		strings.Join(b.authr.HostSubnets, ",") !=
+			strings.Join(authr.HostSubnets, ",") {
 
 		return true
 	}
 	return false
 }
+
+// Issue the certificate for a recorded session connection to the host
+func newSessionCertificate(db *database.Database, authr *authority.Authority,
+	usr *user.User, pubKey string) (cert *ssh.Certificate, certStr string,
+	err error) {
+
+	cert, err = ssh.NewBastionSessionCertificate(db, authr, usr, pubKey)
+	if err != nil {
+		return
+	}
+
+	if len(cert.Certificates) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("bastion: Session certificate missing"),
+		}
+		return
+	}
+
+	certStr = cert.Certificates[0]
+
+	return
+}
@@ -0,0 +1,406 @@
+package bastion
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/revocation"
+	"golang.org/x/crypto/ssh"
+)
+
+// Principal required in user certificates to use the bastion
+const principal = "bastion"
+
+// Critical options set by the authority templates, options not listed
+// will fail certificate authentication
+const (
+	optForceCommand  = "force-command"
+	optSourceAddress = "source-address"
+)
+
+// Permission extensions used to pass the certificate to the session
+const (
+	extUserId = "pritunl-user-id"
+	extSerial = "pritunl-serial"
+)
+
+type directTcpip struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+// Connection metadata with the username replaced by the bastion principal,
+// recorded sessions use the username for the target host
+type bastionConnMeta struct {
+	ssh.ConnMetadata
+}
+
+func (c *bastionConnMeta) User() string {
+	return principal
+}
+
+type server struct {
+	authr      *authority.Authority
+	trusted    []ssh.PublicKey
+	hostKey    ssh.Signer
+	hostSigner ssh.Signer
+	revoked    *revocation.Revoked
+	conns      map[net.Conn]bool
+	lock       sync.Mutex
+}
+
+func (s *server) setRevoked(revoked *revocation.Revoked) {
+	s.lock.Lock()
+	s.revoked = revoked
+	s.lock.Unlock()
+}
+
+func (s *server) loadHostCert(certStr string) (err error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse host certificate"),
+		}
+		return
+	}
+
+	hostCert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("bastion: Host certificate invalid"),
+		}
+		return
+	}
+
+	hostSigner, err := ssh.NewCertSigner(hostCert, s.hostKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to load host certificate"),
+		}
+		return
+	}
+
+	s.lock.Lock()
+	s.hostSigner = hostSigner
+	s.lock.Unlock()
+
+	return
+}
+
+func (s *server) isAuthority(authrKey ssh.PublicKey) bool {
+	authrKeyBytes := authrKey.Marshal()
+
+	for _, key := range s.trusted {
+		if bytes.Equal(key.Marshal(), authrKeyBytes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *server) isRevoked(cert *ssh.Certificate) bool {
+	s.lock.Lock()
+	revoked := s.revoked
+	s.lock.Unlock()
+
+	return revoked.Contains(cert)
+}
+
+// Check if the address is within the comma separated source-address list
+func sourcePermitted(addr net.Addr, sourceAddrs string) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, sourceAddr := range strings.Split(sourceAddrs, ",") {
+		sourceAddr = strings.TrimSpace(sourceAddr)
+
+		ip := net.ParseIP(sourceAddr)
+		if ip != nil {
+			if ip.Equal(tcpAddr.IP) {
+				return true
+			}
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(sourceAddr)
+		if err != nil {
+			continue
+		}
+
+		if subnet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *server) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (
+	perms *ssh.Permissions, err error) {
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: Public key is not a certificate"),
+		}
+		return
+	}
+
+	username := conn.User()
+	if username != principal {
+		_, _, _, ok := parseTarget(username)
+		if !s.authr.ProxyRecording || !ok {
+			err = &errortypes.AuthenticationError{
+				errors.New("bastion: Invalid bastion username"),
+			}
+			return
+		}
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: s.isAuthority,
+		IsRevoked:       s.isRevoked,
+		SupportedCriticalOptions: []string{
+			optForceCommand,
+			optSourceAddress,
+		},
+	}
+
+	_, err = checker.Authenticate(&bastionConnMeta{conn}, key)
+	if err != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(err, "bastion: Certificate authentication failed"),
+		}
+		return
+	}
+
+	sourceAddrs, ok := cert.CriticalOptions[optSourceAddress]
+	if ok && !sourcePermitted(conn.RemoteAddr(), sourceAddrs) {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: Certificate source address not permitted"),
+		}
+		return
+	}
+
+	// Recorded sessions connect with a certificate from the same templates
+	// which will apply the forced command on the host, forwarded
+	// connections can't be restricted to a command
+	if username == principal {
+		_, ok = cert.CriticalOptions[optForceCommand]
+		if ok {
+			err = &errortypes.AuthenticationError{
+				errors.New("bastion: Certificate restricted to command"),
+			}
+			return
+		}
+
+		_, ok = cert.Permissions.Extensions["permit-port-forwarding"]
+		if !ok {
+			err = &errortypes.AuthenticationError{
+				errors.New("bastion: Certificate does not permit forwarding"),
+			}
+			return
+		}
+	}
+
+	perms = &ssh.Permissions{
+		CriticalOptions: cert.Permissions.CriticalOptions,
+		Extensions: map[string]string{
+			extUserId: cert.KeyId,
+			extSerial: strconv.FormatUint(cert.Serial, 10),
+		},
+	}
+
+	return
+}
+
+func (s *server) config() (conf *ssh.ServerConfig) {
+	s.lock.Lock()
+	hostSigner := s.hostSigner
+	s.lock.Unlock()
+
+	conf = &ssh.ServerConfig{
+		PublicKeyCallback: s.authenticate,
+	}
+	conf.AddHostKey(hostSigner)
+
+	return
+}
+
+func (s *server) handleConn(conn net.Conn) {
+	s.lock.Lock()
+	s.conns[conn] = true
+	s.lock.Unlock()
+
+	defer func() {
+		conn.Close()
+
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+	}()
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"remote_addr":  conn.RemoteAddr().String(),
+			"error":        err,
+		}).Info("bastion: Bastion connection rejected")
+		return
+	}
+	defer sshConn.Close()
+
+	conn.SetDeadline(time.Time{})
+
+	go ssh.DiscardRequests(reqs)
+
+	if sshConn.User() != principal {
+		s.handleSession(sshConn, chans)
+		return
+	}
+
+	for newChan := range chans {
+		if !s.forwardPermitted(newChan) {
+			continue
+		}
+
+		go s.handleDirect(sshConn, newChan)
+	}
+}
+
+// Forwarded connections are not recorded, with recording enabled only
+// recorded sessions are permitted
+func (s *server) forwardPermitted(newChan ssh.NewChannel) bool {
+	if s.authr.ProxyRecording {
+		newChan.Reject(ssh.Prohibited,
+			"bastion: Forwarding is disabled, sessions are recorded")
+		return false
+	}
+
+	if newChan.ChannelType() != "direct-tcpip" {
+		newChan.Reject(ssh.Prohibited,
+			"bastion: Only direct-tcpip forwarding is permitted")
+		return false
+	}
+
+	return true
+}
+
+func (s *server) handleDirect(sshConn *ssh.ServerConn,
+	newChan ssh.NewChannel) {
+
+	data := &directTcpip{}
+	err := ssh.Unmarshal(newChan.ExtraData(), data)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed,
+			"bastion: Invalid forwarding request")
+		return
+	}
+
+	if !s.authr.HostPermitted(data.Host) {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"remote_addr":  sshConn.RemoteAddr().String(),
+			"host":         data.Host,
+		}).Warning("bastion: Forwarding to host not permitted")
+
+		newChan.Reject(ssh.Prohibited,
+			"bastion: Forwarding to host not permitted")
+		return
+	}
+
+	dest, err := net.DialTimeout("tcp", net.JoinHostPort(
+		data.Host, strconv.Itoa(int(data.Port))), 10*time.Second)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed,
+			fmt.Sprintf("bastion: Failed to connect to host: %s", err))
+		return
+	}
+	defer dest.Close()
+
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	waiter := sync.WaitGroup{}
+	waiter.Add(2)
+
+	go func() {
+		defer waiter.Done()
+		io.Copy(dest, channel)
+		if tcpDest, ok := dest.(*net.TCPConn); ok {
+			tcpDest.CloseWrite()
+		}
+	}()
+
+	go func() {
+		defer waiter.Done()
+		io.Copy(channel, dest)
+		channel.CloseWrite()
+	}()
+
+	waiter.Wait()
+}
+
+func (s *server) closeConns() {
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+}
+
+func newServer(authr *authority.Authority) (s *server, err error) {
+	hostKey, err := ssh.ParsePrivateKey([]byte(authr.ProxyPrivateKey))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse host key"),
+		}
+		return
+	}
+
+	trusted := []ssh.PublicKey{}
+	for _, pubKey := range authr.TrustedPublicKeys() {
+		key, _, _, _, e := ssh.ParseAuthorizedKey([]byte(pubKey))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bastion: Failed to parse authority key"),
+			}
+			return
+		}
+
+		trusted = append(trusted, key)
+	}
+
+	s = &server{
+		authr:      authr,
+		trusted:    trusted,
+		hostKey:    hostKey,
+		hostSigner: hostKey,
+		conns:      map[net.Conn]bool{},
+	}
+
+	return
+}
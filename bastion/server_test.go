@@ -0,0 +1,219 @@
+package bastion
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hydeant/pritunl-zero/authority"
+	"golang.org/x/crypto/ssh"
+)
+
+type testChannel struct {
+	chanType string
+	rejected bool
+	reason   ssh.RejectionReason
+}
+
+func (c *testChannel) Accept() (ssh.Channel, <-chan *ssh.Request, error) {
+	return nil, nil, nil
+}
+
+func (c *testChannel) Reject(reason ssh.RejectionReason,
+	message string) error {
+
+	c.rejected = true
+	c.reason = reason
+	return nil
+}
+
+func (c *testChannel) ChannelType() string {
+	return c.chanType
+}
+
+func (c *testChannel) ExtraData() []byte {
+	return nil
+}
+
+func TestForwardPermitted(t *testing.T) {
+	tests := []struct {
+		recording bool
+		chanType  string
+		permitted bool
+	}{
+		{false, "direct-tcpip", true},
+		{false, "session", false},
+		{true, "direct-tcpip", false},
+		{true, "session", false},
+		{true, "forwarded-tcpip", false},
+	}
+
+	for _, test := range tests {
+		s := &server{
+			authr: &authority.Authority{
+				ProxyRecording: test.recording,
+			},
+		}
+		newChan := &testChannel{
+			chanType: test.chanType,
+		}
+
+		permitted := s.forwardPermitted(newChan)
+		if permitted != test.permitted {
+			t.Errorf("%s recording %t: Expected permitted %t",
+				test.chanType, test.recording, test.permitted)
+		}
+
+		if newChan.rejected == permitted {
+			t.Errorf("%s recording %t: Channel rejection mismatch",
+				test.chanType, test.recording)
+		}
+
+		if newChan.rejected && newChan.reason != ssh.Prohibited {
+			t.Errorf("%s recording %t: Unexpected rejection reason %s",
+				test.chanType, test.recording, newChan.reason)
+		}
+	}
+}
+
+type testConnMeta struct {
+	user       string
+	remoteAddr net.Addr
+}
+
+func (c *testConnMeta) User() string {
+	return c.user
+}
+
+func (c *testConnMeta) SessionID() []byte {
+	return nil
+}
+
+func (c *testConnMeta) ClientVersion() []byte {
+	return nil
+}
+
+func (c *testConnMeta) ServerVersion() []byte {
+	return nil
+}
+
+func (c *testConnMeta) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *testConnMeta) LocalAddr() net.Addr {
+	return nil
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func TestSourcePermitted(t *testing.T) {
+	addr := &net.TCPAddr{
+		IP:   net.ParseIP("10.0.0.5"),
+		Port: 50000,
+	}
+
+	tests := []struct {
+		sourceAddrs string
+		permitted   bool
+	}{
+		{"10.0.0.5", true},
+		{"10.0.0.6", false},
+		{"10.0.0.0/24", true},
+		{"10.1.0.0/24", false},
+		{"192.168.1.1, 10.0.0.0/8", true},
+		{"invalid", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if sourcePermitted(addr, test.sourceAddrs) != test.permitted {
+			t.Errorf("%q: Expected permitted %t",
+				test.sourceAddrs, test.permitted)
+		}
+	}
+
+	if sourcePermitted(nil, "10.0.0.5") {
+		t.Error("Unknown address permitted")
+	}
+}
+
+func TestAuthenticateCriticalOptions(t *testing.T) {
+	caKey := newTestSigner(t)
+
+	s := &server{
+		authr:   &authority.Authority{},
+		trusted: []ssh.PublicKey{caKey.PublicKey()},
+	}
+
+	conn := &testConnMeta{
+		user: principal,
+		remoteAddr: &net.TCPAddr{
+			IP:   net.ParseIP("10.0.0.5"),
+			Port: 50000,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		options   map[string]string
+		permitted bool
+	}{
+		{"none", map[string]string{}, true},
+		{"source_address", map[string]string{
+			optSourceAddress: "10.0.0.0/24",
+		}, true},
+		{"source_address_denied", map[string]string{
+			optSourceAddress: "10.1.0.5",
+		}, false},
+		{"force_command", map[string]string{
+			optForceCommand: "/bin/true",
+		}, false},
+		{"unsupported", map[string]string{
+			"verify-required": "",
+		}, false},
+	}
+
+	for _, test := range tests {
+		cert := &ssh.Certificate{
+			Key:             newTestSigner(t).PublicKey(),
+			Serial:          1,
+			CertType:        ssh.UserCert,
+			KeyId:           "test",
+			ValidPrincipals: []string{principal},
+			ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+			Permissions: ssh.Permissions{
+				CriticalOptions: test.options,
+				Extensions: map[string]string{
+					"permit-port-forwarding": "",
+				},
+			},
+		}
+
+		err := cert.SignCert(rand.Reader, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.authenticate(conn, cert)
+		if (err == nil) != test.permitted {
+			t.Errorf("%s: Expected permitted %t: %v",
+				test.name, test.permitted, err)
+		}
+	}
+}
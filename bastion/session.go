@@ -0,0 +1,387 @@
+package bastion
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/recording"
+	"github.com/hydeant/pritunl-zero/user"
+	"golang.org/x/crypto/ssh"
+)
+
+type ptyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+type windowChange struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+type execRequest struct {
+	Command string
+}
+
+type target struct {
+	HostUser   string
+	Host       string
+	Port       int
+	UserId     primitive.ObjectID
+	Serial     string
+	RemoteAddr string
+}
+
+// Parse recorded session username in the format user@host[:port]
+func parseTarget(username string) (hostUser, host string, port int,
+	ok bool) {
+
+	n := strings.LastIndex(username, "@")
+	if n < 1 || n == len(username)-1 {
+		return
+	}
+
+	hostUser = username[:n]
+	host = username[n+1:]
+	port = 22
+
+	if strings.Contains(host, ":") {
+		hostStr, portStr, err := net.SplitHostPort(host)
+		if err != nil {
+			return
+		}
+
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return
+		}
+
+		host = hostStr
+	}
+
+	if host == "" {
+		return
+	}
+
+	ok = true
+	return
+}
+
+func (s *server) hostKeyCallback() ssh.HostKeyCallback {
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return s.isAuthority(auth)
+		},
+	}
+
+	if !s.authr.StrictHostChecking {
+		checker.HostKeyFallback = ssh.InsecureIgnoreHostKey()
+	}
+
+	return checker.CheckHostKey
+}
+
+// Connect to the target host with an ephemeral key and certificate issued
+// to the user from the authority
+func (s *server) dialTarget(tgt *target) (client *ssh.Client, err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	usr, err := user.Get(db, tgt.UserId)
+	if err != nil {
+		return
+	}
+
+	if usr.Disabled || !s.authr.UserHasAccess(usr) {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: User does not have access to authority"),
+		}
+		return
+	}
+
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "bastion: Failed to generate session key"),
+		}
+		return
+	}
+
+	signer, err := ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse session key"),
+		}
+		return
+	}
+
+	pubKey := strings.TrimSpace(string(
+		ssh.MarshalAuthorizedKey(signer.PublicKey())))
+
+	sessionCert, certStr, err := newSessionCertificate(
+		db, s.authr, usr, pubKey)
+	if err != nil {
+		return
+	}
+
+	serials := []string{}
+	principals := []string{}
+	for _, info := range sessionCert.CertificatesInfo {
+		serials = append(serials, info.Serial)
+		principals = append(principals, info.Principals...)
+	}
+
+	err = audit.NewUser(
+		db,
+		usr.Id,
+		audit.SshBastionApprove,
+		audit.Fields{
+			"authority_id":   s.authr.Id,
+			"certificate_id": sessionCert.Id,
+			"ssh_key":        sessionCert.PubKey,
+			"serials":        serials,
+			"principals":     principals,
+			"bastion_serial": tgt.Serial,
+			"host":           tgt.Host,
+			"host_user":      tgt.HostUser,
+			"remote_addr":    tgt.RemoteAddr,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	certKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse session certificate"),
+		}
+		return
+	}
+
+	cert, ok := certKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("bastion: Session certificate invalid"),
+		}
+		return
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to load session certificate"),
+		}
+		return
+	}
+
+	client, err = ssh.Dial("tcp",
+		net.JoinHostPort(tgt.Host, strconv.Itoa(tgt.Port)),
+		&ssh.ClientConfig{
+			User: tgt.HostUser,
+			Auth: []ssh.AuthMethod{
+				ssh.PublicKeys(certSigner),
+			},
+			HostKeyCallback: s.hostKeyCallback(),
+			Timeout:         10 * time.Second,
+		},
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "bastion: Failed to connect to host"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *server) handleSession(sshConn *ssh.ServerConn,
+	chans <-chan ssh.NewChannel) {
+
+	hostUser, host, port, _ := parseTarget(sshConn.User())
+	remoteAddr, _, _ := net.SplitHostPort(sshConn.RemoteAddr().String())
+	userId, _ := primitive.ObjectIDFromHex(
+		sshConn.Permissions.Extensions[extUserId])
+
+	tgt := &target{
+		HostUser:   hostUser,
+		Host:       host,
+		Port:       port,
+		UserId:     userId,
+		Serial:     sshConn.Permissions.Extensions[extSerial],
+		RemoteAddr: remoteAddr,
+	}
+
+	var client *ssh.Client
+	var err error
+
+	if !s.authr.HostPermitted(host) {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: Host not permitted"),
+		}
+	} else {
+		client, err = s.dialTarget(tgt)
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"user_id":      userId.Hex(),
+			"remote_addr":  remoteAddr,
+			"host":         host,
+			"error":        err,
+		}).Warning("bastion: Failed to start recorded session")
+
+		newChan, ok := <-chans
+		if ok {
+			newChan.Reject(ssh.ConnectionFailed,
+				"bastion: Failed to connect to host")
+		}
+		return
+	}
+	defer client.Close()
+
+	go func() {
+		client.Wait()
+		sshConn.Close()
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"authority_id": s.authr.Id.Hex(),
+		"user_id":      userId.Hex(),
+		"remote_addr":  remoteAddr,
+		"host":         host,
+		"host_user":    hostUser,
+	}).Info("bastion: Starting recorded session")
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.Prohibited,
+				"bastion: Only sessions are permitted")
+			continue
+		}
+
+		go s.proxySession(client, newChan, tgt)
+	}
+}
+
+func (s *server) proxySession(client *ssh.Client, newChan ssh.NewChannel,
+	tgt *target) {
+
+	targetChan, targetReqs, err := client.OpenChannel("session", nil)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed,
+			fmt.Sprintf("bastion: Failed to open session: %s", err))
+		return
+	}
+	defer targetChan.Close()
+
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	db := database.GetDatabase()
+	rcdr, err := recording.New(db, &recording.Recording{
+		AuthorityId: s.authr.Id,
+		UserId:      tgt.UserId,
+		Serial:      tgt.Serial,
+		Host:        tgt.Host,
+		Port:        tgt.Port,
+		HostUser:    tgt.HostUser,
+		RemoteAddr:  tgt.RemoteAddr,
+	}, 80, 24, "")
+	db.Close()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"user_id":      tgt.UserId.Hex(),
+			"error":        err,
+		}).Error("bastion: Failed to start session recording")
+		return
+	}
+	defer rcdr.Close()
+
+	waiter := &sync.WaitGroup{}
+	waiter.Add(2)
+
+	go func() {
+		defer waiter.Done()
+
+		for req := range targetReqs {
+			ok, _ := channel.SendRequest(req.Type, req.WantReply, req.Payload)
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+		}
+	}()
+
+	go func() {
+		for req := range reqs {
+			switch req.Type {
+			case "pty-req":
+				data := &ptyRequest{}
+				if ssh.Unmarshal(req.Payload, data) == nil {
+					rcdr.Resize(int(data.Columns), int(data.Rows))
+				}
+				break
+			case "window-change":
+				data := &windowChange{}
+				if ssh.Unmarshal(req.Payload, data) == nil {
+					rcdr.Resize(int(data.Columns), int(data.Rows))
+				}
+				break
+			case "exec":
+				data := &execRequest{}
+				if ssh.Unmarshal(req.Payload, data) == nil {
+					rcdr.Command(data.Command)
+				}
+				break
+			}
+
+			ok, _ := targetChan.SendRequest(
+				req.Type, req.WantReply, req.Payload)
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+		}
+	}()
+
+	// Input is not recorded, passwords entered at prompts would be
+	// stored with the recording
+	go func() {
+		io.Copy(targetChan, channel)
+		targetChan.CloseWrite()
+	}()
+
+	go func() {
+		defer waiter.Done()
+		io.Copy(io.MultiWriter(channel.Stderr(), rcdr.Output()),
+			targetChan.Stderr())
+	}()
+
+	io.Copy(io.MultiWriter(channel, rcdr.Output()), targetChan)
+
+	waiter.Wait()
+}
@@ -2,6 +2,7 @@ package bastion
 
 import (
 	"fmt"
+	"os/exec"
 	"strings"
 
 	"github.com/dropbox/godropbox/errors"
@@ -11,11 +12,9 @@ import (
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
-func DockerMatchContainer(a, b string) bool {
-	if len(b) > len(a) {
-		a, b = b, a
-	}
-	return strings.HasPrefix(a, b)
+func DockerAvailable() bool {
+	_, err := exec.LookPath("docker")
+	return err == nil
 }
 
 func DockerGetName(authrId primitive.ObjectID) string {
@@ -3,9 +3,11 @@ package certificate
 import (
 	"crypto/md5"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"io"
+	"net/url"
 	"strings"
 	"time"
 
@@ -28,15 +30,68 @@ type Info struct {
 }
 
 type Certificate struct {
-	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `bson:"name" json:"name"`
-	Type        string             `bson:"type" json:"type"`
-	Key         string             `bson:"key" json:"key"`
-	Certificate string             `bson:"certificate" json:"certificate"`
-	Info        *Info              `bson:"info" json:"info"`
-	AcmeHash    string             `bson:"acme_hash" json:"-"`
-	AcmeAccount string             `bson:"acme_account" json:"-"`
-	AcmeDomains []string           `bson:"acme_domains" json:"acme_domains"`
+	Id                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name                string             `bson:"name" json:"name"`
+	Type                string             `bson:"type" json:"type"`
+	Key                 string             `bson:"key" json:"key"`
+	Certificate         string             `bson:"certificate" json:"certificate"`
+	Info                *Info              `bson:"info" json:"info"`
+	AcmeHash            string             `bson:"acme_hash" json:"-"`
+	AcmeAccount         string             `bson:"acme_account" json:"-"`
+	AcmeDomains         []string           `bson:"acme_domains" json:"acme_domains"`
+	Auto                bool               `bson:"auto" json:"auto"`
+	AcmeAuth            string             `bson:"acme_auth" json:"acme_auth"`
+	AcmeDnsProvider     string             `bson:"acme_dns_provider" json:"acme_dns_provider"`
+	AcmeDnsServer       string             `bson:"acme_dns_server" json:"acme_dns_server"`
+	AcmeDnsKeyName      string             `bson:"acme_dns_key_name" json:"acme_dns_key_name"`
+	AcmeDnsKeySecret    string             `bson:"acme_dns_key_secret" json:"-"`
+	AcmeDnsKeyAlgorithm string             `bson:"acme_dns_key_algorithm" json:"acme_dns_key_algorithm"`
+	AcmeDirectory       string             `bson:"acme_directory" json:"acme_directory"`
+	AcmeCaBundle        string             `bson:"acme_ca_bundle" json:"acme_ca_bundle"`
+	AcmeEabKid          string             `bson:"acme_eab_kid" json:"acme_eab_kid"`
+	AcmeEabKey          string             `bson:"acme_eab_key" json:"-"`
+	AcmeEmail           string             `bson:"acme_email" json:"acme_email"`
+	AcmeKeyAlgorithm    string             `bson:"acme_key_algorithm" json:"acme_key_algorithm"`
+}
+
+func (c *Certificate) Directory() string {
+	if c.AcmeDirectory != "" {
+		return c.AcmeDirectory
+	}
+	return AcmeDirectory
+}
+
+// Certificate pool for the ACME directory, the CA bundle is added to the
+// system roots to support internal certificate authorities
+func (c *Certificate) CaPool() (pool *x509.CertPool, err error) {
+	pool, e := x509.SystemCertPool()
+	if e != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM([]byte(c.AcmeCaBundle)) {
+		err = &errortypes.ParseError{
+			errors.New("certificate: Failed to parse ACME CA bundle"),
+		}
+		return
+	}
+
+	return
+}
+
+// External account binding keys are provided base64 url encoded
+func (c *Certificate) EabKey() (key []byte, err error) {
+	keyStr := strings.TrimRight(strings.TrimSpace(c.AcmeEabKey), "=")
+
+	key, err = base64.RawURLEncoding.DecodeString(keyStr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "certificate: Failed to decode EAB key"),
+		}
+		return
+	}
+
+	return
 }
 
 func (c *Certificate) Validate(db *database.Database) (
@@ -47,7 +102,16 @@ func (c *Certificate) Validate(db *database.Database) (
 	}
 
 	if c.Type != LetsEncrypt {
+		c.Auto = false
 		c.AcmeAccount = ""
+		c.AcmeAuth = ""
+		c.AcmeDnsProvider = ""
+		c.AcmeDirectory = ""
+		c.AcmeCaBundle = ""
+		c.AcmeEabKid = ""
+		c.AcmeEabKey = ""
+		c.AcmeEmail = ""
+		c.AcmeKeyAlgorithm = ""
 		c.AcmeDomains = []string{}
 	}
 
@@ -69,6 +133,142 @@ func (c *Certificate) Validate(db *database.Database) (
 		return
 	}
 
+	c.AcmeDirectory = strings.TrimSpace(c.AcmeDirectory)
+	if c.AcmeDirectory != "" {
+		dirUrl, e := url.Parse(c.AcmeDirectory)
+		if e != nil || dirUrl.Scheme != "https" || dirUrl.Host == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_directory_invalid",
+				Message: "ACME directory must be a HTTPS URL",
+			}
+			return
+		}
+	}
+
+	c.AcmeCaBundle = strings.TrimSpace(c.AcmeCaBundle)
+	if c.AcmeCaBundle != "" {
+		_, e := c.CaPool()
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_ca_bundle_invalid",
+				Message: "ACME CA bundle must contain PEM certificates",
+			}
+			return
+		}
+	}
+
+	c.AcmeEabKid = strings.TrimSpace(c.AcmeEabKid)
+	if c.AcmeEabKid == "" {
+		c.AcmeEabKey = ""
+	} else {
+		key, e := c.EabKey()
+		if e != nil || len(key) == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_eab_key_invalid",
+				Message: "ACME external account binding key is invalid",
+			}
+			return
+		}
+	}
+
+	c.AcmeEmail = strings.TrimSpace(c.AcmeEmail)
+	if c.AcmeEmail != "" && !strings.Contains(c.AcmeEmail, "@") {
+		errData = &errortypes.ErrorData{
+			Error:   "acme_email_invalid",
+			Message: "ACME contact email is invalid",
+		}
+		return
+	}
+
+	switch c.AcmeKeyAlgorithm {
+	case "", Rsa, EcP256, EcP384:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "acme_key_algorithm_invalid",
+			Message: "Invalid ACME certificate key algorithm",
+		}
+		return
+	}
+
+	if c.AcmeAuth == "" {
+		c.AcmeAuth = AcmeHttp
+	}
+
+	switch c.AcmeAuth {
+	case AcmeHttp:
+		for _, domain := range c.AcmeDomains {
+			if strings.Contains(domain, "*") {
+				errData = &errortypes.ErrorData{
+					Error: "acme_wildcard_http",
+					Message: "Wildcard domains require DNS " +
+						"challenge authentication",
+				}
+				return
+			}
+		}
+
+		c.AcmeDnsProvider = ""
+		break
+	case AcmeDns:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "acme_auth_invalid",
+			Message: "Invalid ACME challenge authentication",
+		}
+		return
+	}
+
+	switch c.AcmeDnsProvider {
+	case "":
+		break
+	case DnsRfc2136:
+		if c.AcmeDnsServer == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_dns_server_missing",
+				Message: "DNS update server required",
+			}
+			return
+		}
+
+		if c.AcmeDnsKeyAlgorithm == "" {
+			c.AcmeDnsKeyAlgorithm = DnsHmacSha256
+		}
+
+		if !DnsHmacAlgorithms.Contains(c.AcmeDnsKeyAlgorithm) {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_dns_key_algorithm_invalid",
+				Message: "Invalid DNS TSIG key algorithm",
+			}
+			return
+		}
+		break
+	case DnsExec:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "acme_dns_provider_invalid",
+			Message: "Invalid ACME DNS provider",
+		}
+		return
+	}
+
+	if c.AcmeAuth == AcmeDns && c.AcmeDnsProvider == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "acme_dns_provider_missing",
+			Message: "ACME DNS provider required",
+		}
+		return
+	}
+
+	if c.AcmeDnsProvider != DnsRfc2136 {
+		c.AcmeDnsServer = ""
+		c.AcmeDnsKeyName = ""
+		c.AcmeDnsKeySecret = ""
+		c.AcmeDnsKeyAlgorithm = ""
+	}
+
 	err = c.UpdateInfo()
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -194,6 +394,8 @@ func (c *Certificate) Hash() string {
 	hash.Write([]byte(c.Key))
 	hash.Write([]byte(c.Certificate))
 	hash.Write([]byte(c.AcmeAccount))
+	hash.Write([]byte(c.AcmeDirectory))
+	hash.Write([]byte(c.AcmeKeyAlgorithm))
 	if c.AcmeDomains != nil {
 		for _, domain := range c.AcmeDomains {
 			io.WriteString(hash, domain)
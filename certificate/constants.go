@@ -1,6 +1,34 @@
 package certificate
 
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
 	Text        = "text"
 	LetsEncrypt = "lets_encrypt"
+
+	AcmeHttp = "acme_http"
+	AcmeDns  = "acme_dns"
+
+	DnsRfc2136 = "rfc2136"
+	DnsExec    = "exec"
+
+	Rsa    = "rsa"
+	EcP256 = "ec_p256"
+	EcP384 = "ec_p384"
+
+	AcmeDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+
+	DnsHmacSha1   = "hmac-sha1"
+	DnsHmacSha256 = "hmac-sha256"
+	DnsHmacSha512 = "hmac-sha512"
+)
+
+var (
+	DnsHmacAlgorithms = set.NewSet(
+		DnsHmacSha1,
+		DnsHmacSha256,
+		DnsHmacSha512,
+	)
 )
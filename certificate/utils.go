@@ -50,6 +50,81 @@ func GetAll(db *database.Database) (certs []*Certificate, err error) {
 	return
 }
 
+func GetAuto(db *database.Database) (certs []*Certificate, err error) {
+	coll := db.Certificates()
+	certs = []*Certificate{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"auto": true,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cert := &Certificate{}
+		err = cursor.Decode(cert)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		certs = append(certs, cert)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAutoDomains(db *database.Database, domains []string) (
+	certs []*Certificate, err error) {
+
+	coll := db.Certificates()
+	certs = []*Certificate{}
+
+	if len(domains) == 0 {
+		return
+	}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"auto": true,
+		"acme_domains": &bson.M{
+			"$in": domains,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cert := &Certificate{}
+		err = cursor.Decode(cert)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		certs = append(certs, cert)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Remove(db *database.Database, certId primitive.ObjectID) (err error) {
 	coll := db.Certificates()
 
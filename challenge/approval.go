@@ -0,0 +1,166 @@
+package challenge
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/agent"
+	"github.com/hydeant/pritunl-zero/approval"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/ssh"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+// Leave the challenge pending until a second user answers the approval
+func (c *Challenge) requestApproval(db *database.Database, usr *user.User,
+	authrIds []primitive.ObjectID, approverRoles [][]string,
+	agnt *agent.Agent) (err error) {
+
+	if c.State != "" {
+		err = errortypes.WriteError{
+			errors.New("sshcert: Challenge has already been answered"),
+		}
+		return
+	}
+
+	apprvl, err := approval.New(db, c.Id, usr, authrIds, approverRoles,
+		agnt, c.PubKey, c.RemoteAddr)
+	if err != nil {
+		return
+	}
+
+	// Challenge expiry restarts with the approval to leave the approver
+	// the full approval timeout
+	c.State = ssh.Pending
+	c.ApprovalId = apprvl.Id
+	c.Timestamp = time.Now()
+
+	coll := db.SshChallenges()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": "",
+	}, &bson.M{
+		"$set": c,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	event.PublishDispatch(db, "ssh_approval.change")
+
+	return
+}
+
+// Get the pending challenge for an answered approval
+func getApproval(db *database.Database, apprvl *approval.Approval) (
+	chal *Challenge, err error) {
+
+	chal, err = GetChallenge(db, apprvl.ChallengeId)
+	if err != nil {
+		return
+	}
+
+	if chal.State != ssh.Pending || chal.ApprovalId != apprvl.Id {
+		err = errortypes.WriteError{
+			errors.New("sshcert: Challenge is not pending approval"),
+		}
+		return
+	}
+
+	return
+}
+
+// Issue the certificate for a challenge after the approval was granted
+func Grant(db *database.Database, apprvl *approval.Approval) (
+	chal *Challenge, err error) {
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		e := apprvl.Rollback(db)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"approval_id": apprvl.Id.Hex(),
+				"error":       e,
+			}).Error("challenge: Failed to rollback approval")
+		}
+	}()
+
+	chal, err = getApproval(db, apprvl)
+	if err != nil {
+		return
+	}
+
+	usr, err := user.Get(db, apprvl.UserId)
+	if err != nil {
+		return
+	}
+
+	if usr.Disabled {
+		err = Reject(db, apprvl)
+		return
+	}
+
+	allAuthrs, err := authority.GetMulti(db, apprvl.AuthorityIds)
+	if err != nil {
+		return
+	}
+
+	authrs := []*authority.Authority{}
+	for _, authr := range allAuthrs {
+		if authr.UserHasAccess(usr) {
+			authrs = append(authrs, authr)
+		}
+	}
+
+	chal.Timestamp = time.Now()
+
+	err = chal.issue(db, authrs, usr, apprvl.Agent, ssh.Pending)
+	if err != nil {
+		return
+	}
+
+	event.Publish(db, "ssh_challenge", chal.Id)
+	event.PublishDispatch(db, "ssh_approval.change")
+
+	return
+}
+
+// Deny the challenge after the approval was denied
+func Reject(db *database.Database, apprvl *approval.Approval) (err error) {
+	chal, err := getApproval(db, apprvl)
+	if err != nil {
+		return
+	}
+
+	chal.State = ssh.Denied
+	chal.CertificateId = primitive.NilObjectID
+
+	coll := db.SshChallenges()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   chal.Id,
+		"state": ssh.Pending,
+	}, &bson.M{
+		"$set": chal,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	event.Publish(db, "ssh_challenge", chal.Id)
+	event.PublishDispatch(db, "ssh_approval.change")
+
+	return
+}
@@ -24,9 +24,12 @@ import (
 type Challenge struct {
 	Id            string             `bson:"_id"`
 	CertificateId primitive.ObjectID `bson:"certificate_id,omitempty"`
+	ApprovalId    primitive.ObjectID `bson:"approval_id,omitempty"`
+	AuthorityId   primitive.ObjectID `bson:"authority_id,omitempty"`
 	Timestamp     time.Time          `bson:"timestamp"`
 	State         string             `bson:"state"`
 	PubKey        string             `bson:"pub_key"`
+	RemoteAddr    string             `bson:"remote_addr"`
 }
 
 func (c *Challenge) Approve(db *database.Database, usr *user.User,
@@ -41,6 +44,10 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 	authrIds := []primitive.ObjectID{}
 	authrs := []*authority.Authority{}
 	for _, authr := range allAuthrs {
+		if !c.AuthorityId.IsZero() && authr.Id != c.AuthorityId {
+			continue
+		}
+
 		if authr.UserHasAccess(usr) {
 			authrIds = append(authrIds, authr.Id)
 			authrs = append(authrs, authr)
@@ -64,6 +71,7 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 	}
 
 	requireSmartCard := false
+	approvalAuthrs := set.NewSet()
 	for _, polcy := range policies {
 		if polcy.Disabled {
 			continue
@@ -80,6 +88,12 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 		if polcy.AuthorityRequireSmartCard {
 			requireSmartCard = true
 		}
+
+		if polcy.AuthorityRequireApproval {
+			for _, authrId := range polcy.Authorities {
+				approvalAuthrs.Add(authrId)
+			}
+		}
 	}
 
 	if (deviceAuth && !deviceSec && !secondary) ||
@@ -172,7 +186,54 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 		return
 	}
 
-	cert, err := ssh.NewCertificate(db, authrs, usr, agnt, c.PubKey)
+	// Approvers must have an approver role from each authority that
+	// requires approval
+	approverRoles := [][]string{}
+	for _, authr := range authrs {
+		if !authr.RequireApproval && !approvalAuthrs.Contains(authr.Id) {
+			continue
+		}
+
+		if len(authr.ApproverRoles) == 0 {
+			err = c.Deny(db, usr)
+			if err != nil {
+				return
+			}
+
+			errData = &errortypes.ErrorData{
+				Error:   "approval_unavailable",
+				Message: "Approval required but no approvers are configured",
+			}
+			return
+		}
+
+		approverRoles = append(approverRoles, authr.ApproverRoles)
+	}
+
+	if len(approverRoles) > 0 {
+		err = c.requestApproval(db, usr, authrIds, approverRoles, agnt)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	err = c.issue(db, authrs, usr, agnt, "")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Issue the certificate and update the challenge from the state
+func (c *Challenge) issue(db *database.Database,
+	authrs []*authority.Authority, usr *user.User, agnt *agent.Agent,
+	state string) (err error) {
+
+	cert, err := ssh.NewCertificate(
+		db, authrs, usr, agnt, c.PubKey, c.RemoteAddr)
 	if err != nil {
 		return
 	}
@@ -194,7 +255,7 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 
 	_, err = coll.UpdateOne(db, &bson.M{
 		"_id":   c.Id,
-		"state": "",
+		"state": state,
 	}, &bson.M{
 		"$set": c,
 	})
@@ -269,9 +330,24 @@ func (c *Challenge) Insert(db *database.Database) (err error) {
 	return
 }
 
-func NewChallenge(db *database.Database, pubKey string) (
+func NewChallenge(db *database.Database, pubKey, remoteAddr string) (
 	chal *Challenge, err error) {
 
+	chal, err = newChallenge(db, pubKey, remoteAddr, primitive.NilObjectID)
+	return
+}
+
+// Create a challenge that will only issue a certificate from the authority
+func NewAuthorityChallenge(db *database.Database, pubKey, remoteAddr string,
+	authrId primitive.ObjectID) (chal *Challenge, err error) {
+
+	chal, err = newChallenge(db, pubKey, remoteAddr, authrId)
+	return
+}
+
+func newChallenge(db *database.Database, pubKey, remoteAddr string,
+	authrId primitive.ObjectID) (chal *Challenge, err error) {
+
 	pubKey = strings.TrimSpace(pubKey)
 
 	if len(pubKey) > settings.System.SshPubKeyLen {
@@ -287,9 +363,11 @@ func NewChallenge(db *database.Database, pubKey string) (
 	}
 
 	chal = &Challenge{
-		Id:        token,
-		Timestamp: time.Now(),
-		PubKey:    pubKey,
+		Id:          token,
+		Timestamp:   time.Now(),
+		PubKey:      pubKey,
+		RemoteAddr:  remoteAddr,
+		AuthorityId: authrId,
 	}
 
 	err = chal.Insert(db)
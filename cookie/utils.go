@@ -93,6 +93,23 @@ func getCookieNextDomain(host string) string {
 	return ""
 }
 
+// Shared sessions on wildcard domains are scoped to the wildcard parent
+func getCookieServiceDomain(srvc *service.Service, host string) string {
+	host = utils.StripPort(host)
+
+	for _, domain := range srvc.Domains {
+		if !domain.Wildcard() {
+			continue
+		}
+
+		if _, ok := domain.MatchLabel(host); ok {
+			return "." + domain.Parent()
+		}
+	}
+
+	return getCookieTopDomain(host)
+}
+
 func newProxyStore(srvc *service.Service,
 	r *http.Request) *sessions.CookieStore {
 
@@ -104,7 +121,7 @@ func newProxyStore(srvc *service.Service,
 	cookieStore.Options.HttpOnly = true
 
 	if srvc.ShareSession {
-		cookieStore.Options.Domain = getCookieTopDomain(r.Host)
+		cookieStore.Options.Domain = getCookieServiceDomain(srvc, r.Host)
 	}
 
 	return cookieStore
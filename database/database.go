@@ -80,6 +80,11 @@ func (d *Database) Services() (coll *Collection) {
 	return
 }
 
+func (d *Database) ServiceStats() (coll *Collection) {
+	coll = d.getCollection("service_stats")
+	return
+}
+
 func (d *Database) Policies() (coll *Collection) {
 	coll = d.getCollection("policies")
 	return
@@ -155,6 +160,11 @@ func (d *Database) SshChallenges() (coll *Collection) {
 	return
 }
 
+func (d *Database) SshApprovals() (coll *Collection) {
+	coll = d.getCollection("ssh_approvals")
+	return
+}
+
 func (d *Database) SshCertificates() (coll *Collection) {
 	coll = d.getCollection("ssh_certificates")
 	return
@@ -175,6 +185,41 @@ func (d *Database) Audits() (coll *Collection) {
 	return
 }
 
+func (d *Database) Revocations() (coll *Collection) {
+	coll = d.getCollection("revocations")
+	return
+}
+
+func (d *Database) Hosts() (coll *Collection) {
+	coll = d.getCollection("hosts")
+	return
+}
+
+func (d *Database) HostTokens() (coll *Collection) {
+	coll = d.getCollection("host_tokens")
+	return
+}
+
+func (d *Database) SshTerminals() (coll *Collection) {
+	coll = d.getCollection("ssh_terminals")
+	return
+}
+
+func (d *Database) Recordings() (coll *Collection) {
+	coll = d.getCollection("recordings")
+	return
+}
+
+func (d *Database) RecordingChunks() (coll *Collection) {
+	coll = d.getCollection("recording_chunks")
+	return
+}
+
+func (d *Database) RecordingCommands() (coll *Collection) {
+	coll = d.getCollection("recording_commands")
+	return
+}
+
 func (d *Database) Geo() (coll *Collection) {
 	coll = d.getCollection("geo")
 	return
@@ -355,6 +400,19 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.ServiceStats(),
+		Keys: &bson.D{
+			{"service", 1},
+			{"group", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Policies(),
 		Keys: &bson.D{
@@ -457,6 +515,66 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshTerminals(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 6 * time.Minute,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Recordings(),
+		Keys: &bson.D{
+			{"user_id", 1},
+			{"start", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.RecordingChunks(),
+		Keys: &bson.D{
+			{"recording_id", 1},
+			{"index", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.RecordingCommands(),
+		Keys: &bson.D{
+			{"user_id", 1},
+			{"timestamp", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.RecordingCommands(),
+		Keys: &bson.D{
+			{"recording_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Devices(),
 		Keys: &bson.D{
@@ -500,6 +618,17 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.Authorities(),
+		Keys: &bson.D{
+			{"hsm_agents.token", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.SshChallenges(),
 		Keys: &bson.D{
@@ -512,6 +641,42 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshApprovals(),
+		Keys: &bson.D{
+			{"state", 1},
+			{"approver_roles", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshApprovals(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 24 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Certificates(),
+		Keys: &bson.D{
+			{"auto", 1},
+			{"acme_domains", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.SshCertificates(),
 		Keys: &bson.D{
@@ -524,6 +689,87 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshCertificates(),
+		Keys: &bson.D{
+			{"user_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Revocations(),
+		Keys: &bson.D{
+			{"type", 1},
+			{"authority_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Hosts(),
+		Keys: &bson.D{
+			{"authority_id", 1},
+			{"hostname", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Hosts(),
+		Keys: &bson.D{
+			{"hostname", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Hosts(),
+		Keys: &bson.D{
+			{"expires", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.HostTokens(),
+		Keys: &bson.D{
+			{"token", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.HostTokens(),
+		Keys: &bson.D{
+			{"authority_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Devices(),
 		Keys: &bson.D{
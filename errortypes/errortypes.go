@@ -44,6 +44,10 @@ type ExecError struct {
 	errors.DropboxError
 }
 
+type VerificationError struct {
+	errors.DropboxError
+}
+
 type ErrorData struct {
 	Error   string `json:"error"`
 	Message string `json:"error_msg"`
@@ -0,0 +1,7 @@
+package expiry
+
+const (
+	Certificate     = "certificate"
+	ServiceUpstream = "service_upstream"
+	AuthorityRoot   = "authority_root"
+)
@@ -0,0 +1,205 @@
+package expiry
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/certificate"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/node"
+	"github.com/hydeant/pritunl-zero/service"
+	"github.com/hydeant/pritunl-zero/settings"
+)
+
+type Expiry struct {
+	Type       string             `bson:"type" json:"type"`
+	ResourceId primitive.ObjectID `bson:"resource_id" json:"resource_id"`
+	Name       string             `bson:"name" json:"name"`
+	Subject    string             `bson:"subject" json:"subject"`
+	ExpiresOn  time.Time          `bson:"expires_on" json:"expires_on"`
+	Days       int                `bson:"days" json:"days"`
+	Node       primitive.ObjectID `bson:"node,omitempty" json:"-"`
+}
+
+func (e *Expiry) Expired() bool {
+	return time.Now().After(e.ExpiresOn)
+}
+
+func newExpiry(typ string, resourceId primitive.ObjectID, name string,
+	subject string, expiresOn time.Time, now time.Time) *Expiry {
+
+	return &Expiry{
+		Type:       typ,
+		ResourceId: resourceId,
+		Name:       name,
+		Subject:    subject,
+		ExpiresOn:  expiresOn,
+		Days:       int(math.Floor(expiresOn.Sub(now).Hours() / 24)),
+	}
+}
+
+func parseCerts(data string) (certs []*x509.Certificate) {
+	certs = []*x509.Certificate{}
+	rest := []byte(data)
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return
+}
+
+func GetAll(db *database.Database) (expiries []*Expiry, err error) {
+	expiries = []*Expiry{}
+	now := time.Now()
+
+	certs, err := certificate.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, cert := range certs {
+		if cert.Info == nil || cert.Info.ExpiresOn.IsZero() {
+			continue
+		}
+
+		subject := ""
+		if len(cert.Info.DnsNames) > 0 {
+			subject = cert.Info.DnsNames[0]
+		}
+
+		expiries = append(expiries, newExpiry(
+			Certificate, cert.Id, cert.Name, subject,
+			cert.Info.ExpiresOn, now))
+	}
+
+	srvcs, err := service.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, srvc := range srvcs {
+		for _, cert := range parseCerts(srvc.UpstreamCerts) {
+			expiries = append(expiries, newExpiry(
+				ServiceUpstream, srvc.Id, srvc.Name,
+				cert.Subject.CommonName, cert.NotAfter, now))
+		}
+	}
+
+	authrs, err := authority.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, authr := range authrs {
+		for _, cert := range parseCerts(authr.RootCertificate) {
+			expiries = append(expiries, newExpiry(
+				AuthorityRoot, authr.Id, authr.Name,
+				cert.Subject.CommonName, cert.NotAfter, now))
+		}
+	}
+
+	sort.Slice(expiries, func(i, j int) bool {
+		return expiries[i].ExpiresOn.Before(expiries[j].ExpiresOn)
+	})
+
+	return
+}
+
+// Get certificates expiring within days including expired certificates
+func GetExpiring(db *database.Database, days int) (
+	expiries []*Expiry, err error) {
+
+	all, err := GetAll(db)
+	if err != nil {
+		return
+	}
+
+	expiries = []*Expiry{}
+	for _, exp := range all {
+		if exp.Days < days {
+			expiries = append(expiries, exp)
+		}
+	}
+
+	return
+}
+
+// Warn for certificates reaching a threshold today, expired certificates
+// are reported on every check
+func Check(db *database.Database) (err error) {
+	thresholds := settings.System.CertificateExpiryWarn
+	maxThreshold := 0
+	for _, threshold := range thresholds {
+		if threshold > maxThreshold {
+			maxThreshold = threshold
+		}
+	}
+
+	expiries, err := GetExpiring(db, maxThreshold+1)
+	if err != nil {
+		return
+	}
+
+	for _, exp := range expiries {
+		warn := exp.Expired()
+		for _, threshold := range thresholds {
+			if exp.Days == threshold {
+				warn = true
+				break
+			}
+		}
+
+		if !warn {
+			continue
+		}
+
+		fields := logrus.Fields{
+			"type":        exp.Type,
+			"resource_id": exp.ResourceId.Hex(),
+			"name":        exp.Name,
+			"subject":     exp.Subject,
+			"expires_on":  exp.ExpiresOn,
+			"days":        exp.Days,
+		}
+
+		if exp.Expired() {
+			logrus.WithFields(fields).Error(
+				"expiry: Certificate has expired")
+		} else {
+			logrus.WithFields(fields).Warn(
+				"expiry: Certificate expiring soon")
+		}
+
+		exp.Node = node.Self.Id
+
+		err = event.Publish(db, "certificate_expiry", exp)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,112 @@
+package expiry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/node"
+	"github.com/hydeant/pritunl-zero/requires"
+	"github.com/hydeant/pritunl-zero/settings"
+)
+
+var (
+	client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+)
+
+func notify(exp *Expiry) (err error) {
+	webhook := settings.System.CertificateExpiryWebhook
+	if webhook == "" {
+		return
+	}
+
+	reqData := &bytes.Buffer{}
+	err = json.NewEncoder(reqData).Encode(exp)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "expiry: Failed to parse request data"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest(
+		"POST",
+		webhook,
+		reqData,
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "expiry: Failed to create request"),
+		}
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "expiry: Failed to send request"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = &errortypes.RequestError{
+			errors.Newf(
+				"expiry: Request failed with status %d", resp.StatusCode),
+		}
+		return
+	}
+
+	return
+}
+
+func callback(evt *event.EventPublish) {
+	data, err := bson.Marshal(evt.Data)
+	if err != nil {
+		return
+	}
+
+	exp := &Expiry{}
+	err = bson.Unmarshal(data, exp)
+	if err != nil {
+		return
+	}
+
+	// Every node receives the event, only the node that ran the check
+	// sends the notification
+	if node.Self == nil || exp.Node != node.Self.Id {
+		return
+	}
+
+	err = notify(exp)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"type":        exp.Type,
+			"resource_id": exp.ResourceId.Hex(),
+			"name":        exp.Name,
+			"error":       err,
+		}).Error("expiry: Failed to send expiry notification")
+	}
+}
+
+func init() {
+	module := requires.New("expiry")
+	module.After("settings")
+	module.Before("event")
+
+	module.Handler = func() (err error) {
+		event.Register("certificate_expiry", callback)
+		return
+	}
+}
@@ -0,0 +1,8 @@
+package hosttoken
+
+const (
+	DefaultTtl     = 24
+	MaxTtl         = 8760
+	RenewalTtl     = 720
+	MaxRedemptions = 100
+)
@@ -0,0 +1,310 @@
+// Host bootstrap tokens for SSH host certificates.
+package hosttoken
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type Redemption struct {
+	Hostname   string    `bson:"hostname" json:"hostname"`
+	RemoteAddr string    `bson:"remote_addr" json:"remote_addr"`
+	Timestamp  time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+type Token struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AuthorityId primitive.ObjectID `bson:"authority_id" json:"authority_id"`
+	Name        string             `bson:"name" json:"name"`
+	Token       string             `bson:"token" json:"token"`
+	SingleUse   bool               `bson:"single_use" json:"single_use"`
+	HostMatches []string           `bson:"host_matches" json:"host_matches"`
+	HostSubnets []string           `bson:"host_subnets" json:"host_subnets"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+	Expires     time.Time          `bson:"expires" json:"expires"`
+	Revoked     bool               `bson:"revoked" json:"revoked"`
+	Renewal     bool               `bson:"renewal" json:"renewal"`
+	ParentId    primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id"`
+	Redemptions []*Redemption      `bson:"redemptions" json:"redemptions"`
+}
+
+// Token has not expired, been revoked or been used when single use
+func (t *Token) Active() bool {
+	if t.Revoked || time.Now().After(t.Expires) {
+		return false
+	}
+
+	if t.SingleUse && len(t.Redemptions) > 0 {
+		return false
+	}
+
+	return true
+}
+
+// Check the hostname against the host matches and the remote address
+// against the host subnets, empty restrictions permit all
+func (t *Token) Permitted(hostname, remoteAddr string) bool {
+	if len(t.HostMatches) > 0 {
+		hostname = strings.ToLower(hostname)
+		matched := false
+
+		for _, match := range t.HostMatches {
+			if utils.Match(strings.ToLower(match), hostname) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(t.HostSubnets) > 0 {
+		ip := net.ParseIP(remoteAddr)
+		if ip == nil {
+			return false
+		}
+
+		matched := false
+
+		for _, hostSubnet := range t.HostSubnets {
+			_, subnet, err := net.ParseCIDR(hostSubnet)
+			if err != nil {
+				continue
+			}
+
+			if subnet.Contains(ip) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Record the redemption, single use tokens can only be redeemed once and
+// renewal tokens are extended on each redemption. Only the most recent
+// redemptions are stored.
+func (t *Token) Redeem(db *database.Database, hostname,
+	remoteAddr string) (redeemed bool, err error) {
+
+	coll := db.HostTokens()
+
+	redemption := &Redemption{
+		Hostname:   hostname,
+		RemoteAddr: remoteAddr,
+		Timestamp:  time.Now(),
+	}
+
+	query := bson.M{
+		"_id":     t.Id,
+		"revoked": false,
+		"expires": &bson.M{
+			"$gt": redemption.Timestamp,
+		},
+	}
+	if t.SingleUse {
+		query["redemptions.0"] = &bson.M{
+			"$exists": false,
+		}
+	}
+
+	update := bson.M{
+		"$push": &bson.M{
+			"redemptions": &bson.M{
+				"$each": []*Redemption{
+					redemption,
+				},
+				"$slice": -MaxRedemptions,
+			},
+		},
+	}
+
+	expires := t.Expires
+	if t.Renewal {
+		expires = redemption.Timestamp.Add(RenewalTtl * time.Hour)
+		update["$set"] = &bson.M{
+			"expires": expires,
+		}
+	}
+
+	resp, err := coll.UpdateOne(db, query, update)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		return
+	}
+
+	t.Expires = expires
+	t.Redemptions = append(t.Redemptions, redemption)
+	if len(t.Redemptions) > MaxRedemptions {
+		t.Redemptions = t.Redemptions[len(t.Redemptions)-MaxRedemptions:]
+	}
+	redeemed = true
+
+	return
+}
+
+func (t *Token) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if t.AuthorityId.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "authority_required",
+			Message: "Missing required authority",
+		}
+		return
+	}
+
+	if t.Name == "" {
+		t.Name = "Host Token"
+	}
+
+	if t.HostMatches == nil {
+		t.HostMatches = []string{}
+	}
+
+	if t.HostSubnets == nil {
+		t.HostSubnets = []string{}
+	}
+
+	if t.Redemptions == nil {
+		t.Redemptions = []*Redemption{}
+	}
+
+	for _, hostSubnet := range t.HostSubnets {
+		_, _, e := net.ParseCIDR(hostSubnet)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "subnet_invalid",
+				Message: "Host subnet is invalid",
+			}
+			return
+		}
+	}
+
+	if t.Expires.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "expires_invalid",
+			Message: "Token expiration is required",
+		}
+		return
+	}
+
+	return
+}
+
+func (t *Token) Commit(db *database.Database) (err error) {
+	coll := db.HostTokens()
+
+	err = coll.Commit(t.Id, t)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Token) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.HostTokens()
+
+	err = coll.CommitFields(t.Id, t, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Token) Insert(db *database.Database) (err error) {
+	coll := db.HostTokens()
+
+	if !t.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("hosttoken: Token already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Create a renewal token for the host that redeemed the single use token,
+// the renewal token keeps the subnet restrictions of the token
+func (t *Token) NewRenewal(db *database.Database, hostname string) (
+	tokn *Token, err error) {
+
+	tokn, err = New(t.AuthorityId, "Renewal "+hostname, false,
+		[]string{hostname}, t.HostSubnets, RenewalTtl)
+	if err != nil {
+		return
+	}
+
+	tokn.Renewal = true
+	tokn.ParentId = t.Id
+
+	err = tokn.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func New(authrId primitive.ObjectID, name string, singleUse bool,
+	hostMatches, hostSubnets []string, ttl int) (
+	tokn *Token, err error) {
+
+	token, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+
+	if ttl < 1 {
+		ttl = DefaultTtl
+	} else if ttl > MaxTtl {
+		ttl = MaxTtl
+	}
+
+	now := time.Now()
+
+	tokn = &Token{
+		AuthorityId: authrId,
+		Name:        name,
+		Token:       token,
+		SingleUse:   singleUse,
+		HostMatches: hostMatches,
+		HostSubnets: hostSubnets,
+		Timestamp:   now,
+		Expires:     now.Add(time.Duration(ttl) * time.Hour),
+		Redemptions: []*Redemption{},
+	}
+
+	return
+}
@@ -0,0 +1,144 @@
+package hosttoken
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+func Get(db *database.Database, toknId primitive.ObjectID) (
+	tokn *Token, err error) {
+
+	coll := db.HostTokens()
+	tokn = &Token{}
+
+	err = coll.FindOneId(toknId, tokn)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, authrId primitive.ObjectID) (
+	tokns []*Token, err error) {
+
+	coll := db.HostTokens()
+	tokns = []*Token{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"authority_id": authrId,
+	}, &options.FindOptions{
+		Sort: &bson.D{
+			{"timestamp", -1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tokn := &Token{}
+		err = cursor.Decode(tokn)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		tokns = append(tokns, tokn)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Get the active tokens matching the token values
+func GetTokens(db *database.Database, tokens []string) (
+	tokns []*Token, err error) {
+
+	coll := db.HostTokens()
+	tokns = []*Token{}
+
+	if len(tokens) == 0 {
+		return
+	}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"token": &bson.M{
+			"$in": tokens,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tokn := &Token{}
+		err = cursor.Decode(tokn)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		if !tokn.Active() {
+			continue
+		}
+
+		tokns = append(tokns, tokn)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Revoke(db *database.Database, authrId, toknId primitive.ObjectID) (
+	err error) {
+
+	coll := db.HostTokens()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":          toknId,
+		"authority_id": authrId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"revoked": true,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveAuthority(db *database.Database, authrId primitive.ObjectID) (
+	err error) {
+
+	coll := db.HostTokens()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"authority_id": authrId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+// Inventory of SSH hosts from host certificate requests.
+package inventory
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/agent"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+type Host struct {
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Hostname      string             `bson:"hostname" json:"hostname"`
+	AuthorityId   primitive.ObjectID `bson:"authority_id" json:"authority_id"`
+	Address       string             `bson:"address" json:"address"`
+	PubKey        string             `bson:"pub_key" json:"pub_key"`
+	CertificateId primitive.ObjectID `bson:"certificate_id" json:"certificate_id"`
+	Agent         *agent.Agent       `bson:"agent" json:"agent"`
+	Timestamp     time.Time          `bson:"timestamp" json:"timestamp"`
+	LastRenewal   time.Time          `bson:"last_renewal" json:"last_renewal"`
+	Expires       time.Time          `bson:"expires" json:"expires"`
+	Stale         bool               `bson:"-" json:"stale"`
+}
+
+// Host has not renewed the host certificate before it expired
+func (h *Host) IsStale() bool {
+	return !h.Expires.IsZero() && time.Now().After(h.Expires)
+}
+
+func (h *Host) Json() {
+	h.Stale = h.IsStale()
+}
+
+func (h *Host) Commit(db *database.Database) (err error) {
+	coll := db.Hosts()
+
+	err = coll.Commit(h.Id, h)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (h *Host) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Hosts()
+
+	err = coll.CommitFields(h.Id, h, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
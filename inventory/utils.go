@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/ssh"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+// Update the hosts for each authority from an issued host certificate,
+// hosts are identified by the authority and hostname
+func Sync(db *database.Database, hostname, remoteAddr string,
+	cert *ssh.Certificate) (hsts []*Host, err error) {
+
+	coll := db.Hosts()
+	now := time.Now()
+	hsts = []*Host{}
+
+	expires := time.Time{}
+	for _, info := range cert.CertificatesInfo {
+		if expires.IsZero() || info.Expires.Before(expires) {
+			expires = info.Expires
+		}
+	}
+
+	fields := bson.M{
+		"pub_key":        cert.PubKey,
+		"certificate_id": cert.Id,
+		"agent":          cert.Agent,
+		"last_renewal":   now,
+		"expires":        expires,
+	}
+	if remoteAddr != "" {
+		fields["address"] = remoteAddr
+	}
+
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	opts.SetReturnDocument(options.After)
+
+	for _, authrId := range cert.AuthorityIds {
+		hst := &Host{}
+		err = coll.FindOneAndUpdate(
+			db,
+			&bson.M{
+				"authority_id": authrId,
+				"hostname":     hostname,
+			},
+			&bson.M{
+				"$set": fields,
+				"$setOnInsert": &bson.M{
+					"timestamp": now,
+				},
+			},
+			opts,
+		).Decode(hst)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		hsts = append(hsts, hst)
+	}
+
+	return
+}
+
+func Get(db *database.Database, hostId primitive.ObjectID) (
+	hst *Host, err error) {
+
+	coll := db.Hosts()
+	hst = &Host{}
+
+	err = coll.FindOneId(hostId, hst)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M, page, pageCount int64) (
+	hsts []*Host, count int64, err error) {
+
+	coll := db.Hosts()
+	hsts = []*Host{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	opts := options.FindOptions{
+		Sort: &bson.D{
+			{"hostname", 1},
+		},
+	}
+
+	if pageCount != 0 {
+		page = utils.Min64(page, count/pageCount)
+		skip := utils.Min64(page*pageCount, count)
+		opts.Skip = &skip
+		opts.Limit = &pageCount
+	}
+
+	cursor, err := coll.Find(db, query, &opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		hst := &Host{}
+		err = cursor.Decode(hst)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		hsts = append(hsts, hst)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, hostId primitive.ObjectID) (err error) {
+	coll := db.Hosts()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": hostId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
@@ -6,34 +6,50 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
 	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/authorizer"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
 	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/hosttoken"
+	"github.com/hydeant/pritunl-zero/revocation"
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
 type authorityData struct {
-	Id                 primitive.ObjectID `json:"id"`
-	Name               string             `json:"name"`
-	Type               string             `json:"type"`
-	Expire             int                `json:"expire"`
-	HostExpire         int                `json:"host_expire"`
-	MatchRoles         bool               `json:"match_roles"`
-	Roles              []string           `json:"roles"`
-	ProxyHosting       bool               `json:"proxy_hosting"`
-	ProxyHostname      string             `json:"proxy_hostname"`
-	ProxyPort          int                `json:"proxy_port"`
-	HostDomain         string             `json:"host_domain"`
-	HostMatches        []string           `json:"host_matches"`
-	HostSubnets        []string           `json:"host_subnets"`
-	HostProxy          string             `json:"host_proxy"`
-	HostCertificates   bool               `json:"host_certificates"`
-	StrictHostChecking bool               `json:"strict_host_checking"`
-	HsmToken           string             `json:"hsm_token"`
-	HsmSecret          string             `json:"hsm_secret"`
-	HsmSerial          string             `json:"hsm_serial"`
-	HsmGenerateSecret  bool               `json:"hsm_generate_secret"`
+	Id                     primitive.ObjectID        `json:"id"`
+	Name                   string                    `json:"name"`
+	Type                   string                    `json:"type"`
+	Expire                 int                       `json:"expire"`
+	HostExpire             int                       `json:"host_expire"`
+	ServiceCertificates    bool                      `json:"service_certificates"`
+	ServiceExpire          int                       `json:"service_expire"`
+	RequireApproval        bool                      `json:"require_approval"`
+	ApproverRoles          []string                  `json:"approver_roles"`
+	MatchRoles             bool                      `json:"match_roles"`
+	Roles                  []string                  `json:"roles"`
+	ProxyHosting           bool                      `json:"proxy_hosting"`
+	ProxyHostname          string                    `json:"proxy_hostname"`
+	ProxyPort              int                       `json:"proxy_port"`
+	ProxyRecording         bool                      `json:"proxy_recording"`
+	HostDomain             string                    `json:"host_domain"`
+	HostMatches            []string                  `json:"host_matches"`
+	HostSubnets            []string                  `json:"host_subnets"`
+	HostProxy              string                    `json:"host_proxy"`
+	HostCertificates       bool                      `json:"host_certificates"`
+	StrictHostChecking     bool                      `json:"strict_host_checking"`
+	HsmToken               string                    `json:"hsm_token"`
+	HsmSecret              string                    `json:"hsm_secret"`
+	HsmSerial              string                    `json:"hsm_serial"`
+	HsmGenerateSecret      bool                      `json:"hsm_generate_secret"`
+	KeyAlgorithm           string                    `json:"key_algorithm"`
+	RotationGrace          int                       `json:"rotation_grace"`
+	RotationRetire         int                       `json:"rotation_retire"`
+	PrincipalTemplates     []string                  `json:"principal_templates"`
+	HostPrincipalTemplates []string                  `json:"host_principal_templates"`
+	RoleTemplates          []*authority.RoleTemplate `json:"role_templates"`
 }
 
 func authorityPut(c *gin.Context) {
@@ -63,7 +79,8 @@ func authorityPut(c *gin.Context) {
 	}
 
 	showSecret := false
-	if authr.Type != data.Type {
+	typeChanged := authr.Type != data.Type
+	if typeChanged {
 		if data.Type == authority.PritunlHsm {
 			err = authr.GenerateHsmToken()
 			if err != nil {
@@ -78,6 +95,10 @@ func authorityPut(c *gin.Context) {
 	authr.Type = data.Type
 	authr.Expire = data.Expire
 	authr.HostExpire = data.HostExpire
+	authr.ServiceCertificates = data.ServiceCertificates
+	authr.ServiceExpire = data.ServiceExpire
+	authr.RequireApproval = data.RequireApproval
+	authr.ApproverRoles = data.ApproverRoles
 	authr.MatchRoles = data.MatchRoles
 	authr.Roles = data.Roles
 
@@ -91,6 +112,7 @@ func authorityPut(c *gin.Context) {
 	authr.ProxyHosting = data.ProxyHosting
 	authr.ProxyHostname = data.ProxyHostname
 	authr.ProxyPort = data.ProxyPort
+	authr.ProxyRecording = data.ProxyRecording
 	authr.HostMatches = data.HostMatches
 	authr.HostSubnets = data.HostSubnets
 	authr.HostDomain = data.HostDomain
@@ -98,6 +120,11 @@ func authorityPut(c *gin.Context) {
 	authr.HostCertificates = data.HostCertificates
 	authr.StrictHostChecking = data.StrictHostChecking
 	authr.HsmSerial = data.HsmSerial
+	authr.RotationGrace = data.RotationGrace
+	authr.RotationRetire = data.RotationRetire
+	authr.PrincipalTemplates = data.PrincipalTemplates
+	authr.HostPrincipalTemplates = data.HostPrincipalTemplates
+	authr.RoleTemplates = data.RoleTemplates
 
 	if authr.Type == authority.PritunlHsm && data.HsmGenerateSecret {
 		err = authr.GenerateHsmToken()
@@ -113,6 +140,10 @@ func authorityPut(c *gin.Context) {
 		"type",
 		"expire",
 		"host_expire",
+		"service_certificates",
+		"service_expire",
+		"require_approval",
+		"approver_roles",
 		"public_key",
 		"public_key_pem",
 		"root_certificate",
@@ -123,6 +154,7 @@ func authorityPut(c *gin.Context) {
 		"proxy_hosting",
 		"proxy_hostname",
 		"proxy_port",
+		"proxy_recording",
 		"host_domain",
 		"host_matches",
 		"host_subnets",
@@ -133,8 +165,23 @@ func authorityPut(c *gin.Context) {
 		"hsm_token",
 		"hsm_secret",
 		"hsm_serial",
+		"rotation_grace",
+		"rotation_retire",
+		"principal_templates",
+		"host_principal_templates",
+		"role_templates",
 	)
 
+	// Rotation state is only cleared when the key type changes, otherwise
+	// rotation fields are left to the rotation task
+	if typeChanged {
+		fields.Add("next_private_key")
+		fields.Add("next_public_key")
+		fields.Add("previous_public_key")
+		fields.Add("rotation_status")
+		fields.Add("rotation_timestamp")
+	}
+
 	errData, err := authr.Validate(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -180,23 +227,45 @@ func authorityPost(c *gin.Context) {
 	}
 
 	authr := &authority.Authority{
-		Name:               data.Name,
-		Type:               data.Type,
-		Expire:             data.Expire,
-		HostExpire:         data.HostExpire,
-		MatchRoles:         data.MatchRoles,
-		Roles:              data.Roles,
-		ProxyHosting:       data.ProxyHosting,
-		ProxyHostname:      data.ProxyHostname,
-		ProxyPort:          data.ProxyPort,
-		HostDomain:         data.HostDomain,
-		HostMatches:        data.HostMatches,
-		HostSubnets:        data.HostSubnets,
-		StrictHostChecking: data.StrictHostChecking,
-	}
-
-	err = authr.GenerateRsaPrivateKey()
+		Name:                   data.Name,
+		Type:                   data.Type,
+		Expire:                 data.Expire,
+		HostExpire:             data.HostExpire,
+		ServiceCertificates:    data.ServiceCertificates,
+		ServiceExpire:          data.ServiceExpire,
+		RequireApproval:        data.RequireApproval,
+		ApproverRoles:          data.ApproverRoles,
+		MatchRoles:             data.MatchRoles,
+		Roles:                  data.Roles,
+		ProxyHosting:           data.ProxyHosting,
+		ProxyHostname:          data.ProxyHostname,
+		ProxyPort:              data.ProxyPort,
+		ProxyRecording:         data.ProxyRecording,
+		HostDomain:             data.HostDomain,
+		HostMatches:            data.HostMatches,
+		HostSubnets:            data.HostSubnets,
+		StrictHostChecking:     data.StrictHostChecking,
+		RotationGrace:          data.RotationGrace,
+		RotationRetire:         data.RotationRetire,
+		PrincipalTemplates:     data.PrincipalTemplates,
+		HostPrincipalTemplates: data.HostPrincipalTemplates,
+		RoleTemplates:          data.RoleTemplates,
+	}
+
+	if data.KeyAlgorithm != "" &&
+		!authority.KeyAlgorithms.Contains(data.KeyAlgorithm) {
+
+		errData := &errortypes.ErrorData{
+			Error:   "key_algorithm_invalid",
+			Message: "Authority key algorithm is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = authr.GeneratePrivateKey(data.KeyAlgorithm)
 	if err != nil {
+		utils.AbortWithError(c, 500, err)
 		return
 	}
 
@@ -243,6 +312,12 @@ func authorityDelete(c *gin.Context) {
 		return
 	}
 
+	err = hosttoken.RemoveAuthority(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, nil)
@@ -334,12 +409,54 @@ func authorityPublicKeyGet(c *gin.Context) {
 	}
 
 	for _, authr := range authrs {
-		publicKeys += strings.TrimSpace(authr.PublicKey) + "\n"
+		for _, publicKey := range authr.TrustedPublicKeys() {
+			publicKeys += publicKey + "\n"
+		}
 	}
 
 	c.String(200, publicKeys)
 }
 
+func authorityKrlGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	authrIdsStr := strings.Split(c.Param("authr_ids"), ",")
+	authrIds := []primitive.ObjectID{}
+
+	for _, authrIdStr := range authrIdsStr {
+		if authrIdStr == "" {
+			continue
+		}
+
+		authrId, ok := utils.ParseObjectId(authrIdStr)
+		if !ok {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+
+		authrIds = append(authrIds, authrId)
+	}
+
+	if len(authrIds) == 0 {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	authrs, err := authority.GetMulti(db, authrIds)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data, err := revocation.GenerateKrl(db, authrs)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Data(200, "application/octet-stream", data)
+}
+
 func authorityTokenPost(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -417,3 +534,145 @@ func authorityTokenDelete(c *gin.Context) {
 
 	c.Status(200)
 }
+
+func authorityRotatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrty, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := authrty.RotateStart()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = authrty.CommitFields(db, set.NewSet(
+		"next_private_key",
+		"next_public_key",
+		"rotation_status",
+		"rotation_timestamp",
+	))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.AuthorityRotateStart,
+		audit.Fields{
+			"authority_id":    authrty.Id.Hex(),
+			"authority_name":  authrty.Name,
+			"next_public_key": authrty.NextPublicKey,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "authority.change")
+
+	authrty.Json()
+	authrty.HsmSecret = ""
+
+	c.JSON(200, authrty)
+}
+
+func authorityRotateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrty, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	nextPublicKey := authrty.NextPublicKey
+
+	errData := authrty.RotateCancel()
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = authrty.CommitFields(db, set.NewSet(
+		"next_private_key",
+		"next_public_key",
+		"previous_public_key",
+		"rotation_status",
+		"rotation_timestamp",
+	))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.AuthorityRotateCancel,
+		audit.Fields{
+			"authority_id":    authrty.Id.Hex(),
+			"authority_name":  authrty.Name,
+			"next_public_key": nextPublicKey,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "authority.change")
+
+	authrty.Json()
+	authrty.HsmSecret = ""
+
+	c.JSON(200, authrty)
+}
@@ -13,12 +13,24 @@ import (
 )
 
 type certificateData struct {
-	Id          primitive.ObjectID `json:"id"`
-	Name        string             `json:"name"`
-	Type        string             `json:"type"`
-	Key         string             `json:"key"`
-	Certificate string             `json:"certificate"`
-	AcmeDomains []string           `json:"acme_domains"`
+	Id                  primitive.ObjectID `json:"id"`
+	Name                string             `json:"name"`
+	Type                string             `json:"type"`
+	Key                 string             `json:"key"`
+	Certificate         string             `json:"certificate"`
+	AcmeDomains         []string           `json:"acme_domains"`
+	AcmeAuth            string             `json:"acme_auth"`
+	AcmeDnsProvider     string             `json:"acme_dns_provider"`
+	AcmeDnsServer       string             `json:"acme_dns_server"`
+	AcmeDnsKeyName      string             `json:"acme_dns_key_name"`
+	AcmeDnsKeySecret    string             `json:"acme_dns_key_secret"`
+	AcmeDnsKeyAlgorithm string             `json:"acme_dns_key_algorithm"`
+	AcmeDirectory       string             `json:"acme_directory"`
+	AcmeCaBundle        string             `json:"acme_ca_bundle"`
+	AcmeEabKid          string             `json:"acme_eab_kid"`
+	AcmeEabKey          string             `json:"acme_eab_key"`
+	AcmeEmail           string             `json:"acme_email"`
+	AcmeKeyAlgorithm    string             `json:"acme_key_algorithm"`
 }
 
 func certificatePut(c *gin.Context) {
@@ -50,11 +62,41 @@ func certificatePut(c *gin.Context) {
 	cert.Name = data.Name
 	cert.Type = data.Type
 	cert.AcmeDomains = data.AcmeDomains
+	cert.AcmeAuth = data.AcmeAuth
+	cert.AcmeDnsProvider = data.AcmeDnsProvider
+	cert.AcmeDnsServer = data.AcmeDnsServer
+	cert.AcmeDnsKeyName = data.AcmeDnsKeyName
+	cert.AcmeDnsKeyAlgorithm = data.AcmeDnsKeyAlgorithm
+	cert.AcmeDirectory = data.AcmeDirectory
+	cert.AcmeCaBundle = data.AcmeCaBundle
+	cert.AcmeEabKid = data.AcmeEabKid
+	cert.AcmeEmail = data.AcmeEmail
+	cert.AcmeKeyAlgorithm = data.AcmeKeyAlgorithm
+
+	if data.AcmeDnsKeySecret != "" {
+		cert.AcmeDnsKeySecret = data.AcmeDnsKeySecret
+	}
+
+	if data.AcmeEabKey != "" {
+		cert.AcmeEabKey = data.AcmeEabKey
+	}
 
 	fields := set.NewSet(
 		"name",
 		"type",
 		"acme_domains",
+		"acme_auth",
+		"acme_dns_provider",
+		"acme_dns_server",
+		"acme_dns_key_name",
+		"acme_dns_key_secret",
+		"acme_dns_key_algorithm",
+		"acme_directory",
+		"acme_ca_bundle",
+		"acme_eab_kid",
+		"acme_eab_key",
+		"acme_email",
+		"acme_key_algorithm",
 		"info",
 	)
 
@@ -118,9 +160,21 @@ func certificatePost(c *gin.Context) {
 	}
 
 	cert := &certificate.Certificate{
-		Name:        data.Name,
-		Type:        data.Type,
-		AcmeDomains: data.AcmeDomains,
+		Name:                data.Name,
+		Type:                data.Type,
+		AcmeDomains:         data.AcmeDomains,
+		AcmeAuth:            data.AcmeAuth,
+		AcmeDnsProvider:     data.AcmeDnsProvider,
+		AcmeDnsServer:       data.AcmeDnsServer,
+		AcmeDnsKeyName:      data.AcmeDnsKeyName,
+		AcmeDnsKeySecret:    data.AcmeDnsKeySecret,
+		AcmeDnsKeyAlgorithm: data.AcmeDnsKeyAlgorithm,
+		AcmeDirectory:       data.AcmeDirectory,
+		AcmeCaBundle:        data.AcmeCaBundle,
+		AcmeEabKid:          data.AcmeEabKid,
+		AcmeEabKey:          data.AcmeEabKey,
+		AcmeEmail:           data.AcmeEmail,
+		AcmeKeyAlgorithm:    data.AcmeKeyAlgorithm,
 	}
 
 	if cert.Type != certificate.LetsEncrypt {
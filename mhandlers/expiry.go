@@ -0,0 +1,34 @@
+package mhandlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/expiry"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+func expiryGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	days := settings.System.CertificateExpiryDays
+	daysStr := c.Query("days")
+	if daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d < 0 {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+		days = d
+	}
+
+	expiries, err := expiry.GetExpiring(db, days)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, expiries)
+}
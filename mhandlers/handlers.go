@@ -61,7 +61,17 @@ func Register(engine *gin.Engine) {
 	csrfGroup.POST("/authority/:authr_id/token", authorityTokenPost)
 	csrfGroup.DELETE("/authority/:authr_id/token/:token",
 		authorityTokenDelete)
+	csrfGroup.GET("/authority/:authr_id/host_token", hostTokensGet)
+	csrfGroup.POST("/authority/:authr_id/host_token", hostTokenPost)
+	csrfGroup.DELETE("/authority/:authr_id/host_token/:token_id",
+		hostTokenDelete)
+	csrfGroup.POST("/authority/:authr_id/hsm_agent", hsmAgentPost)
+	csrfGroup.DELETE("/authority/:authr_id/hsm_agent/:agent_id",
+		hsmAgentDelete)
+	csrfGroup.POST("/authority/:authr_id/rotate", authorityRotatePost)
+	csrfGroup.DELETE("/authority/:authr_id/rotate", authorityRotateDelete)
 	dbGroup.GET("/ssh_public_key/:authr_ids", authorityPublicKeyGet)
+	dbGroup.GET("/ssh_krl/:authr_ids", authorityKrlGet)
 
 	csrfGroup.GET("/certificate", certificatesGet)
 	csrfGroup.GET("/certificate/:cert_id", certificateGet)
@@ -69,6 +79,8 @@ func Register(engine *gin.Engine) {
 	csrfGroup.POST("/certificate", certificatePost)
 	csrfGroup.DELETE("/certificate/:cert_id", certificateDelete)
 
+	csrfGroup.GET("/expiry", expiryGet)
+
 	engine.GET("/check", checkGet)
 
 	authGroup.GET("/csrf", csrfGet)
@@ -82,6 +94,10 @@ func Register(engine *gin.Engine) {
 
 	csrfGroup.GET("/event", eventGet)
 
+	csrfGroup.GET("/host", hostsGet)
+	csrfGroup.GET("/host/:host_id", hostGet)
+	csrfGroup.DELETE("/host/:host_id", hostDelete)
+
 	csrfGroup.GET("/log", logsGet)
 	csrfGroup.GET("/log/:log_id", logGet)
 
@@ -100,10 +116,20 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/service/:service_id", servicePut)
 	csrfGroup.POST("/service", servicePost)
 	csrfGroup.DELETE("/service/:service_id", serviceDelete)
+	csrfGroup.GET("/service/:service_id/stats", serviceStatsGet)
+	csrfGroup.DELETE("/service/:service_id/stats", serviceStatsDelete)
 
 	csrfGroup.GET("/session/:user_id", sessionsGet)
 	csrfGroup.DELETE("/session/:session_id", sessionDelete)
 
+	csrfGroup.GET("/recording/:user_id", recordingsGet)
+	csrfGroup.GET("/recording/:user_id/command", recordingCommandsGet)
+	csrfGroup.GET("/recording/:user_id/data/:rec_id", recordingDataGet)
+
+	csrfGroup.GET("/revocation", revocationsGet)
+	csrfGroup.POST("/revocation", revocationPost)
+	csrfGroup.DELETE("/revocation/:revk_id", revocationDelete)
+
 	csrfGroup.GET("/settings", settingsGet)
 	csrfGroup.PUT("/settings", settingsPut)
 
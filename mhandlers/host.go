@@ -0,0 +1,123 @@
+package mhandlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/inventory"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type hostsData struct {
+	Hosts []*inventory.Host `json:"hosts"`
+	Count int64             `json:"count"`
+}
+
+func hostGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	hostId, ok := utils.ParseObjectId(c.Param("host_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	hst, err := inventory.Get(db, hostId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	hst.Json()
+
+	c.JSON(200, hst)
+}
+
+func hostsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	hostname := strings.TrimSpace(c.Query("hostname"))
+	if hostname != "" {
+		query["hostname"] = &bson.M{
+			"$regex":   regexp.QuoteMeta(hostname),
+			"$options": "i",
+		}
+	}
+
+	address := strings.TrimSpace(c.Query("address"))
+	if address != "" {
+		query["address"] = address
+	}
+
+	authrId, ok := utils.ParseObjectId(c.Query("authority"))
+	if ok {
+		query["authority_id"] = authrId
+	}
+
+	stale := c.Query("stale")
+	switch stale {
+	case "true":
+		query["expires"] = &bson.M{
+			"$lt": time.Now(),
+		}
+		break
+	case "false":
+		query["expires"] = &bson.M{
+			"$gte": time.Now(),
+		}
+		break
+	}
+
+	hsts, count, err := inventory.GetAll(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, hst := range hsts {
+		hst.Json()
+	}
+
+	data := &hostsData{
+		Hosts: hsts,
+		Count: count,
+	}
+
+	c.JSON(200, data)
+}
+
+func hostDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	hostId, ok := utils.ParseObjectId(c.Param("host_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := inventory.Remove(db, hostId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "host.change")
+
+	c.JSON(200, nil)
+}
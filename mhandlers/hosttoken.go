@@ -0,0 +1,132 @@
+package mhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/hosttoken"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type hostTokenData struct {
+	Name        string   `json:"name"`
+	SingleUse   bool     `json:"single_use"`
+	HostMatches []string `json:"host_matches"`
+	HostSubnets []string `json:"host_subnets"`
+	Ttl         int      `json:"ttl"`
+}
+
+func hostTokensGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	tokns, err := hosttoken.GetAll(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, tokns)
+}
+
+func hostTokenPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &hostTokenData{}
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authr, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !authr.HostCertificates {
+		errData := &errortypes.ErrorData{
+			Error:   "host_certificates_disabled",
+			Message: "Host certificates must be enabled for host tokens",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	tokn, err := hosttoken.New(authr.Id, data.Name, data.SingleUse,
+		data.HostMatches, data.HostSubnets, data.Ttl)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := tokn.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tokn.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "host_token.change")
+
+	c.JSON(200, tokn)
+}
+
+func hostTokenDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	toknId, ok := utils.ParseObjectId(c.Param("token_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := hosttoken.Revoke(db, authrId, toknId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "host_token.change")
+
+	c.JSON(200, nil)
+}
@@ -0,0 +1,118 @@
+package mhandlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type hsmAgentData struct {
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+}
+
+type hsmAgentResp struct {
+	*authority.HsmAgent
+	Secret string `json:"secret"`
+}
+
+func hsmAgentPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &hsmAgentData{}
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authr, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if authr.Type != authority.PritunlHsm {
+		errData := &errortypes.ErrorData{
+			Error:   "invalid_type",
+			Message: "HSM agents require a Pritunl HSM authority",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	data.Serial = strings.TrimSpace(data.Serial)
+	if data.Serial == "" {
+		errData := &errortypes.ErrorData{
+			Error:   "missing_hsm_serial",
+			Message: "Missing HSM agent serial",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	agnt, err := authr.NewHsmAgent(db, data.Name, data.Serial)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "authority.change")
+
+	c.JSON(200, &hsmAgentResp{
+		HsmAgent: agnt,
+		Secret:   agnt.Secret,
+	})
+}
+
+func hsmAgentDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	agntId, ok := utils.ParseObjectId(c.Param("agent_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	authr, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = authr.RemoveHsmAgent(db, agntId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "authority.change")
+
+	c.JSON(200, nil)
+}
@@ -19,6 +19,11 @@ type nodeData struct {
 	NoRedirectServer     bool                 `json:"no_redirect_server"`
 	Protocol             string               `json:"protocol"`
 	Certificates         []primitive.ObjectID `json:"certificates"`
+	AutoCertificates     bool                 `json:"auto_certificates"`
+	TlsMinVersion        string               `json:"tls_min_version"`
+	TlsCipherSuites      []string             `json:"tls_cipher_suites"`
+	TlsCurves            []string             `json:"tls_curves"`
+	OcspStapling         bool                 `json:"ocsp_stapling"`
 	ManagementDomain     string               `json:"management_domain"`
 	UserDomain           string               `json:"user_domain"`
 	Services             []primitive.ObjectID `json:"services"`
@@ -59,6 +64,11 @@ func nodePut(c *gin.Context) {
 	nde.NoRedirectServer = data.NoRedirectServer
 	nde.Protocol = data.Protocol
 	nde.Certificates = data.Certificates
+	nde.AutoCertificates = data.AutoCertificates
+	nde.TlsMinVersion = data.TlsMinVersion
+	nde.TlsCipherSuites = data.TlsCipherSuites
+	nde.TlsCurves = data.TlsCurves
+	nde.OcspStapling = data.OcspStapling
 	nde.ManagementDomain = data.ManagementDomain
 	nde.UserDomain = data.UserDomain
 	nde.Services = data.Services
@@ -73,6 +83,11 @@ func nodePut(c *gin.Context) {
 		"no_redirect_server",
 		"protocol",
 		"certificates",
+		"auto_certificates",
+		"tls_min_version",
+		"tls_cipher_suites",
+		"tls_curves",
+		"ocsp_stapling",
 		"management_domain",
 		"user_domain",
 		"services",
@@ -28,6 +28,7 @@ type policyData struct {
 	ProxyDeviceSecondary      bool                    `json:"proxy_device_secondary"`
 	AuthorityDeviceSecondary  bool                    `json:"authority_device_secondary"`
 	AuthorityRequireSmartCard bool                    `json:"authority_require_smart_card"`
+	AuthorityRequireApproval  bool                    `json:"authority_require_approval"`
 }
 
 func policyPut(c *gin.Context) {
@@ -71,6 +72,7 @@ func policyPut(c *gin.Context) {
 	polcy.ProxyDeviceSecondary = data.ProxyDeviceSecondary
 	polcy.AuthorityDeviceSecondary = data.AuthorityDeviceSecondary
 	polcy.AuthorityRequireSmartCard = data.AuthorityRequireSmartCard
+	polcy.AuthorityRequireApproval = data.AuthorityRequireApproval
 
 	fields := set.NewSet(
 		"name",
@@ -88,6 +90,7 @@ func policyPut(c *gin.Context) {
 		"proxy_device_secondary",
 		"authority_device_secondary",
 		"authority_require_smart_card",
+		"authority_require_approval",
 	)
 
 	errData, err := polcy.Validate(db)
@@ -143,6 +146,7 @@ func policyPost(c *gin.Context) {
 		UserDeviceSecondary:      data.UserDeviceSecondary,
 		ProxyDeviceSecondary:     data.ProxyDeviceSecondary,
 		AuthorityDeviceSecondary: data.AuthorityDeviceSecondary,
+		AuthorityRequireApproval: data.AuthorityRequireApproval,
 	}
 
 	errData, err := polcy.Validate(db)
@@ -0,0 +1,112 @@
+package mhandlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/recording"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type recordingsData struct {
+	Recordings []*recording.Recording `json:"recordings"`
+	Count      int64                  `json:"count"`
+}
+
+type recordingCommandsData struct {
+	Commands []*recording.Command `json:"commands"`
+	Count    int64                `json:"count"`
+}
+
+func recordingsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	userId, ok := utils.ParseObjectId(c.Param("user_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	recs, count, err := recording.GetAll(db, userId, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &recordingsData{
+		Recordings: recs,
+		Count:      count,
+	}
+
+	c.JSON(200, data)
+}
+
+func recordingCommandsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+	search := c.Query("search")
+
+	userId, ok := utils.ParseObjectId(c.Param("user_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	cmds, count, err := recording.GetCommands(
+		db, userId, search, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &recordingCommandsData{
+		Commands: cmds,
+		Count:    count,
+	}
+
+	c.JSON(200, data)
+}
+
+func recordingDataGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	userId, ok := utils.ParseObjectId(c.Param("user_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	recId, ok := utils.ParseObjectId(c.Param("rec_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	rec, err := recording.Get(db, recId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if rec.UserId != userId {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	data, err := recording.GetData(db, rec.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=\"%s.cast\"", rec.Id.Hex()))
+	c.Data(200, "application/x-asciicast", data)
+}
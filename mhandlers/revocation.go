@@ -0,0 +1,181 @@
+package mhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/authorizer"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/revocation"
+	"github.com/hydeant/pritunl-zero/ssh"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type revocationData struct {
+	Type          string             `json:"type"`
+	CertificateId primitive.ObjectID `json:"certificate_id"`
+	UserId        primitive.ObjectID `json:"user_id"`
+	PublicKey     string             `json:"public_key"`
+	Reason        string             `json:"reason"`
+}
+
+type revocationResp struct {
+	Count int `json:"count"`
+}
+
+func revocationsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	revks, err := revocation.GetAll(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, revks)
+}
+
+func revocationPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	data := &revocationData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	count := 0
+
+	switch data.Type {
+	case "certificate":
+		cert, e := ssh.GetCertificate(db, data.CertificateId)
+		if e != nil {
+			utils.AbortWithError(c, 500, e)
+			return
+		}
+
+		count, err = revocation.RevokeCertificate(db, cert, data.Reason)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		break
+	case "user":
+		count, err = revocation.RevokeUser(db, data.UserId, data.Reason)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		break
+	case "key":
+		_, errData, e := revocation.RevokeKey(
+			db, data.PublicKey, data.UserId, data.Reason)
+		if e != nil {
+			utils.AbortWithError(c, 500, e)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+
+		count = 1
+
+		break
+	default:
+		errData := &errortypes.ErrorData{
+			Error:   "revocation_type_invalid",
+			Message: "Revocation type is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.RevocationCreate,
+		audit.Fields{
+			"type":            data.Type,
+			"certificate_id":  data.CertificateId,
+			"revoked_user_id": data.UserId,
+			"ssh_key":         data.PublicKey,
+			"reason":          data.Reason,
+			"count":           count,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "revocation.change")
+
+	c.JSON(200, &revocationResp{
+		Count: count,
+	})
+}
+
+func revocationDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	revkId, ok := utils.ParseObjectId(c.Param("revk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = revocation.Remove(db, revkId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.RevocationRemove,
+		audit.Fields{
+			"revocation_id": revkId,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "revocation.change")
+
+	c.JSON(200, nil)
+}
@@ -1,6 +1,8 @@
 package mhandlers
 
 import (
+	"time"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -12,19 +14,36 @@ import (
 )
 
 type serviceData struct {
-	Id                primitive.ObjectID       `json:"id"`
-	Name              string                   `json:"name"`
-	Type              string                   `json:"type"`
-	ShareSession      bool                     `json:"share_session"`
-	LogoutPath        string                   `json:"logout_path"`
-	WebSockets        bool                     `json:"websockets"`
-	DisableCsrfCheck  bool                     `json:"disable_csrf_check"`
-	ClientAuthority   primitive.ObjectID       `json:"client_authority"`
-	Domains           []*service.Domain        `json:"domains"`
-	Roles             []string                 `json:"roles"`
-	Servers           []*service.Server        `json:"servers"`
-	WhitelistNetworks []string                 `json:"whitelist_networks"`
-	WhitelistPaths    []*service.WhitelistPath `json:"whitelist_paths"`
+	Id                 primitive.ObjectID           `json:"id"`
+	Name               string                       `json:"name"`
+	Type               string                       `json:"type"`
+	ShareSession       bool                         `json:"share_session"`
+	LogoutPath         string                       `json:"logout_path"`
+	WebSockets         bool                         `json:"websockets"`
+	DisableCsrfCheck   bool                         `json:"disable_csrf_check"`
+	ClientAuthority    primitive.ObjectID           `json:"client_authority"`
+	Domains            []*service.Domain            `json:"domains"`
+	Roles              []string                     `json:"roles"`
+	Servers            []*service.Server            `json:"servers"`
+	ServerGroups       []*service.ServerGroup       `json:"server_groups"`
+	MirrorServer       *service.Server              `json:"mirror_server"`
+	MirrorPercent      float64                      `json:"mirror_percent"`
+	MirrorBodyLimit    int64                        `json:"mirror_body_limit"`
+	WhitelistNetworks  []string                     `json:"whitelist_networks"`
+	WhitelistPaths     []*service.WhitelistPath     `json:"whitelist_paths"`
+	UpstreamCerts      string                       `json:"upstream_certs"`
+	UpstreamPins       []string                     `json:"upstream_pins"`
+	ResponseHeaders    []*service.Header            `json:"response_headers"`
+	StripHeaders       []string                     `json:"strip_headers"`
+	CorsOrigins        []string                     `json:"cors_origins"`
+	CorsMethods        []string                     `json:"cors_methods"`
+	CorsHeaders        []string                     `json:"cors_headers"`
+	CorsCredentials    bool                         `json:"cors_credentials"`
+	CorsMaxAge         int                          `json:"cors_max_age"`
+	Maintenance        bool                         `json:"maintenance"`
+	MaintenanceWindows []*service.MaintenanceWindow `json:"maintenance_windows"`
+	MaintenancePage    string                       `json:"maintenance_page"`
+	MaintenanceRole    string                       `json:"maintenance_role"`
 }
 
 func servicePut(c *gin.Context) {
@@ -63,8 +82,25 @@ func servicePut(c *gin.Context) {
 	srvce.Domains = data.Domains
 	srvce.Roles = data.Roles
 	srvce.Servers = data.Servers
+	srvce.ServerGroups = data.ServerGroups
+	srvce.MirrorServer = data.MirrorServer
+	srvce.MirrorPercent = data.MirrorPercent
+	srvce.MirrorBodyLimit = data.MirrorBodyLimit
 	srvce.WhitelistNetworks = data.WhitelistNetworks
 	srvce.WhitelistPaths = data.WhitelistPaths
+	srvce.UpstreamCerts = data.UpstreamCerts
+	srvce.UpstreamPins = data.UpstreamPins
+	srvce.ResponseHeaders = data.ResponseHeaders
+	srvce.StripHeaders = data.StripHeaders
+	srvce.CorsOrigins = data.CorsOrigins
+	srvce.CorsMethods = data.CorsMethods
+	srvce.CorsHeaders = data.CorsHeaders
+	srvce.CorsCredentials = data.CorsCredentials
+	srvce.CorsMaxAge = data.CorsMaxAge
+	srvce.Maintenance = data.Maintenance
+	srvce.MaintenanceWindows = data.MaintenanceWindows
+	srvce.MaintenancePage = data.MaintenancePage
+	srvce.MaintenanceRole = data.MaintenanceRole
 
 	fields := set.NewSet(
 		"name",
@@ -77,8 +113,25 @@ func servicePut(c *gin.Context) {
 		"domains",
 		"roles",
 		"servers",
+		"server_groups",
+		"mirror_server",
+		"mirror_percent",
+		"mirror_body_limit",
 		"whitelist_networks",
 		"whitelist_paths",
+		"upstream_certs",
+		"upstream_pins",
+		"response_headers",
+		"strip_headers",
+		"cors_origins",
+		"cors_methods",
+		"cors_headers",
+		"cors_credentials",
+		"cors_max_age",
+		"maintenance",
+		"maintenance_windows",
+		"maintenance_page",
+		"maintenance_role",
 	)
 
 	errData, err := srvce.Validate(db)
@@ -98,6 +151,12 @@ func servicePut(c *gin.Context) {
 		return
 	}
 
+	err = srvce.SyncMaintenance(db, time.Now())
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	event.PublishDispatch(db, "service.change")
 
 	c.JSON(200, srvce)
@@ -112,6 +171,7 @@ func servicePost(c *gin.Context) {
 	data := &serviceData{
 		Name:         "New Service",
 		ShareSession: true,
+		StripHeaders: append([]string{}, service.DefaultStripHeaders...),
 	}
 
 	err := c.Bind(data)
@@ -121,18 +181,35 @@ func servicePost(c *gin.Context) {
 	}
 
 	srvce := &service.Service{
-		Name:              data.Name,
-		Type:              data.Type,
-		ShareSession:      data.ShareSession,
-		LogoutPath:        data.LogoutPath,
-		WebSockets:        data.WebSockets,
-		DisableCsrfCheck:  data.DisableCsrfCheck,
-		ClientAuthority:   data.ClientAuthority,
-		Roles:             data.Roles,
-		Domains:           data.Domains,
-		Servers:           data.Servers,
-		WhitelistNetworks: data.WhitelistNetworks,
-		WhitelistPaths:    data.WhitelistPaths,
+		Name:               data.Name,
+		Type:               data.Type,
+		ShareSession:       data.ShareSession,
+		LogoutPath:         data.LogoutPath,
+		WebSockets:         data.WebSockets,
+		DisableCsrfCheck:   data.DisableCsrfCheck,
+		ClientAuthority:    data.ClientAuthority,
+		Roles:              data.Roles,
+		Domains:            data.Domains,
+		Servers:            data.Servers,
+		ServerGroups:       data.ServerGroups,
+		MirrorServer:       data.MirrorServer,
+		MirrorPercent:      data.MirrorPercent,
+		MirrorBodyLimit:    data.MirrorBodyLimit,
+		WhitelistNetworks:  data.WhitelistNetworks,
+		WhitelistPaths:     data.WhitelistPaths,
+		UpstreamCerts:      data.UpstreamCerts,
+		UpstreamPins:       data.UpstreamPins,
+		ResponseHeaders:    data.ResponseHeaders,
+		StripHeaders:       data.StripHeaders,
+		CorsOrigins:        data.CorsOrigins,
+		CorsMethods:        data.CorsMethods,
+		CorsHeaders:        data.CorsHeaders,
+		CorsCredentials:    data.CorsCredentials,
+		CorsMaxAge:         data.CorsMaxAge,
+		Maintenance:        data.Maintenance,
+		MaintenanceWindows: data.MaintenanceWindows,
+		MaintenancePage:    data.MaintenancePage,
+		MaintenanceRole:    data.MaintenanceRole,
 	}
 
 	errData, err := srvce.Validate(db)
@@ -146,6 +223,8 @@ func servicePost(c *gin.Context) {
 		return
 	}
 
+	srvce.MaintenanceActive = srvce.MaintenanceScheduled(time.Now())
+
 	err = srvce.Insert(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -192,3 +271,43 @@ func servicesGet(c *gin.Context) {
 
 	c.JSON(200, services)
 }
+
+func serviceStatsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	serviceId, ok := utils.ParseObjectId(c.Param("service_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	stats, err := service.GetGroupStats(db, serviceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, stats)
+}
+
+func serviceStatsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	serviceId, ok := utils.ParseObjectId(c.Param("service_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := service.ClearGroupStats(db, serviceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
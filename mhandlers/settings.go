@@ -4,8 +4,11 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/auth"
+	"github.com/hydeant/pritunl-zero/authorizer"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
 	"github.com/hydeant/pritunl-zero/event"
 	"github.com/hydeant/pritunl-zero/secondary"
 	"github.com/hydeant/pritunl-zero/settings"
@@ -13,29 +16,35 @@ import (
 )
 
 type settingsData struct {
-	AuthProviders          []*settings.Provider          `json:"auth_providers"`
-	AuthSecondaryProviders []*settings.SecondaryProvider `json:"auth_secondary_providers"`
-	AuthAdminExpire        int                           `json:"auth_admin_expire"`
-	AuthAdminMaxDuration   int                           `json:"auth_admin_max_duration"`
-	AuthProxyExpire        int                           `json:"auth_proxy_expire"`
-	AuthProxyMaxDuration   int                           `json:"auth_proxy_max_duration"`
-	AuthUserExpire         int                           `json:"auth_user_expire"`
-	AuthUserMaxDuration    int                           `json:"auth_user_max_duration"`
-	ElasticAddress         string                        `json:"elastic_address"`
-	ElasticProxyRequests   bool                          `json:"elastic_proxy_requests"`
+	AuthProviders             []*settings.Provider          `json:"auth_providers"`
+	AuthSecondaryProviders    []*settings.SecondaryProvider `json:"auth_secondary_providers"`
+	AuthAdminExpire           int                           `json:"auth_admin_expire"`
+	AuthAdminMaxDuration      int                           `json:"auth_admin_max_duration"`
+	AuthProxyExpire           int                           `json:"auth_proxy_expire"`
+	AuthProxyMaxDuration      int                           `json:"auth_proxy_max_duration"`
+	AuthUserExpire            int                           `json:"auth_user_expire"`
+	AuthUserMaxDuration       int                           `json:"auth_user_max_duration"`
+	AuthAdminCertificate      bool                          `json:"auth_admin_certificate"`
+	AuthAdminCertificateCas   string                        `json:"auth_admin_certificate_cas"`
+	AuthAdminCertificateMatch string                        `json:"auth_admin_certificate_match"`
+	ElasticAddress            string                        `json:"elastic_address"`
+	ElasticProxyRequests      bool                          `json:"elastic_proxy_requests"`
 }
 
 func getSettingsData() *settingsData {
 	data := &settingsData{
-		AuthProviders:          settings.Auth.Providers,
-		AuthSecondaryProviders: settings.Auth.SecondaryProviders,
-		AuthAdminExpire:        settings.Auth.AdminExpire,
-		AuthAdminMaxDuration:   settings.Auth.AdminMaxDuration,
-		AuthProxyExpire:        settings.Auth.ProxyExpire,
-		AuthProxyMaxDuration:   settings.Auth.ProxyMaxDuration,
-		AuthUserExpire:         settings.Auth.UserExpire,
-		AuthUserMaxDuration:    settings.Auth.UserMaxDuration,
-		ElasticProxyRequests:   settings.Elastic.ProxyRequests,
+		AuthProviders:             settings.Auth.Providers,
+		AuthSecondaryProviders:    settings.Auth.SecondaryProviders,
+		AuthAdminExpire:           settings.Auth.AdminExpire,
+		AuthAdminMaxDuration:      settings.Auth.AdminMaxDuration,
+		AuthProxyExpire:           settings.Auth.ProxyExpire,
+		AuthProxyMaxDuration:      settings.Auth.ProxyMaxDuration,
+		AuthUserExpire:            settings.Auth.UserExpire,
+		AuthUserMaxDuration:       settings.Auth.UserMaxDuration,
+		AuthAdminCertificate:      settings.Auth.AdminCertificate,
+		AuthAdminCertificateCas:   settings.Auth.AdminCertificateCas,
+		AuthAdminCertificateMatch: settings.Auth.AdminCertificateMatch,
+		ElasticProxyRequests:      settings.Elastic.ProxyRequests,
 	}
 
 	if len(settings.Elastic.Addresses) != 0 {
@@ -56,6 +65,7 @@ func settingsPut(c *gin.Context) {
 	}
 
 	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
 	data := &settingsData{}
 
 	err := c.Bind(data)
@@ -64,6 +74,47 @@ func settingsPut(c *gin.Context) {
 		return
 	}
 
+	if data.AuthAdminCertificateMatch == "" {
+		data.AuthAdminCertificateMatch = auth.CertMatchEmail
+	}
+
+	if !auth.CertMatches.Contains(data.AuthAdminCertificateMatch) {
+		errData := &errortypes.ErrorData{
+			Error:   "admin_certificate_match_invalid",
+			Message: "Admin certificate match type is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	if data.AuthAdminCertificate {
+		pool, e := auth.ParseAdminCertificateCas(
+			data.AuthAdminCertificateCas)
+		if e != nil {
+			errData := &errortypes.ErrorData{
+				Error:   "admin_certificate_cas_invalid",
+				Message: "Admin certificate CAs are invalid",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		usr, err := authr.GetUser(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		// Prevent enabling a configuration that would lock out the
+		// current administrator
+		errData := auth.VerifyAdminCertificate(c.Request, usr, pool,
+			data.AuthAdminCertificateMatch)
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	}
+
 	fields := set.NewSet()
 
 	elasticAddr := ""
@@ -125,6 +176,21 @@ func settingsPut(c *gin.Context) {
 		fields.Add("user_max_duration")
 	}
 
+	if settings.Auth.AdminCertificate != data.AuthAdminCertificate {
+		settings.Auth.AdminCertificate = data.AuthAdminCertificate
+		fields.Add("admin_certificate")
+	}
+	if settings.Auth.AdminCertificateCas != data.AuthAdminCertificateCas {
+		settings.Auth.AdminCertificateCas = data.AuthAdminCertificateCas
+		fields.Add("admin_certificate_cas")
+	}
+	if settings.Auth.AdminCertificateMatch !=
+		data.AuthAdminCertificateMatch {
+
+		settings.Auth.AdminCertificateMatch = data.AuthAdminCertificateMatch
+		fields.Add("admin_certificate_match")
+	}
+
 	for _, provider := range data.AuthProviders {
 		if provider.Id.IsZero() {
 			provider.Id = primitive.NewObjectID()
@@ -13,6 +13,7 @@ import (
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/demo"
 	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/revocation"
 	"github.com/hydeant/pritunl-zero/user"
 	"github.com/hydeant/pritunl-zero/utils"
 )
@@ -28,6 +29,7 @@ type userData struct {
 	GenerateSecret bool               `json:"generate_secret"`
 	Disabled       bool               `json:"disabled"`
 	ActiveUntil    time.Time          `json:"active_until"`
+	Attributes     map[string]string  `json:"attributes"`
 }
 
 type usersData struct {
@@ -89,6 +91,8 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	wasDisabled := usr.Disabled
+
 	showSecret := false
 	if usr.Type != data.Type {
 		if data.Type == user.Api {
@@ -107,6 +111,7 @@ func userPut(c *gin.Context) {
 	usr.Permissions = data.Permissions
 	usr.Disabled = data.Disabled
 	usr.ActiveUntil = data.ActiveUntil
+	usr.Attributes = data.Attributes
 
 	if usr.Disabled {
 		usr.ActiveUntil = time.Time{}
@@ -127,6 +132,7 @@ func userPut(c *gin.Context) {
 		"permissions",
 		"disabled",
 		"active_until",
+		"attributes",
 	)
 
 	if usr.Type == user.Local && data.Password != "" {
@@ -170,6 +176,14 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	if usr.Disabled && !wasDisabled {
+		_, err = revocation.RevokeUser(db, usr.Id, "User disabled")
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	if !showSecret {
@@ -201,6 +215,7 @@ func userPost(c *gin.Context) {
 		Permissions:   data.Permissions,
 		Disabled:      data.Disabled,
 		ActiveUntil:   data.ActiveUntil,
+		Attributes:    data.Attributes,
 	}
 
 	if usr.Disabled {
@@ -340,6 +355,14 @@ func usersDelete(c *gin.Context) {
 		return
 	}
 
+	for _, userId := range data {
+		_, err = revocation.RevokeUser(db, userId, "User deleted")
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	c.JSON(200, nil)
@@ -203,13 +203,26 @@ func AuthAdmin(c *gin.Context) {
 		return
 	}
 
-	_, _, errAudit, errData, err := validator.ValidateAdmin(
-		db, usr, authr.IsApi(), c.Request)
+	method := "certificate"
+	var errAudit audit.Fields
+
+	errData, err := auth.AdminCertificateCheck(c.Request, usr)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if errData == nil {
+		method = "check"
+
+		_, _, errAudit, errData, err = validator.ValidateAdmin(
+			db, usr, authr.IsApi(), c.Request)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	if errData != nil {
 		err = authr.Clear(db, c.Writer, c.Request)
 		if err != nil {
@@ -223,7 +236,7 @@ func AuthAdmin(c *gin.Context) {
 				"message": errData.Message,
 			}
 		}
-		errAudit["method"] = "check"
+		errAudit["method"] = method
 
 		err = audit.New(
 			db,
@@ -375,6 +388,7 @@ func AuthHsm(c *gin.Context) {
 	}
 
 	c.Set("authority", authr)
+	c.Set("hsm_token", token)
 }
 
 func Recovery(c *gin.Context) {
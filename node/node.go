@@ -20,6 +20,7 @@ import (
 	"github.com/hydeant/pritunl-zero/errortypes"
 	"github.com/hydeant/pritunl-zero/event"
 	"github.com/hydeant/pritunl-zero/requires"
+	"github.com/hydeant/pritunl-zero/service"
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
@@ -37,6 +38,11 @@ type Node struct {
 	Protocol             string                     `bson:"protocol" json:"protocol"`
 	Certificate          primitive.ObjectID         `bson:"certificate" json:"certificate"`
 	Certificates         []primitive.ObjectID       `bson:"certificates" json:"certificates"`
+	AutoCertificates     bool                       `bson:"auto_certificates" json:"auto_certificates"`
+	TlsMinVersion        string                     `bson:"tls_min_version" json:"tls_min_version"`
+	TlsCipherSuites      []string                   `bson:"tls_cipher_suites" json:"tls_cipher_suites"`
+	TlsCurves            []string                   `bson:"tls_curves" json:"tls_curves"`
+	OcspStapling         bool                       `bson:"ocsp_stapling" json:"ocsp_stapling"`
 	SelfCertificate      string                     `bson:"self_certificate_key" json:"-"`
 	SelfCertificateKey   string                     `bson:"self_certificate" json:"-"`
 	ManagementDomain     string                     `bson:"management_domain" json:"management_domain"`
@@ -96,6 +102,15 @@ func (n *Node) Validate(db *database.Database) (
 		n.Certificates = []primitive.ObjectID{}
 	}
 
+	if n.Protocol != "https" || !strings.Contains(n.Type, Proxy) {
+		n.AutoCertificates = false
+	}
+
+	errData = n.validateTls()
+	if errData != nil {
+		return
+	}
+
 	if n.Type == "" {
 		n.Type = Management
 	}
@@ -236,6 +251,11 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.NoRedirectServer = nde.NoRedirectServer
 	n.Protocol = nde.Protocol
 	n.Certificates = nde.Certificates
+	n.AutoCertificates = nde.AutoCertificates
+	n.TlsMinVersion = nde.TlsMinVersion
+	n.TlsCipherSuites = nde.TlsCipherSuites
+	n.TlsCurves = nde.TlsCurves
+	n.OcspStapling = nde.OcspStapling
 	n.SelfCertificate = nde.SelfCertificate
 	n.SelfCertificateKey = nde.SelfCertificateKey
 	n.ManagementDomain = nde.ManagementDomain
@@ -251,11 +271,6 @@ func (n *Node) update(db *database.Database) (err error) {
 func (n *Node) loadCerts(db *database.Database) (err error) {
 	certObjs := []*certificate.Certificate{}
 
-	if n.Certificates == nil || len(n.Certificates) == 0 {
-		n.CertificateObjs = certObjs
-		return
-	}
-
 	for _, certId := range n.Certificates {
 		cert, e := certificate.Get(db, certId)
 		if e != nil {
@@ -272,6 +287,33 @@ func (n *Node) loadCerts(db *database.Database) (err error) {
 		}
 	}
 
+	if n.AutoCertificates && len(n.Services) > 0 {
+		srvcs, e := service.GetMulti(db, n.Services)
+		if e != nil {
+			err = e
+			return
+		}
+
+		domains := []string{}
+		for _, srvc := range srvcs {
+			for _, domain := range srvc.Domains {
+				domains = append(domains, domain.Domain)
+			}
+		}
+
+		autoCerts, e := certificate.GetAutoDomains(db, domains)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, cert := range autoCerts {
+			if cert.Certificate != "" {
+				certObjs = append(certObjs, cert)
+			}
+		}
+	}
+
 	n.CertificateObjs = certObjs
 
 	return
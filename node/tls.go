@@ -0,0 +1,115 @@
+package node
+
+import (
+	"crypto/tls"
+
+	"github.com/hydeant/pritunl-zero/errortypes"
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	tlsCurves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P256":   tls.CurveP256,
+		"P384":   tls.CurveP384,
+		"P521":   tls.CurveP521,
+	}
+)
+
+func tlsCipherSuite(name string) (id uint16, ok bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			id = suite.ID
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// TLS configuration for the web server, cipher suites only apply to
+// TLS 1.2 connections
+func (n *Node) NewTlsConfig() (tlsConfig *tls.Config) {
+	tlsConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+	}
+
+	if version, ok := tlsVersions[n.TlsMinVersion]; ok {
+		tlsConfig.MinVersion = version
+	}
+
+	if len(n.TlsCipherSuites) > 0 {
+		tlsConfig.CipherSuites = []uint16{}
+		for _, name := range n.TlsCipherSuites {
+			if id, ok := tlsCipherSuite(name); ok {
+				tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+			}
+		}
+	}
+
+	if len(n.TlsCurves) > 0 {
+		tlsConfig.CurvePreferences = []tls.CurveID{}
+		for _, name := range n.TlsCurves {
+			if curve, ok := tlsCurves[name]; ok {
+				tlsConfig.CurvePreferences = append(
+					tlsConfig.CurvePreferences, curve)
+			}
+		}
+	}
+
+	return
+}
+
+func (n *Node) validateTls() (errData *errortypes.ErrorData) {
+	if n.TlsCipherSuites == nil {
+		n.TlsCipherSuites = []string{}
+	}
+
+	if n.TlsCurves == nil {
+		n.TlsCurves = []string{}
+	}
+
+	if n.Protocol != "https" {
+		n.TlsMinVersion = ""
+		n.TlsCipherSuites = []string{}
+		n.TlsCurves = []string{}
+		n.OcspStapling = false
+		return
+	}
+
+	if n.TlsMinVersion != "" {
+		if _, ok := tlsVersions[n.TlsMinVersion]; !ok {
+			errData = &errortypes.ErrorData{
+				Error:   "node_tls_version_invalid",
+				Message: "Invalid node minimum TLS version",
+			}
+			return
+		}
+	}
+
+	for _, name := range n.TlsCipherSuites {
+		if _, ok := tlsCipherSuite(name); !ok {
+			errData = &errortypes.ErrorData{
+				Error:   "node_tls_cipher_invalid",
+				Message: "Invalid node TLS cipher suite",
+			}
+			return
+		}
+	}
+
+	for _, name := range n.TlsCurves {
+		if _, ok := tlsCurves[name]; !ok {
+			errData = &errortypes.ErrorData{
+				Error:   "node_tls_curve_invalid",
+				Message: "Invalid node TLS curve",
+			}
+			return
+		}
+	}
+
+	return
+}
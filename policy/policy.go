@@ -42,6 +42,7 @@ type Policy struct {
 	ProxyDeviceSecondary      bool                 `bson:"proxy_device_secondary" json:"proxy_device_secondary"`
 	AuthorityDeviceSecondary  bool                 `bson:"authority_device_secondary" json:"authority_device_secondary"`
 	AuthorityRequireSmartCard bool                 `bson:"authority_require_smart_card" json:"authority_require_smart_card"`
+	AuthorityRequireApproval  bool                 `bson:"authority_require_approval" json:"authority_require_approval"`
 }
 
 func (p *Policy) Validate(db *database.Database) (
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hydeant/pritunl-zero/service"
+)
+
+func writeResponseHeaders(srvc *service.Service, r *http.Request,
+	header http.Header) {
+
+	for _, key := range srvc.StripHeaders {
+		header.Del(key)
+	}
+
+	for _, hdr := range srvc.ResponseHeaders {
+		if hdr.Value == "" {
+			header.Del(hdr.Key)
+		} else {
+			header.Set(hdr.Key, hdr.Value)
+		}
+	}
+
+	if !srvc.CorsEnabled() {
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	if !srvc.MatchCorsOrigin(origin) {
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+	if srvc.CorsCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func serveCorsPreflight(srvc *service.Service, w http.ResponseWriter,
+	r *http.Request) bool {
+
+	if !srvc.CorsEnabled() || r.Method != "OPTIONS" {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	if origin == "" || reqMethod == "" {
+		return false
+	}
+
+	if !srvc.MatchCorsOrigin(origin) || !srvc.MatchCorsMethod(reqMethod) {
+		http.Error(w, "CORS request not allowed", 403)
+		return true
+	}
+
+	header := w.Header()
+
+	methods := srvc.CorsMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST"}
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(srvc.CorsHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers",
+			strings.Join(srvc.CorsHeaders, ", "))
+	} else {
+		reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if reqHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+	}
+
+	if srvc.CorsCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if srvc.CorsMaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(srvc.CorsMaxAge))
+	}
+
+	for _, hdr := range srvc.ResponseHeaders {
+		if hdr.Value != "" {
+			header.Set(hdr.Key, hdr.Value)
+		}
+	}
+
+	w.WriteHeader(204)
+
+	return true
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/requires"
+	"github.com/hydeant/pritunl-zero/service"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+var (
+	reloadSignal = make(chan bool, 1)
+)
+
+func signalReload() {
+	select {
+	case reloadSignal <- true:
+	default:
+	}
+}
+
+func writeMaintenance(w http.ResponseWriter, srvc *service.Service) {
+	w.Header().Set("Retry-After",
+		strconv.Itoa(srvc.MaintenanceRetryAfter(time.Now())))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if srvc.MaintenancePage == "" {
+		utils.WriteStatus(w, 503)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(503)
+	io.WriteString(w, srvc.MaintenancePage)
+}
+
+func init() {
+	module := requires.New("proxy")
+	module.After("settings")
+	module.Before("event")
+
+	module.Handler = func() (err error) {
+		event.Register("service_maintenance", func(_ *event.EventPublish) {
+			signalReload()
+		})
+		return
+	}
+}
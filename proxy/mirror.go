@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+const (
+	mirrorWorkers   = 4
+	mirrorQueueSize = 256
+)
+
+var (
+	mirrorQueue = make(chan *mirrorRequest, mirrorQueueSize)
+)
+
+type mirrorBody struct {
+	io.Reader
+	io.Closer
+}
+
+type mirrorRequest struct {
+	mirr   *mirror
+	method string
+	url    *url.URL
+	host   string
+	header http.Header
+	body   []byte
+}
+
+type mirror struct {
+	serverProto string
+	serverHost  string
+	percent     float64
+	bodyLimit   int64
+	client      *http.Client
+}
+
+func (m *mirror) sample() bool {
+	return m.percent > 0 && rand.Float64()*100 < m.percent
+}
+
+// Capture outbound request, the body is read before the request is sent
+// to give the primary server and mirror separate copies. Requests with a
+// body larger than the body limit are not mirrored
+func (m *mirror) capture(req *http.Request) (mirReq *mirrorRequest) {
+	if req.ContentLength > m.bodyLimit {
+		return
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := ioutil.ReadAll(io.LimitReader(req.Body, m.bodyLimit+1))
+		if err != nil || int64(len(data)) > m.bodyLimit {
+			req.Body = mirrorBody{
+				Reader: io.MultiReader(bytes.NewReader(data), req.Body),
+				Closer: req.Body,
+			}
+			return
+		}
+
+		req.Body.Close()
+		req.Body = utils.NopCloser{bytes.NewReader(data)}
+		body = data
+	}
+
+	u := &url.URL{}
+	*u = *req.URL
+	u.Scheme = m.serverProto
+	u.Host = m.serverHost
+
+	mirReq = &mirrorRequest{
+		mirr:   m,
+		method: req.Method,
+		url:    u,
+		host:   req.Host,
+		header: utils.CloneHeader(req.Header),
+		body:   body,
+	}
+	mirReq.header.Set("Pritunl-Zero-Mirror", "true")
+
+	return
+}
+
+// Queue request without blocking, requests are dropped when queue is full
+func (m *mirror) send(mirReq *mirrorRequest) {
+	select {
+	case mirrorQueue <- mirReq:
+	default:
+	}
+}
+
+func (r *mirrorRequest) do() (err error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	req, err := http.NewRequest(r.method, r.url.String(), body)
+	if err != nil {
+		return
+	}
+
+	req.Header = r.header
+	req.Host = r.host
+
+	resp, err := r.mirr.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return
+}
+
+func mirrorRunner() {
+	for mirReq := range mirrorQueue {
+		err := mirReq.do()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"server": mirReq.url.Host,
+				"error":  err,
+			}).Warn("proxy: Mirror request failed")
+		}
+	}
+}
+
+func newMirror(host *Host) (m *mirror) {
+	server := host.Service.MirrorServer
+	if server == nil || host.Service.MirrorPercent <= 0 {
+		return
+	}
+
+	requestTimeout := time.Duration(
+		settings.Router.RequestTimeout) * time.Second
+	dialTimeout := time.Duration(
+		settings.Router.DialTimeout) * time.Second
+	handshakeTimeout := time.Duration(
+		settings.Router.HandshakeTimeout) * time.Second
+
+	m = &mirror{
+		serverProto: server.Protocol,
+		serverHost:  utils.FormatHostPort(server.Hostname, server.Port),
+		percent:     host.Service.MirrorPercent,
+		bodyLimit:   host.Service.MirrorBodyLimit,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout: dialTimeout,
+				}).DialContext,
+				MaxIdleConnsPerHost: mirrorWorkers,
+				TLSHandshakeTimeout: handshakeTimeout,
+				TLSClientConfig:     newTlsConfig(host, server),
+			},
+			CheckRedirect: func(r *http.Request, v []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Timeout: requestTimeout,
+		},
+	}
+
+	return
+}
+
+func initMirror() {
+	for i := 0; i < mirrorWorkers; i++ {
+		go mirrorRunner()
+	}
+}
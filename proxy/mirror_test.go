@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mirrorTest struct {
+	upstream *httptest.Server
+	mirror   *httptest.Server
+	received chan []byte
+	mirrored chan []byte
+}
+
+func newMirrorTest(t *testing.T) *mirrorTest {
+	m := &mirrorTest{
+		received: make(chan []byte, 1),
+		mirrored: make(chan []byte, 1),
+	}
+
+	m.upstream = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			m.received <- body
+			w.WriteHeader(200)
+		}))
+
+	m.mirror = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			m.mirrored <- body
+			w.WriteHeader(200)
+		}))
+
+	return m
+}
+
+func (m *mirrorTest) Close() {
+	m.upstream.Close()
+	m.mirror.Close()
+}
+
+// Proxy the request as the web handler does and send the mirror request
+// while the primary request can still be in progress
+func (m *mirrorTest) proxy(t *testing.T, bodyLimit int64,
+	body io.Reader) {
+
+	upstreamUrl, _ := url.Parse(m.upstream.URL)
+	mirrorUrl, _ := url.Parse(m.mirror.URL)
+
+	mirr := &mirror{
+		serverProto: "http",
+		serverHost:  mirrorUrl.Host,
+		percent:     100,
+		bodyLimit:   bodyLimit,
+		client:      &http.Client{},
+	}
+
+	var mirReq *mirrorRequest
+	prxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = upstreamUrl.Host
+			mirReq = mirr.capture(req)
+		},
+	}
+
+	req := httptest.NewRequest("POST", "http://service.test/path", body)
+	prxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if mirReq != nil {
+		go func() {
+			err := mirReq.do()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+}
+
+func (m *mirrorTest) wait(ch chan []byte) (body []byte, ok bool) {
+
+	select {
+	case body = <-ch:
+		ok = true
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	return
+}
+
+func TestMirrorBody(t *testing.T) {
+	m := newMirrorTest(t)
+	defer m.Close()
+
+	data := []byte(strings.Repeat("mirror", 1000))
+	m.proxy(t, 1<<20, bytes.NewReader(data))
+
+	received, ok := m.wait(m.received)
+	if !ok || !bytes.Equal(received, data) {
+		t.Error("Upstream body mismatch")
+	}
+
+	mirrored, ok := m.wait(m.mirrored)
+	if !ok || !bytes.Equal(mirrored, data) {
+		t.Error("Mirror body mismatch")
+	}
+}
+
+func TestMirrorBodyLimit(t *testing.T) {
+	m := newMirrorTest(t)
+	defer m.Close()
+
+	data := []byte(strings.Repeat("mirror", 1000))
+
+	// Unknown content length is only detected when reading the body
+	m.proxy(t, 1000, struct{ io.Reader }{bytes.NewReader(data)})
+
+	received, ok := m.wait(m.received)
+	if !ok || !bytes.Equal(received, data) {
+		t.Error("Upstream body mismatch")
+	}
+
+	_, ok = m.wait(m.mirrored)
+	if ok {
+		t.Error("Request over body limit mirrored")
+	}
+}
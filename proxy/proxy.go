@@ -2,10 +2,12 @@ package proxy
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -32,6 +34,12 @@ type Host struct {
 	WhitelistNetworks []*net.IPNet
 	ClientAuthority   *authority.Authority
 	ClientCertificate *tls.Certificate
+	UpstreamRoots     *x509.CertPool
+}
+
+type groupProxies struct {
+	wProxies  []*web
+	wsProxies []*webSocket
 }
 
 type Proxy struct {
@@ -40,15 +48,44 @@ type Proxy struct {
 	wProxies  map[string][]*web
 	wsProxies map[string][]*webSocket
 	wiProxies map[string][]*webIsolated
+	wgProxies map[string][]*groupProxies
+}
+
+// Find hosts key for request host, exact domains are matched first then
+// wildcard domains of the parent when the first label is a single valid
+// DNS label
+func (p *Proxy) MatchHost(hst string) string {
+	hosts := p.Hosts
+
+	if _, ok := hosts[hst]; ok {
+		return hst
+	}
+
+	i := strings.Index(hst, ".")
+	if i == -1 {
+		return hst
+	}
+
+	parent := hst[i+1:]
+	if !strings.Contains(parent, ".") || !service.ValidLabel(hst[:i]) {
+		return hst
+	}
+
+	if _, ok := hosts["*."+parent]; ok {
+		return "*." + parent
+	}
+
+	return hst
 }
 
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 	hst := utils.StripPort(r.Host)
+	key := p.MatchHost(hst)
 
-	host := p.Hosts[hst]
-	wProxies := p.wProxies[hst]
-	wsProxies := p.wsProxies[hst]
-	wiProxies := p.wiProxies[hst]
+	host := p.Hosts[key]
+	wProxies := p.wProxies[key]
+	wsProxies := p.wsProxies[key]
+	wiProxies := p.wiProxies[key]
 
 	wLen := 0
 	if wProxies != nil {
@@ -75,13 +112,23 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	}
 
+	if serveCorsPreflight(host.Service, w, r) {
+		return true
+	}
+
 	if !host.Service.DisableCsrfCheck {
-		valid := auth.CsrfCheck(w, r, host.Domain.Domain)
+		valid := auth.CsrfCheck(w, r, hst)
 		if !valid {
 			return true
 		}
 	}
 
+	maintenance := host.Service.MaintenanceActive
+	if maintenance && host.Service.MaintenanceRole == "" {
+		writeMaintenance(w, host.Service)
+		return true
+	}
+
 	db := database.GetDatabase()
 	defer db.Close()
 
@@ -114,6 +161,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 			if clientIp != nil {
 				for _, network := range host.WhitelistNetworks {
 					if network.Contains(clientIp) {
+						if maintenance {
+							writeMaintenance(w, host.Service)
+							return true
+						}
+
 						if wsProxies != nil && wsLen > 0 &&
 							r.Header.Get("Upgrade") == "websocket" {
 
@@ -134,6 +186,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 	if wiProxies != nil && wiLen > 0 &&
 		host.Service.MatchWhitelistPath(r.URL.Path) {
 
+		if maintenance {
+			writeMaintenance(w, host.Service)
+			return true
+		}
+
 		wiProxies[rand.Intn(wLen)].ServeHTTP(
 			w, r, authorizer.NewProxy(nil))
 		return true
@@ -230,6 +287,26 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
+	if maintenance &&
+		!usr.RolesMatch([]string{host.Service.MaintenanceRole}) {
+
+		writeMaintenance(w, host.Service)
+		return true
+	}
+
+	grpIndex := host.Service.SelectServerGroup(usr.Id, usr.Roles)
+	if grpIndex >= 0 {
+		grpProxies := p.wgProxies[key]
+		if grpIndex < len(grpProxies) &&
+			len(grpProxies[grpIndex].wProxies) > 0 {
+
+			wProxies = grpProxies[grpIndex].wProxies
+			wLen = len(wProxies)
+			wsProxies = grpProxies[grpIndex].wsProxies
+			wsLen = len(wsProxies)
+		}
+	}
+
 	if wsProxies != nil && r.Header.Get("Upgrade") == "websocket" {
 		wsProxies[rand.Intn(wsLen)].ServeHTTP(w, r, db, authr)
 		return true
@@ -297,7 +374,11 @@ func (p *Proxy) reloadHosts(db *database.Database,
 		nodeService := nodeServices.Contains(srvc.Id)
 
 		for _, domain := range srvc.Domains {
-			facets = append(facets, fmt.Sprintf("https://%s", domain.Domain))
+			// U2F facets must be exact origins
+			if !domain.Wildcard() {
+				facets = append(facets,
+					fmt.Sprintf("https://%s", domain.Domain))
+			}
 
 			if !nodeService {
 				continue
@@ -348,12 +429,21 @@ func (p *Proxy) reloadHosts(db *database.Database,
 				}
 			}
 
+			upstreamRoots, e := srvc.UpstreamRoots()
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"service_id": srvc.Id.Hex(),
+					"error":      e,
+				}).Error("proxy: Invalid service upstream certificates")
+			}
+
 			srvcDomain := &Host{
 				Service:           srvc,
 				Domain:            domain,
 				WhitelistNetworks: whitelistNets,
 				ClientAuthority:   clientAuthr,
 				ClientCertificate: cert,
+				UpstreamRoots:     upstreamRoots,
 			}
 
 			hosts[domain.Domain] = srvcDomain
@@ -374,11 +464,20 @@ func (p *Proxy) reloadProxies(db *database.Database, proto string, port int) (
 	wProxies := map[string][]*web{}
 	wsProxies := map[string][]*webSocket{}
 	wiProxies := map[string][]*webIsolated{}
+	wgProxies := map[string][]*groupProxies{}
 
 	for domain, host := range p.Hosts {
+		defaultGroup := ""
+		if len(host.Service.ServerGroups) > 0 {
+			defaultGroup = service.DefaultServerGroup
+		}
+
+		mirr := newMirror(host)
+
 		domainProxies := []*web{}
 		for _, server := range host.Service.Servers {
-			prxy := newWeb(proto, port, host, server)
+			prxy := newWeb(proto, port, host, server, defaultGroup)
+			prxy.mirr = mirr
 			domainProxies = append(domainProxies, prxy)
 		}
 		wProxies[domain] = domainProxies
@@ -386,7 +485,8 @@ func (p *Proxy) reloadProxies(db *database.Database, proto string, port int) (
 		if host.Service.WebSockets {
 			domainWsProxies := []*webSocket{}
 			for _, server := range host.Service.Servers {
-				prxy := newWebSocket(proto, port, host, server)
+				prxy := newWebSocket(proto, port, host, server,
+					defaultGroup)
 				domainWsProxies = append(domainWsProxies, prxy)
 			}
 			wsProxies[domain] = domainWsProxies
@@ -394,15 +494,43 @@ func (p *Proxy) reloadProxies(db *database.Database, proto string, port int) (
 
 		domainIsoProxies := []*webIsolated{}
 		for _, server := range host.Service.Servers {
-			prxy := newWebIsolated(proto, port, host, server)
+			prxy := newWebIsolated(proto, port, host, server,
+				defaultGroup)
 			domainIsoProxies = append(domainIsoProxies, prxy)
 		}
 		wiProxies[domain] = domainIsoProxies
+
+		domainGrpProxies := []*groupProxies{}
+		for _, group := range host.Service.ServerGroups {
+			grpProxies := &groupProxies{
+				wProxies: []*web{},
+			}
+
+			for _, server := range group.Servers {
+				prxy := newWeb(proto, port, host, server, group.Name)
+				prxy.mirr = mirr
+				grpProxies.wProxies = append(grpProxies.wProxies, prxy)
+			}
+
+			if host.Service.WebSockets {
+				grpProxies.wsProxies = []*webSocket{}
+				for _, server := range group.Servers {
+					prxy := newWebSocket(proto, port, host, server,
+						group.Name)
+					grpProxies.wsProxies = append(
+						grpProxies.wsProxies, prxy)
+				}
+			}
+
+			domainGrpProxies = append(domainGrpProxies, grpProxies)
+		}
+		wgProxies[domain] = domainGrpProxies
 	}
 
 	p.wProxies = wProxies
 	p.wsProxies = wsProxies
 	p.wiProxies = wiProxies
+	p.wgProxies = wgProxies
 
 	return
 }
@@ -437,13 +565,17 @@ func (p *Proxy) watchNode() {
 			p.wProxies = map[string][]*web{}
 			p.wsProxies = map[string][]*webSocket{}
 			p.wiProxies = map[string][]*webIsolated{}
+			p.wgProxies = map[string][]*groupProxies{}
 
 			logrus.WithFields(logrus.Fields{
 				"error": err,
 			}).Error("proxy: Failed to load proxy state")
 		}
 
-		time.Sleep(3 * time.Second)
+		select {
+		case <-reloadSignal:
+		case <-time.After(3 * time.Second):
+		}
 	}
 
 	return
@@ -453,5 +585,8 @@ func (p *Proxy) Init() {
 	p.Hosts = map[string]*Host{}
 	p.wProxies = map[string][]*web{}
 	p.wsProxies = map[string][]*webSocket{}
+	p.wgProxies = map[string][]*groupProxies{}
 	go p.watchNode()
+	go groupStatsRunner()
+	initMirror()
 }
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/service"
+)
+
+var (
+	groupStats     = map[groupStatsKey]*groupStatsCount{}
+	groupStatsLock = sync.Mutex{}
+)
+
+type groupStatsKey struct {
+	service primitive.ObjectID
+	group   string
+}
+
+type groupStatsCount struct {
+	requests int64
+	errors   int64
+}
+
+func countGroupRequest(serviceId primitive.ObjectID, group string,
+	failed bool) {
+
+	if group == "" {
+		return
+	}
+
+	key := groupStatsKey{
+		service: serviceId,
+		group:   group,
+	}
+
+	groupStatsLock.Lock()
+	count := groupStats[key]
+	if count == nil {
+		count = &groupStatsCount{}
+		groupStats[key] = count
+	}
+	count.requests += 1
+	if failed {
+		count.errors += 1
+	}
+	groupStatsLock.Unlock()
+}
+
+func syncGroupStats() (err error) {
+	groupStatsLock.Lock()
+	stats := groupStats
+	groupStats = map[groupStatsKey]*groupStatsCount{}
+	groupStatsLock.Unlock()
+
+	if len(stats) == 0 {
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	for key, count := range stats {
+		e := service.AddGroupStats(db, key.service, key.group,
+			count.requests, count.errors)
+		if e != nil {
+			err = e
+			restoreGroupStats(key, count)
+			continue
+		}
+	}
+
+	return
+}
+
+// Merge unsent counts back to retry on the next sync
+func restoreGroupStats(key groupStatsKey, count *groupStatsCount) {
+	groupStatsLock.Lock()
+	existing := groupStats[key]
+	if existing == nil {
+		groupStats[key] = count
+	} else {
+		existing.requests += count.requests
+		existing.errors += count.errors
+	}
+	groupStatsLock.Unlock()
+}
+
+func groupStatsRunner() {
+	for {
+		time.Sleep(10 * time.Second)
+
+		err := syncGroupStats()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("proxy: Failed to sync server group stats")
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package proxy
 
 import (
+	"crypto/tls"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/hydeant/pritunl-zero/node"
+	"github.com/hydeant/pritunl-zero/service"
+	"github.com/hydeant/pritunl-zero/settings"
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
@@ -47,3 +52,61 @@ func stripCookieHeaders(r *http.Request) {
 		r.Header.Del("Cookie")
 	}
 }
+
+func newTlsConfig(host *Host, server *service.Server) (tlsConfig *tls.Config) {
+	tlsConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+	}
+
+	if host.Service.UpstreamVerify() {
+		verifier := newUpstreamVerifier(host, server)
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifier.VerifyPeerCertificate
+	} else if settings.Router.SkipVerify ||
+		net.ParseIP(server.Hostname) != nil {
+
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if host.ClientCertificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{
+			*host.ClientCertificate,
+		}
+	}
+
+	return
+}
+
+// Upstream host header for request, wildcard domains substitute the
+// matched label into the host template
+func domainHost(domain *service.Domain, r *http.Request) string {
+	if domain.Host == "" {
+		return ""
+	}
+
+	label, ok := domain.MatchLabel(utils.StripPort(r.Host))
+	if !ok {
+		return ""
+	}
+
+	return domain.FormatHost(label)
+}
+
+func domainPath(domain *service.Domain, r *http.Request, u *url.URL) {
+	if domain.Path == "" {
+		return
+	}
+
+	label, ok := domain.MatchLabel(utils.StripPort(r.Host))
+	if !ok {
+		return
+	}
+
+	prefix := domain.FormatPath(label)
+
+	u.Path = prefix + u.Path
+	if u.RawPath != "" {
+		u.RawPath = prefix + u.RawPath
+	}
+}
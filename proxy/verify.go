@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/authorizer"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/service"
+)
+
+type upstreamVerifier struct {
+	hostname string
+	roots    *x509.CertPool
+	pins     set.Set
+}
+
+func (v *upstreamVerifier) VerifyPeerCertificate(rawCerts [][]byte,
+	_ [][]*x509.Certificate) (err error) {
+
+	if len(rawCerts) == 0 {
+		err = &errortypes.VerificationError{
+			errors.New("proxy: Upstream server sent no certificates"),
+		}
+		return
+	}
+
+	// Only the leaf is pinned, any other certificate in the chain is
+	// chosen by the server
+	if v.pins.Len() > 0 {
+		hash := sha256.Sum256(rawCerts[0])
+		if v.pins.Contains(hex.EncodeToString(hash[:])) {
+			leaf, e := x509.ParseCertificate(rawCerts[0])
+			if e != nil {
+				err = &errortypes.VerificationError{
+					errors.Wrap(e,
+						"proxy: Failed to parse upstream certificate"),
+				}
+				return
+			}
+
+			e = leaf.VerifyHostname(v.hostname)
+			if e != nil {
+				err = &errortypes.VerificationError{
+					errors.Wrap(e,
+						"proxy: Pinned upstream certificate hostname "+
+							"mismatch"),
+				}
+				return
+			}
+
+			return
+		}
+
+		if v.roots == nil {
+			err = &errortypes.VerificationError{
+				errors.New("proxy: Upstream certificate does not " +
+					"match any pin"),
+			}
+			return
+		}
+	}
+
+	if v.roots == nil {
+		err = &errortypes.VerificationError{
+			errors.New("proxy: No trusted upstream certificates"),
+		}
+		return
+	}
+
+	certs := []*x509.Certificate{}
+	for _, rawCert := range rawCerts {
+		cert, e := x509.ParseCertificate(rawCert)
+		if e != nil {
+			err = &errortypes.VerificationError{
+				errors.Wrap(e, "proxy: Failed to parse upstream certificate"),
+			}
+			return
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// DNSName also matches IP SANs when the hostname is an IP address
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		DNSName:       v.hostname,
+		Roots:         v.roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		err = &errortypes.VerificationError{
+			errors.Wrap(err, "proxy: Upstream certificate verify failed"),
+		}
+		return
+	}
+
+	return
+}
+
+func newUpstreamVerifier(host *Host, server *service.Server) (
+	v *upstreamVerifier) {
+
+	pins := set.NewSet()
+	for _, pin := range host.Service.UpstreamPins {
+		pins.Add(pin)
+	}
+
+	v = &upstreamVerifier{
+		hostname: server.Hostname,
+		roots:    host.UpstreamRoots,
+		pins:     pins,
+	}
+
+	return
+}
+
+func isVerificationError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*errortypes.VerificationError); ok {
+			return true
+		}
+
+		wrapped, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = wrapped.Unwrap()
+	}
+
+	return false
+}
+
+func auditVerificationError(r *http.Request, authr *authorizer.Authorizer,
+	serviceId primitive.ObjectID, server string, verifyErr error) {
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	userId := primitive.NilObjectID
+	if authr != nil {
+		usr, _ := authr.GetUser(nil)
+		if usr != nil {
+			userId = usr.Id
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"service_id": serviceId.Hex(),
+		"server":     server,
+		"error":      verifyErr,
+	}).Error("proxy: Upstream certificate verification failed")
+
+	err := audit.New(
+		db,
+		r,
+		userId,
+		audit.ProxyUpstreamVerifyFailed,
+		audit.Fields{
+			"service_id": serviceId.Hex(),
+			"server":     server,
+			"error":      verifyErr.Error(),
+		},
+	)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("proxy: Failed to audit upstream verification")
+	}
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+)
+
+func newTestCert(t *testing.T, hostname string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templ := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName: hostname,
+		},
+		DNSNames:  []string{hostname},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+
+	certBytes, err := x509.CreateCertificate(
+		rand.Reader, templ, templ, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certBytes
+}
+
+func testPin(certBytes []byte) string {
+	hash := sha256.Sum256(certBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestVerifyPinnedLeaf(t *testing.T) {
+	pinned := newTestCert(t, "upstream.test")
+
+	verifier := &upstreamVerifier{
+		hostname: "upstream.test",
+		pins:     set.NewSet(testPin(pinned)),
+	}
+
+	err := verifier.VerifyPeerCertificate([][]byte{pinned}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyPinnedAppended(t *testing.T) {
+	pinned := newTestCert(t, "upstream.test")
+	attacker := newTestCert(t, "upstream.test")
+
+	verifier := &upstreamVerifier{
+		hostname: "upstream.test",
+		pins:     set.NewSet(testPin(pinned)),
+	}
+
+	err := verifier.VerifyPeerCertificate(
+		[][]byte{attacker, pinned}, nil)
+	if err == nil {
+		t.Error("Attacker leaf with appended pinned certificate accepted")
+	}
+}
+
+func TestVerifyPinnedHostname(t *testing.T) {
+	pinned := newTestCert(t, "other.test")
+
+	verifier := &upstreamVerifier{
+		hostname: "upstream.test",
+		pins:     set.NewSet(testPin(pinned)),
+	}
+
+	err := verifier.VerifyPeerCertificate([][]byte{pinned}, nil)
+	if err == nil {
+		t.Error("Pinned certificate for other hostname accepted")
+	}
+}
@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"bytes"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -25,7 +24,10 @@ import (
 )
 
 type web struct {
-	reqHost     string
+	srvc        *service.Service
+	group       string
+	mirr        *mirror
+	domain      *service.Domain
 	serverHost  string
 	serverProto string
 	proxyProto  string
@@ -37,6 +39,9 @@ type web struct {
 func (w *web) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 	authr *authorizer.Authorizer) {
 
+	mirrored := w.mirr != nil && w.mirr.sample()
+	var mirReq *mirrorRequest
+
 	prxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
 			req.Header.Set("X-Forwarded-For",
@@ -52,15 +57,21 @@ func (w *web) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 				}
 			}
 
-			if w.reqHost != "" {
-				req.Host = w.reqHost
+			reqHost := domainHost(w.domain, r)
+			if reqHost != "" {
+				req.Host = reqHost
 			}
 
 			req.URL.Scheme = w.serverProto
 			req.URL.Host = w.serverHost
+			domainPath(w.domain, r, req.URL)
 
 			stripCookieHeaders(req)
 
+			if mirrored {
+				mirReq = w.mirr.capture(req)
+			}
+
 			if settings.Elastic.ProxyRequests {
 				index := search.Request{
 					Address:   node.Self.GetRemoteAddr(req),
@@ -100,13 +111,35 @@ func (w *web) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 		},
 		Transport: w.Transport,
 		ErrorLog:  w.ErrorLog,
+		ModifyResponse: func(resp *http.Response) error {
+			countGroupRequest(w.srvc.Id, w.group, resp.StatusCode >= 500)
+			writeResponseHeaders(w.srvc, r, resp.Header)
+			return nil
+		},
+		ErrorHandler: func(rw http.ResponseWriter, req *http.Request,
+			err error) {
+
+			countGroupRequest(w.srvc.Id, w.group, true)
+
+			if isVerificationError(err) {
+				auditVerificationError(r, authr, w.srvc.Id,
+					w.serverProto+"://"+w.serverHost, err)
+			}
+
+			w.ErrorLog.Printf("http: proxy error: %v", err)
+			rw.WriteHeader(http.StatusBadGateway)
+		},
 	}
 
 	prxy.ServeHTTP(rw, r)
+
+	if mirReq != nil {
+		w.mirr.send(mirReq)
+	}
 }
 
 func newWeb(proxyProto string, proxyPort int, host *Host,
-	server *service.Server) (w *web) {
+	server *service.Server, group string) (w *web) {
 
 	dialTimeout := time.Duration(
 		settings.Router.DialTimeout) * time.Second
@@ -121,19 +154,7 @@ func newWeb(proxyProto string, proxyPort int, host *Host,
 	continueTimeout := time.Duration(
 		settings.Router.ContinueTimeout) * time.Second
 
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		MaxVersion: tls.VersionTLS13,
-	}
-	if settings.Router.SkipVerify || net.ParseIP(server.Hostname) != nil {
-		tlsConfig.InsecureSkipVerify = true
-	}
-
-	if host.ClientCertificate != nil {
-		tlsConfig.Certificates = []tls.Certificate{
-			*host.ClientCertificate,
-		}
-	}
+	tlsConfig := newTlsConfig(host, server)
 
 	writer := &logger.ErrorWriter{
 		Message: "node: Proxy server error",
@@ -153,7 +174,9 @@ func newWeb(proxyProto string, proxyPort int, host *Host,
 	}
 
 	w = &web{
-		reqHost:     host.Domain.Host,
+		srvc:        host.Service,
+		group:       group,
+		domain:      host.Domain,
 		serverProto: server.Protocol,
 		serverHost:  utils.FormatHostPort(server.Hostname, server.Port),
 		proxyProto:  proxyProto,
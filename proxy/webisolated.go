@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"bytes"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -26,7 +25,9 @@ import (
 )
 
 type webIsolated struct {
-	reqHost     string
+	srvc        *service.Service
+	group       string
+	domain      *service.Domain
 	serverHost  string
 	serverProto string
 	proxyProto  string
@@ -39,6 +40,7 @@ func (w *webIsolated) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 	authr *authorizer.Authorizer) {
 
 	reqUrl := utils.ProxyUrl(r.URL, w.serverProto, w.serverHost)
+	domainPath(w.domain, r, reqUrl)
 
 	srcBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -73,8 +75,9 @@ func (w *webIsolated) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	if w.reqHost != "" {
-		req.Host = w.reqHost
+	reqHost := domainHost(w.domain, r)
+	if reqHost != "" {
+		req.Host = reqHost
 	}
 
 	stripCookieHeaders(req)
@@ -112,6 +115,13 @@ func (w *webIsolated) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 
 	resp, err := w.Client.Do(req)
 	if err != nil {
+		countGroupRequest(w.srvc.Id, w.group, true)
+
+		if isVerificationError(err) {
+			auditVerificationError(r, authr, w.srvc.Id,
+				w.serverProto+"://"+w.serverHost, err)
+		}
+
 		err = errortypes.RequestError{
 			errors.Wrap(err, "request: Request failed"),
 		}
@@ -120,13 +130,16 @@ func (w *webIsolated) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 	}
 	defer resp.Body.Close()
 
+	countGroupRequest(w.srvc.Id, w.group, resp.StatusCode >= 500)
+
 	utils.CopyHeaders(rw.Header(), resp.Header)
+	writeResponseHeaders(w.srvc, r, rw.Header())
 	rw.WriteHeader(resp.StatusCode)
 	io.Copy(rw, resp.Body)
 }
 
 func newWebIsolated(proxyProto string, proxyPort int, host *Host,
-	server *service.Server) (w *webIsolated) {
+	server *service.Server, group string) (w *webIsolated) {
 
 	requestTimeout := time.Duration(
 		settings.Router.RequestTimeout) * time.Second
@@ -143,19 +156,7 @@ func newWebIsolated(proxyProto string, proxyPort int, host *Host,
 	continueTimeout := time.Duration(
 		settings.Router.ContinueTimeout) * time.Second
 
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		MaxVersion: tls.VersionTLS13,
-	}
-	if settings.Router.SkipVerify || net.ParseIP(server.Hostname) != nil {
-		tlsConfig.InsecureSkipVerify = true
-	}
-
-	if host.ClientCertificate != nil {
-		tlsConfig.Certificates = []tls.Certificate{
-			*host.ClientCertificate,
-		}
-	}
+	tlsConfig := newTlsConfig(host, server)
 
 	writer := &logger.ErrorWriter{
 		Message: "node: Proxy server error",
@@ -175,7 +176,9 @@ func newWebIsolated(proxyProto string, proxyPort int, host *Host,
 	}
 
 	w = &webIsolated{
-		reqHost:     host.Domain.Host,
+		srvc:        host.Service,
+		group:       group,
+		domain:      host.Domain,
 		serverProto: server.Protocol,
 		serverHost:  utils.FormatHostPort(server.Hostname, server.Port),
 		proxyProto:  proxyProto,
@@ -4,7 +4,6 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -32,7 +31,9 @@ var (
 )
 
 type webSocket struct {
-	reqHost     string
+	srvc        *service.Service
+	group       string
+	domain      *service.Domain
 	serverHost  string
 	serverProto string
 	proxyProto  string
@@ -213,6 +214,7 @@ func (w *webSocket) Director(req *http.Request, authr *authorizer.Authorizer) (
 
 	u.Scheme = w.serverProto
 	u.Host = w.serverHost
+	domainPath(w.domain, req, u)
 
 	header.Set("X-Forwarded-For",
 		node.Self.GetRemoteAddr(req))
@@ -280,8 +282,9 @@ func (w *webSocket) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 
 	dialer := &websocket.Dialer{
 		Proxy: func(req *http.Request) (url *url.URL, err error) {
-			if w.reqHost != "" {
-				req.Host = w.reqHost
+			reqHost := domainHost(w.domain, r)
+			if reqHost != "" {
+				req.Host = reqHost
 			} else {
 				req.Host = r.Host
 			}
@@ -293,6 +296,13 @@ func (w *webSocket) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 
 	backConn, backResp, err = dialer.Dial(u.String(), header)
 	if err != nil {
+		countGroupRequest(w.srvc.Id, w.group, true)
+
+		if isVerificationError(err) {
+			auditVerificationError(r, authr, w.srvc.Id,
+				w.serverProto+"://"+w.serverHost, err)
+		}
+
 		if backResp != nil {
 			err = &errortypes.RequestError{
 				errors.Wrapf(err, "proxy: WebSocket dial error %d",
@@ -308,7 +318,10 @@ func (w *webSocket) ServeHTTP(rw http.ResponseWriter, r *http.Request,
 	}
 	defer backConn.Close()
 
+	countGroupRequest(w.srvc.Id, w.group, false)
+
 	upgradeHeaders := getUpgradeHeaders(backResp)
+	writeResponseHeaders(w.srvc, r, upgradeHeaders)
 	frontConn, err := w.upgrader.Upgrade(rw, r, upgradeHeaders)
 	if err != nil {
 		err = &errortypes.RequestError{
@@ -346,24 +359,14 @@ func getUpgradeHeaders(resp *http.Response) (header http.Header) {
 }
 
 func newWebSocket(proxyProto string, proxyPort int, host *Host,
-	server *service.Server) (ws *webSocket) {
+	server *service.Server, group string) (ws *webSocket) {
 
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		MaxVersion: tls.VersionTLS13,
-	}
-	if settings.Router.SkipVerify || net.ParseIP(server.Hostname) != nil {
-		tlsConfig.InsecureSkipVerify = true
-	}
-
-	if host.ClientCertificate != nil {
-		tlsConfig.Certificates = []tls.Certificate{
-			*host.ClientCertificate,
-		}
-	}
+	tlsConfig := newTlsConfig(host, server)
 
 	ws = &webSocket{
-		reqHost:    host.Domain.Host,
+		srvc:       host.Service,
+		group:      group,
+		domain:     host.Domain,
 		serverHost: utils.FormatHostPort(server.Hostname, server.Port),
 		proxyProto: proxyProto,
 		proxyPort:  proxyPort,
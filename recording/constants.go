@@ -0,0 +1,11 @@
+package recording
+
+import (
+	"time"
+)
+
+const (
+	chunkSize     = 512 * 1024
+	flushInterval = 10 * time.Second
+	maxCommandLen = 4096
+)
@@ -0,0 +1,309 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+)
+
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type Recorder struct {
+	rec     *Recording
+	buf     *bytes.Buffer
+	partial map[string][]byte
+	chunks  int
+	closed  bool
+	stop    chan bool
+	lock    sync.Mutex
+}
+
+type writer struct {
+	rcdr *Recorder
+	typ  string
+}
+
+func (w *writer) Write(p []byte) (n int, err error) {
+	w.rcdr.event(w.typ, p)
+	n = len(p)
+	return
+}
+
+// Writer for terminal output sent to the client
+func (r *Recorder) Output() io.Writer {
+	return &writer{
+		rcdr: r,
+		typ:  "o",
+	}
+}
+
+func (r *Recorder) elapsed() float64 {
+	return float64(time.Since(r.rec.Start)/time.Microsecond) / 1000000
+}
+
+func (r *Recorder) writeEvent(typ, data string) {
+	line, err := json.Marshal([]interface{}{r.elapsed(), typ, data})
+	if err != nil {
+		return
+	}
+
+	r.buf.Write(line)
+	r.buf.WriteByte('\n')
+}
+
+func (r *Recorder) event(typ string, p []byte) {
+	r.lock.Lock()
+
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+
+	// Hold incomplete UTF-8 sequences until the remaining bytes are received
+	data := append(r.partial[typ], p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.partial[typ] = append([]byte{}, data[end:]...)
+	data = data[:end]
+
+	if len(data) == 0 {
+		r.lock.Unlock()
+		return
+	}
+
+	r.writeEvent(typ, string(data))
+
+	var chunk *Chunk
+	if r.buf.Len() >= chunkSize {
+		chunk = r.takeChunk()
+	}
+
+	r.lock.Unlock()
+
+	r.storeChunk(chunk)
+}
+
+// Take the buffered data as the next chunk, must be called with the lock
+// held. Chunks are stored after the lock is released, the index keeps the
+// chunks in order
+func (r *Recorder) takeChunk() (chunk *Chunk) {
+	if r.buf.Len() == 0 {
+		return
+	}
+
+	chunk = &Chunk{
+		Id:          primitive.NewObjectID(),
+		RecordingId: r.rec.Id,
+		Index:       r.chunks,
+		Data:        r.buf.Bytes(),
+	}
+
+	r.chunks += 1
+	r.buf = &bytes.Buffer{}
+
+	return
+}
+
+func (r *Recorder) storeChunk(chunk *Chunk) {
+	if chunk == nil {
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	err := chunk.Insert(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"recording_id": r.rec.Id.Hex(),
+			"error":        err,
+		}).Error("recording: Failed to store recording data")
+		return
+	}
+
+	err = r.rec.addChunk(db, len(chunk.Data))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"recording_id": r.rec.Id.Hex(),
+			"error":        err,
+		}).Error("recording: Failed to update recording")
+	}
+}
+
+// Store a command from an exec request, interactive shell input is not
+// recorded
+func (r *Recorder) Command(cmd string) {
+	r.lock.Lock()
+	closed := r.closed
+	r.lock.Unlock()
+
+	if closed {
+		return
+	}
+
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+
+	if len(cmd) > maxCommandLen {
+		cmd = cmd[:maxCommandLen]
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	command := &Command{
+		Id:          primitive.NewObjectID(),
+		RecordingId: r.rec.Id,
+		UserId:      r.rec.UserId,
+		Host:        r.rec.Host,
+		HostUser:    r.rec.HostUser,
+		Timestamp:   time.Now(),
+		Command:     cmd,
+	}
+
+	err := command.Insert(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"recording_id": r.rec.Id.Hex(),
+			"error":        err,
+		}).Error("recording: Failed to store command")
+	}
+}
+
+func (r *Recorder) Resize(width, height int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	r.writeEvent("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) sync() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.lock.Lock()
+			chunk := r.takeChunk()
+			r.lock.Unlock()
+
+			r.storeChunk(chunk)
+		}
+	}
+}
+
+func (r *Recorder) Close() {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.stop)
+
+	chunk := r.takeChunk()
+	r.lock.Unlock()
+
+	r.storeChunk(chunk)
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	r.rec.End = time.Now()
+	err := r.rec.CommitFields(db, set.NewSet("end"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"recording_id": r.rec.Id.Hex(),
+			"error":        err,
+		}).Error("recording: Failed to update recording")
+	}
+}
+
+// Start a recording, the recording must have the user, authority and host
+// fields set
+func New(db *database.Database, rec *Recording, width, height int,
+	term string) (rcdr *Recorder, err error) {
+
+	if rec.UserId.IsZero() || rec.AuthorityId.IsZero() || rec.Host == "" {
+		err = &errortypes.ParseError{
+			errors.New("recording: Recording missing required fields"),
+		}
+		return
+	}
+
+	rec.Id = primitive.NewObjectID()
+	rec.Start = time.Now()
+
+	hdr := &header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: rec.Start.Unix(),
+	}
+	if term != "" {
+		hdr.Env = map[string]string{
+			"TERM": term,
+		}
+	}
+
+	hdrData, err := json.Marshal(hdr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "recording: Failed to marshal header"),
+		}
+		return
+	}
+
+	err = rec.Insert(db)
+	if err != nil {
+		return
+	}
+
+	rcdr = &Recorder{
+		rec:     rec,
+		buf:     &bytes.Buffer{},
+		partial: map[string][]byte{},
+		stop:    make(chan bool),
+	}
+
+	rcdr.buf.Write(hdrData)
+	rcdr.buf.WriteByte('\n')
+
+	go rcdr.sync()
+
+	return
+}
@@ -0,0 +1,112 @@
+// Bastion terminal session recordings.
+package recording
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+type Recording struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AuthorityId primitive.ObjectID `bson:"authority_id" json:"authority_id"`
+	UserId      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Serial      string             `bson:"serial" json:"serial"`
+	Host        string             `bson:"host" json:"host"`
+	Port        int                `bson:"port" json:"port"`
+	HostUser    string             `bson:"host_user" json:"host_user"`
+	RemoteAddr  string             `bson:"remote_addr" json:"remote_addr"`
+	Start       time.Time          `bson:"start" json:"start"`
+	End         time.Time          `bson:"end" json:"end"`
+	Size        int64              `bson:"size" json:"size"`
+	Chunks      int                `bson:"chunks" json:"chunks"`
+}
+
+type Chunk struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty"`
+	RecordingId primitive.ObjectID `bson:"recording_id"`
+	Index       int                `bson:"index"`
+	Data        []byte             `bson:"data"`
+}
+
+type Command struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RecordingId primitive.ObjectID `bson:"recording_id" json:"recording_id"`
+	UserId      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Host        string             `bson:"host" json:"host"`
+	HostUser    string             `bson:"host_user" json:"host_user"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+	Command     string             `bson:"command" json:"command"`
+}
+
+func (r *Recording) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Recordings()
+
+	err = coll.CommitFields(r.Id, r, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Count a stored chunk, chunks can be stored concurrently
+func (r *Recording) addChunk(db *database.Database, size int) (err error) {
+	coll := db.Recordings()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": r.Id,
+	}, &bson.M{
+		"$inc": &bson.M{
+			"chunks": 1,
+			"size":   size,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (r *Recording) Insert(db *database.Database) (err error) {
+	coll := db.Recordings()
+
+	_, err = coll.InsertOne(db, r)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (c *Chunk) Insert(db *database.Database) (err error) {
+	coll := db.RecordingChunks()
+
+	_, err = coll.InsertOne(db, c)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (c *Command) Insert(db *database.Database) (err error) {
+	coll := db.RecordingCommands()
+
+	_, err = coll.InsertOne(db, c)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
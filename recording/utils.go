@@ -0,0 +1,269 @@
+package recording
+
+import (
+	"bytes"
+	"regexp"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+func Get(db *database.Database, recId primitive.ObjectID) (
+	rec *Recording, err error) {
+
+	coll := db.Recordings()
+	rec = &Recording{}
+
+	err = coll.FindOneId(recId, rec)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, userId primitive.ObjectID,
+	page, pageCount int64) (recs []*Recording, count int64, err error) {
+
+	coll := db.Recordings()
+	recs = []*Recording{}
+
+	query := &bson.M{
+		"user_id": userId,
+	}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	opts := options.FindOptions{
+		Sort: &bson.D{
+			{"start", -1},
+		},
+	}
+
+	if pageCount != 0 {
+		page = utils.Min64(page, count/pageCount)
+		skip := utils.Min64(page*pageCount, count)
+		opts.Skip = &skip
+		opts.Limit = &pageCount
+	}
+
+	cursor, err := coll.Find(db, query, &opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		rec := &Recording{}
+		err = cursor.Decode(rec)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		recs = append(recs, rec)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Get commands run by the user, search matches a substring of the command
+func GetCommands(db *database.Database, userId primitive.ObjectID,
+	search string, page, pageCount int64) (
+	cmds []*Command, count int64, err error) {
+
+	coll := db.RecordingCommands()
+	cmds = []*Command{}
+
+	query := bson.M{
+		"user_id": userId,
+	}
+
+	if search != "" {
+		query["command"] = &bson.M{
+			"$regex": regexp.QuoteMeta(search),
+		}
+	}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	opts := options.FindOptions{
+		Sort: &bson.D{
+			{"timestamp", -1},
+		},
+	}
+
+	if pageCount != 0 {
+		page = utils.Min64(page, count/pageCount)
+		skip := utils.Min64(page*pageCount, count)
+		opts.Skip = &skip
+		opts.Limit = &pageCount
+	}
+
+	cursor, err := coll.Find(db, query, &opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cmd := &Command{}
+		err = cursor.Decode(cmd)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		cmds = append(cmds, cmd)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Get the asciicast data for a recording
+func GetData(db *database.Database, recId primitive.ObjectID) (
+	data []byte, err error) {
+
+	coll := db.RecordingChunks()
+	buf := &bytes.Buffer{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"recording_id": recId,
+	}, &options.FindOptions{
+		Sort: &bson.D{
+			{"index", 1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		chunk := &Chunk{}
+		err = cursor.Decode(chunk)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		buf.Write(chunk.Data)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	data = buf.Bytes()
+
+	return
+}
+
+// Remove recordings older than the retention setting
+func RemoveExpired(db *database.Database) (err error) {
+	if settings.System.RecordingRetention <= 0 {
+		return
+	}
+
+	expired := time.Now().Add(-time.Duration(
+		settings.System.RecordingRetention) * 24 * time.Hour)
+
+	coll := db.Recordings()
+	recIds := []primitive.ObjectID{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"start": &bson.M{
+			"$lt": expired,
+		},
+	}, &options.FindOptions{
+		Projection: &bson.D{
+			{"_id", 1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		rec := &Recording{}
+		err = cursor.Decode(rec)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		recIds = append(recIds, rec.Id)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if len(recIds) == 0 {
+		return
+	}
+
+	query := &bson.M{
+		"recording_id": &bson.M{
+			"$in": recIds,
+		},
+	}
+
+	_, err = db.RecordingChunks().DeleteMany(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	_, err = db.RecordingCommands().DeleteMany(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": recIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
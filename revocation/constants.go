@@ -0,0 +1,13 @@
+package revocation
+
+import (
+	"time"
+)
+
+const (
+	Serial = "serial"
+	Key    = "key"
+)
+
+// Maximum lifetime of issued user certificates
+const maxCertificateAge = 24 * time.Hour
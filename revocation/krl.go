@@ -0,0 +1,199 @@
+package revocation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"golang.org/x/crypto/ssh"
+)
+
+// OpenSSH key revocation list format from PROTOCOL.krl
+const (
+	krlMagic               = 0x5353484b524c0a00
+	krlFormatVersion       = 1
+	krlSectionCertificates = 1
+	krlSectionExplicitKey  = 2
+	krlCertSerialList      = 0x20
+)
+
+type krlWriter struct {
+	bytes.Buffer
+}
+
+func (w *krlWriter) writeByte(val byte) {
+	w.WriteByte(val)
+}
+
+func (w *krlWriter) writeUint32(val uint32) {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, val)
+	w.Write(buf)
+}
+
+func (w *krlWriter) writeUint64(val uint64) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, val)
+	w.Write(buf)
+}
+
+func (w *krlWriter) writeString(val []byte) {
+	w.writeUint32(uint32(len(val)))
+	w.Write(val)
+}
+
+// Parse authorized key format, certificates are converted to the key the
+// certificate was issued for
+func parsePubKey(pubKey string) (key ssh.PublicKey, comment string,
+	ok bool) {
+
+	key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	if err != nil {
+		return
+	}
+
+	if cert, isCert := key.(*ssh.Certificate); isCert {
+		key = cert.Key
+	}
+
+	ok = true
+	return
+}
+
+func normalizePubKey(pubKey string) (normalized string, ok bool) {
+	key, comment, ok := parsePubKey(pubKey)
+	if !ok {
+		return
+	}
+
+	normalized = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	if comment != "" {
+		normalized += " " + comment
+	}
+
+	return
+}
+
+// Generate an unsigned OpenSSH key revocation list for the authorities,
+// serials are revoked for every trusted key of the authority
+func GenerateKrl(db *database.Database, authrs []*authority.Authority) (
+	data []byte, err error) {
+
+	authrIds := []primitive.ObjectID{}
+	for _, authr := range authrs {
+		authrIds = append(authrIds, authr.Id)
+	}
+
+	revks, err := GetAuthorities(db, authrIds)
+	if err != nil {
+		return
+	}
+
+	data = encodeKrl(authrs, revks, time.Now())
+
+	return
+}
+
+func encodeKrl(authrs []*authority.Authority, revks []*Revocation,
+	now time.Time) []byte {
+
+	serials := map[primitive.ObjectID]map[uint64]bool{}
+	keys := map[string][]byte{}
+
+	for _, revk := range revks {
+		switch revk.Type {
+		case Serial:
+			serial, e := strconv.ParseUint(revk.Serial, 10, 64)
+			if e != nil || serial == 0 {
+				continue
+			}
+
+			if serials[revk.AuthorityId] == nil {
+				serials[revk.AuthorityId] = map[uint64]bool{}
+			}
+			serials[revk.AuthorityId][serial] = true
+
+			break
+		case Key:
+			key, _, ok := parsePubKey(revk.PubKey)
+			if !ok {
+				continue
+			}
+
+			blob := key.Marshal()
+			keys[string(blob)] = blob
+
+			break
+		}
+	}
+
+	w := &krlWriter{}
+
+	w.writeUint64(krlMagic)
+	w.writeUint32(krlFormatVersion)
+	w.writeUint64(uint64(now.UnixNano()))
+	w.writeUint64(uint64(now.Unix()))
+	w.writeUint64(0)
+	w.writeString(nil)
+	w.writeString([]byte("pritunl-zero"))
+
+	for _, authr := range authrs {
+		authrSerials := serials[authr.Id]
+		if len(authrSerials) == 0 {
+			continue
+		}
+
+		serialList := []uint64{}
+		for serial := range authrSerials {
+			serialList = append(serialList, serial)
+		}
+		sort.Slice(serialList, func(i, j int) bool {
+			return serialList[i] < serialList[j]
+		})
+
+		serialData := &krlWriter{}
+		for _, serial := range serialList {
+			serialData.writeUint64(serial)
+		}
+
+		for _, pubKey := range authr.TrustedPublicKeys() {
+			caKey, _, ok := parsePubKey(pubKey)
+			if !ok {
+				continue
+			}
+
+			section := &krlWriter{}
+			section.writeString(caKey.Marshal())
+			section.writeString(nil)
+			section.writeByte(krlCertSerialList)
+			section.writeString(serialData.Bytes())
+
+			w.writeByte(krlSectionCertificates)
+			w.writeString(section.Bytes())
+		}
+	}
+
+	if len(keys) > 0 {
+		blobs := []string{}
+		for blob := range keys {
+			blobs = append(blobs, blob)
+		}
+		sort.Strings(blobs)
+
+		section := &krlWriter{}
+		for _, blob := range blobs {
+			section.writeString(keys[blob])
+		}
+
+		w.writeByte(krlSectionExplicitKey)
+		w.writeString(section.Bytes())
+	}
+
+	return w.Bytes()
+}
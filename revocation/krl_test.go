@@ -0,0 +1,266 @@
+package revocation
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"golang.org/x/crypto/ssh"
+)
+
+type krlTest struct {
+	authr      *authority.Authority
+	caKey      ssh.Signer
+	revks      []*Revocation
+	revokedKey ssh.PublicKey
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func newKrlTest(t *testing.T) *krlTest {
+	caKey := newTestSigner(t)
+	revokedKey := newTestSigner(t).PublicKey()
+
+	authr := &authority.Authority{
+		Id:        primitive.NewObjectID(),
+		PublicKey: string(ssh.MarshalAuthorizedKey(caKey.PublicKey())),
+	}
+
+	return &krlTest{
+		authr:      authr,
+		caKey:      caKey,
+		revokedKey: revokedKey,
+		revks: []*Revocation{
+			&Revocation{
+				Type:        Serial,
+				AuthorityId: authr.Id,
+				Serial:      "7",
+			},
+			&Revocation{
+				Type:        Serial,
+				AuthorityId: authr.Id,
+				Serial:      "5",
+			},
+			&Revocation{
+				Type:        Serial,
+				AuthorityId: authr.Id,
+				Serial:      "invalid",
+			},
+			&Revocation{
+				Type:        Serial,
+				AuthorityId: primitive.NewObjectID(),
+				Serial:      "9",
+			},
+			&Revocation{
+				Type:   Key,
+				PubKey: string(ssh.MarshalAuthorizedKey(revokedKey)),
+			},
+		},
+	}
+}
+
+func (k *krlTest) signCert(t *testing.T, serial uint64) *ssh.Certificate {
+	cert := &ssh.Certificate{
+		Key:             newTestSigner(t).PublicKey(),
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"test"},
+		ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+		ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+	}
+
+	err := cert.SignCert(rand.Reader, k.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+type krlReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *krlReader) next(n int) []byte {
+	if len(r.data) < n {
+		r.t.Fatal("Unexpected end of krl")
+	}
+
+	val := r.data[:n]
+	r.data = r.data[n:]
+
+	return val
+}
+
+func (r *krlReader) readByte() byte {
+	return r.next(1)[0]
+}
+
+func (r *krlReader) readUint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *krlReader) readUint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *krlReader) readString() []byte {
+	return r.next(int(r.readUint32()))
+}
+
+func TestEncodeKrlParse(t *testing.T) {
+	krl := newKrlTest(t)
+	now := time.Now()
+
+	r := &krlReader{
+		t:    t,
+		data: encodeKrl([]*authority.Authority{krl.authr}, krl.revks, now),
+	}
+
+	if r.readUint64() != krlMagic {
+		t.Fatal("Bad krl magic")
+	}
+	if r.readUint32() != krlFormatVersion {
+		t.Fatal("Bad krl format version")
+	}
+	if r.readUint64() != uint64(now.UnixNano()) {
+		t.Error("Bad krl version")
+	}
+	if r.readUint64() != uint64(now.Unix()) {
+		t.Error("Bad krl generated date")
+	}
+	r.readUint64()
+	r.readString()
+	r.readString()
+
+	serials := []uint64{}
+	keys := [][]byte{}
+
+	for len(r.data) > 0 {
+		sectionType := r.readByte()
+		section := &krlReader{
+			t:    t,
+			data: r.readString(),
+		}
+
+		switch sectionType {
+		case krlSectionCertificates:
+			caKey := section.readString()
+			if !bytes.Equal(caKey, krl.caKey.PublicKey().Marshal()) {
+				t.Error("Bad certificate section authority key")
+			}
+			section.readString()
+
+			for len(section.data) > 0 {
+				if section.readByte() != krlCertSerialList {
+					t.Fatal("Unexpected certificate sub-section")
+				}
+
+				serialList := &krlReader{
+					t:    t,
+					data: section.readString(),
+				}
+				for len(serialList.data) > 0 {
+					serials = append(serials, serialList.readUint64())
+				}
+			}
+
+			break
+		case krlSectionExplicitKey:
+			for len(section.data) > 0 {
+				keys = append(keys, section.readString())
+			}
+
+			break
+		default:
+			t.Fatalf("Unexpected krl section %d", sectionType)
+		}
+	}
+
+	if len(serials) != 2 || serials[0] != 5 || serials[1] != 7 {
+		t.Errorf("Bad revoked serials %v", serials)
+	}
+
+	if len(keys) != 1 || !bytes.Equal(keys[0], krl.revokedKey.Marshal()) {
+		t.Error("Bad revoked keys")
+	}
+}
+
+func TestEncodeKrlSshKeygen(t *testing.T) {
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	krl := newKrlTest(t)
+
+	dir, err := ioutil.TempDir("", "pritunl-zero-krl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	krlPath := filepath.Join(dir, "krl")
+	err = ioutil.WriteFile(krlPath, encodeKrl(
+		[]*authority.Authority{krl.authr}, krl.revks, time.Now()), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []struct {
+		name    string
+		key     ssh.PublicKey
+		revoked bool
+	}{
+		{"revoked_serial", krl.signCert(t, 5), true},
+		{"valid_serial", krl.signCert(t, 6), false},
+		{"revoked_key", krl.revokedKey, true},
+		{"valid_key", newTestSigner(t).PublicKey(), false},
+	}
+
+	for _, key := range keys {
+		keyPath := filepath.Join(dir, key.name+".pub")
+		err = ioutil.WriteFile(
+			keyPath, ssh.MarshalAuthorizedKey(key.key), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output, err := exec.Command(
+			sshKeygen, "-Q", "-f", krlPath, keyPath).CombinedOutput()
+		if err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				t.Fatal(err)
+			}
+		}
+
+		revoked := bytes.Contains(output, []byte("REVOKED"))
+		if revoked != key.revoked {
+			t.Errorf("%s: Unexpected ssh-keygen result: %s",
+				key.name, output)
+		}
+	}
+}
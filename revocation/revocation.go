@@ -0,0 +1,366 @@
+// Revocation of SSH certificates and keys.
+package revocation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type Revocation struct {
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          string             `bson:"type" json:"type"`
+	AuthorityId   primitive.ObjectID `bson:"authority_id,omitempty" json:"authority_id"`
+	Serial        string             `bson:"serial" json:"serial"`
+	PubKey        string             `bson:"pub_key" json:"pub_key"`
+	UserId        primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	CertificateId primitive.ObjectID `bson:"certificate_id,omitempty" json:"certificate_id"`
+	Reason        string             `bson:"reason" json:"reason"`
+	Timestamp     time.Time          `bson:"timestamp" json:"timestamp"`
+	Expires       time.Time          `bson:"expires" json:"expires"`
+}
+
+// Serial revocations are only needed until the certificate expires, key
+// revocations do not expire
+func (r *Revocation) Expired() bool {
+	return r.Type == Serial && !r.Expires.IsZero() &&
+		time.Now().After(r.Expires)
+}
+
+func (r *Revocation) Insert(db *database.Database) (err error) {
+	coll := db.Revocations()
+
+	if !r.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("revocation: Revocation already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, r)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Revoke all unexpired certificates in an issued certificate set
+func RevokeCertificate(db *database.Database, cert *ssh.Certificate,
+	reason string) (count int, err error) {
+
+	now := time.Now()
+
+	for i, info := range cert.CertificatesInfo {
+		if i >= len(cert.AuthorityIds) || now.After(info.Expires) {
+			continue
+		}
+
+		serial, e := strconv.ParseUint(info.Serial, 10, 64)
+		if e != nil || serial == 0 {
+			continue
+		}
+
+		revk := &Revocation{
+			Type:          Serial,
+			AuthorityId:   cert.AuthorityIds[i],
+			Serial:        strconv.FormatUint(serial, 10),
+			UserId:        cert.UserId,
+			CertificateId: cert.Id,
+			Reason:        reason,
+			Timestamp:     now,
+			Expires:       info.Expires,
+		}
+
+		err = revk.Insert(db)
+		if err != nil {
+			return
+		}
+
+		count += 1
+	}
+
+	return
+}
+
+// Revoke all unexpired certificates issued to the user
+func RevokeUser(db *database.Database, userId primitive.ObjectID,
+	reason string) (count int, err error) {
+
+	if settings.System.Demo {
+		return
+	}
+
+	coll := db.SshCertificates()
+
+	cursor, err := coll.Find(db, &bson.M{
+		"user_id": userId,
+		"timestamp": &bson.M{
+			"$gte": time.Now().Add(-maxCertificateAge),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	certs := []*ssh.Certificate{}
+	for cursor.Next(db) {
+		cert := &ssh.Certificate{}
+		err = cursor.Decode(cert)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		certs = append(certs, cert)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	for _, cert := range certs {
+		n, e := RevokeCertificate(db, cert, reason)
+		if e != nil {
+			err = e
+			return
+		}
+		count += n
+	}
+
+	return
+}
+
+// Revoke a public key, certificates issued for the key are also rejected
+func RevokeKey(db *database.Database, pubKey string,
+	userId primitive.ObjectID, reason string) (
+	revk *Revocation, errData *errortypes.ErrorData, err error) {
+
+	pubKey = strings.TrimSpace(pubKey)
+
+	if len(pubKey) > settings.System.SshPubKeyLen {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_invalid",
+			Message: "Public key is too long",
+		}
+		return
+	}
+
+	pubKey, ok := normalizePubKey(pubKey)
+	if !ok {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_invalid",
+			Message: "Public key is invalid",
+		}
+		return
+	}
+
+	revk = &Revocation{
+		Type:      Key,
+		PubKey:    pubKey,
+		UserId:    userId,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+
+	err = revk.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database) (revks []*Revocation, err error) {
+	coll := db.Revocations()
+	revks = []*Revocation{}
+
+	cursor, err := coll.Find(db, &bson.M{})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		revk := &Revocation{}
+		err = cursor.Decode(revk)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		if revk.Expired() {
+			continue
+		}
+
+		revks = append(revks, revk)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Get serial revocations for the authorities and all key revocations
+func GetAuthorities(db *database.Database,
+	authrIds []primitive.ObjectID) (revks []*Revocation, err error) {
+
+	coll := db.Revocations()
+	revks = []*Revocation{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"type": Serial,
+				"authority_id": &bson.M{
+					"$in": authrIds,
+				},
+			},
+			&bson.M{
+				"type": Key,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		revk := &Revocation{}
+		err = cursor.Decode(revk)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		if revk.Expired() {
+			continue
+		}
+
+		revks = append(revks, revk)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Revoked serials and keys for an authority
+type Revoked struct {
+	serials map[uint64]bool
+	keys    map[string]bool
+}
+
+// Check if the certificate serial or the key the certificate was issued for
+// has been revoked
+func (r *Revoked) Contains(cert *gossh.Certificate) bool {
+	if r == nil {
+		return false
+	}
+
+	if cert.Serial != 0 && r.serials[cert.Serial] {
+		return true
+	}
+
+	return r.keys[string(cert.Key.Marshal())]
+}
+
+func GetRevoked(db *database.Database, authrId primitive.ObjectID) (
+	revoked *Revoked, err error) {
+
+	revks, err := GetAuthorities(db, []primitive.ObjectID{authrId})
+	if err != nil {
+		return
+	}
+
+	revoked = &Revoked{
+		serials: map[uint64]bool{},
+		keys:    map[string]bool{},
+	}
+
+	for _, revk := range revks {
+		switch revk.Type {
+		case Serial:
+			serial, e := strconv.ParseUint(revk.Serial, 10, 64)
+			if e != nil || serial == 0 {
+				continue
+			}
+
+			revoked.serials[serial] = true
+
+			break
+		case Key:
+			key, _, ok := parsePubKey(revk.PubKey)
+			if !ok {
+				continue
+			}
+
+			revoked.keys[string(key.Marshal())] = true
+
+			break
+		}
+	}
+
+	return
+}
+
+func Remove(db *database.Database, revkId primitive.ObjectID) (err error) {
+	coll := db.Revocations()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": revkId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveExpired(db *database.Database) (err error) {
+	coll := db.Revocations()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"type": Serial,
+		"expires": &bson.M{
+			"$lt": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,40 @@
+package revocation
+
+import (
+	"testing"
+)
+
+func TestRevokedContains(t *testing.T) {
+	krl := newKrlTest(t)
+
+	revoked := &Revoked{
+		serials: map[uint64]bool{
+			5: true,
+		},
+		keys: map[string]bool{
+			string(krl.revokedKey.Marshal()): true,
+		},
+	}
+
+	if !revoked.Contains(krl.signCert(t, 5)) {
+		t.Error("Revoked serial not matched")
+	}
+
+	if revoked.Contains(krl.signCert(t, 6)) {
+		t.Error("Valid serial matched")
+	}
+
+	cert := krl.signCert(t, 0)
+	if revoked.Contains(cert) {
+		t.Error("Valid key matched")
+	}
+
+	cert.Key = krl.revokedKey
+	if !revoked.Contains(cert) {
+		t.Error("Revoked key not matched")
+	}
+
+	if (*Revoked)(nil).Contains(cert) {
+		t.Error("Nil revoked matched")
+	}
+}
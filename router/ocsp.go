@@ -0,0 +1,250 @@
+package router
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspCheckInterval = 1 * time.Minute
+	ocspRetryInterval = 5 * time.Minute
+	ocspMaxRefresh    = 12 * time.Hour
+	ocspMaxSize       = 1048576
+)
+
+var ocspClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+type ocspState struct {
+	refresh    time.Time
+	nextUpdate time.Time
+}
+
+// Serves certificates with stapled OCSP responses, responses are refreshed
+// in the background and certificates are served without a staple when a
+// response is unavailable or stale
+type stapler struct {
+	lock   sync.RWMutex
+	certs  []*tls.Certificate
+	states []*ocspState
+	stop   chan bool
+}
+
+func (s *stapler) GetCertificate(hello *tls.ClientHelloInfo) (
+	*tls.Certificate, error) {
+
+	s.lock.RLock()
+	certs := s.certs
+	s.lock.RUnlock()
+
+	for _, cert := range certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+
+	if len(certs) > 0 {
+		return certs[0], nil
+	}
+
+	return nil, nil
+}
+
+func (s *stapler) refresh() {
+	now := time.Now()
+
+	for i, state := range s.states {
+		if now.Before(state.refresh) {
+			continue
+		}
+
+		s.lock.RLock()
+		cert := s.certs[i]
+		s.lock.RUnlock()
+
+		staple, resp, err := fetchOcsp(cert)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"subject": cert.Leaf.Subject.CommonName,
+				"error":   err,
+			}).Warn("router: Failed to fetch OCSP response")
+
+			state.refresh = now.Add(ocspRetryInterval)
+			if !state.nextUpdate.IsZero() &&
+				now.After(state.nextUpdate) {
+
+				state.nextUpdate = time.Time{}
+				s.setStaple(i, nil)
+			}
+			continue
+		}
+
+		if staple == nil {
+			state.refresh = now.Add(ocspMaxRefresh)
+			continue
+		}
+
+		if resp.Status != ocsp.Good {
+			logrus.WithFields(logrus.Fields{
+				"subject": cert.Leaf.Subject.CommonName,
+				"status":  resp.Status,
+			}).Warn("router: OCSP certificate status not good")
+
+			state.refresh = now.Add(ocspRetryInterval)
+			state.nextUpdate = time.Time{}
+			s.setStaple(i, nil)
+			continue
+		}
+
+		refresh := ocspMaxRefresh
+		if !resp.NextUpdate.IsZero() {
+			half := resp.NextUpdate.Sub(resp.ThisUpdate) / 2
+			if half < refresh {
+				refresh = half
+			}
+		}
+		if refresh < ocspRetryInterval {
+			refresh = ocspRetryInterval
+		}
+
+		state.refresh = now.Add(refresh)
+		state.nextUpdate = resp.NextUpdate
+		s.setStaple(i, staple)
+	}
+}
+
+func (s *stapler) setStaple(i int, staple []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	certs := make([]*tls.Certificate, len(s.certs))
+	copy(certs, s.certs)
+
+	cert := &tls.Certificate{}
+	*cert = *certs[i]
+	cert.OCSPStaple = staple
+	certs[i] = cert
+
+	s.certs = certs
+}
+
+func (s *stapler) run() {
+	for {
+		s.refresh()
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(ocspCheckInterval):
+		}
+	}
+}
+
+func (s *stapler) Stop() {
+	close(s.stop)
+}
+
+// Fetch OCSP response for certificate, returns nil staple when the
+// certificate has no issuer or OCSP server, the response status must be
+// checked before stapling
+func fetchOcsp(cert *tls.Certificate) (staple []byte,
+	resp *ocsp.Response, err error) {
+
+	if len(cert.Certificate) < 2 || len(cert.Leaf.OCSPServer) == 0 {
+		return
+	}
+
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "router: Failed to parse issuer certificate"),
+		}
+		return
+	}
+
+	reqData, err := ocsp.CreateRequest(cert.Leaf, issuer, nil)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "router: Failed to create OCSP request"),
+		}
+		return
+	}
+
+	httpResp, err := ocspClient.Post(
+		cert.Leaf.OCSPServer[0],
+		"application/ocsp-request",
+		bytes.NewReader(reqData),
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "router: OCSP request failed"),
+		}
+		return
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("router: OCSP server error %d",
+				httpResp.StatusCode),
+		}
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, ocspMaxSize))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "router: Failed to read OCSP response"),
+		}
+		return
+	}
+
+	resp, err = ocsp.ParseResponseForCert(data, cert.Leaf, issuer)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "router: Failed to parse OCSP response"),
+		}
+		return
+	}
+
+	staple = data
+
+	return
+}
+
+func newStapler(keypairs []tls.Certificate) (s *stapler) {
+	s = &stapler{
+		certs:  []*tls.Certificate{},
+		states: []*ocspState{},
+		stop:   make(chan bool),
+	}
+
+	for i := range keypairs {
+		cert := &keypairs[i]
+
+		if cert.Leaf == nil {
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				continue
+			}
+			cert.Leaf = leaf
+		}
+
+		s.certs = append(s.certs, cert)
+		s.states = append(s.states, &ocspState{})
+	}
+
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/md5"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"math/rand"
@@ -18,6 +19,7 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/hydeant/pritunl-zero/acme"
+	"github.com/hydeant/pritunl-zero/auth"
 	"github.com/hydeant/pritunl-zero/certificate"
 	"github.com/hydeant/pritunl-zero/constants"
 	"github.com/hydeant/pritunl-zero/errortypes"
@@ -38,6 +40,9 @@ type Router struct {
 	noRedirectServer bool
 	protocol         string
 	certificates     []*certificate.Certificate
+	tlsConfig        *tls.Config
+	ocspStapling     bool
+	adminCas         *x509.CertPool
 	managementDomain string
 	userDomain       string
 	mRouter          *gin.Engine
@@ -173,6 +178,8 @@ func (r *Router) initWeb() (err error) {
 	r.managementDomain = node.Self.ManagementDomain
 	r.userDomain = node.Self.UserDomain
 	r.certificates = node.Self.CertificateObjs
+	r.tlsConfig = node.Self.NewTlsConfig()
+	r.ocspStapling = node.Self.OcspStapling
 	r.noRedirectServer = node.Self.NoRedirectServer
 
 	r.port = node.Self.Port
@@ -186,6 +193,11 @@ func (r *Router) initWeb() (err error) {
 	}
 
 	if strings.Contains(r.typ, node.Management) {
+		r.adminCas, err = auth.AdminCertificatePool()
+		if err != nil {
+			return
+		}
+
 		r.mRouter = gin.New()
 
 		if !constants.Production {
@@ -272,10 +284,7 @@ func (r *Router) startWeb() {
 			}
 		}
 	} else {
-		tlsConfig := &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			MaxVersion: tls.VersionTLS13,
-		}
+		tlsConfig := r.tlsConfig
 		tlsConfig.Certificates = []tls.Certificate{}
 
 		if r.certificates != nil {
@@ -336,6 +345,45 @@ func (r *Router) startWeb() {
 
 		tlsConfig.BuildNameToCertificate()
 
+		if r.ocspStapling {
+			stplr := newStapler(tlsConfig.Certificates)
+			tlsConfig.GetCertificate = stplr.GetCertificate
+
+			// Connections without a server name are served the first
+			// certificate without calling GetCertificate, remove the
+			// certificates to include the staple on the default certificate
+			tlsConfig.Certificates = nil
+			tlsConfig.NameToCertificate = nil
+
+			go stplr.run()
+			defer stplr.Stop()
+		}
+
+		// Client certificates are always requested on the management
+		// domain and verified by the handlers, this allows enabling admin
+		// certificates with a certificate signed by the new CAs
+		if strings.Contains(r.typ, node.Management) {
+			if r.typ == node.Management {
+				tlsConfig.ClientAuth = tls.RequestClientCert
+				tlsConfig.ClientCAs = r.adminCas
+			} else {
+				// Only request client certificates on the management
+				// domain to avoid prompting users of other domains
+				mTlsConfig := tlsConfig.Clone()
+				mTlsConfig.ClientAuth = tls.RequestClientCert
+				mTlsConfig.ClientCAs = r.adminCas
+
+				tlsConfig.GetConfigForClient = func(
+					hello *tls.ClientHelloInfo) (*tls.Config, error) {
+
+					if hello.ServerName == r.managementDomain {
+						return mTlsConfig, nil
+					}
+					return nil, nil
+				}
+			}
+		}
+
 		r.webServer.TLSConfig = tlsConfig
 
 		listener, err := tls.Listen("tcp", r.webServer.Addr, tlsConfig)
@@ -461,6 +509,12 @@ func (r *Router) hashNode() []byte {
 	io.WriteString(hash, strconv.Itoa(node.Self.Port))
 	io.WriteString(hash, fmt.Sprintf("%t", node.Self.NoRedirectServer))
 	io.WriteString(hash, node.Self.Protocol)
+	io.WriteString(hash, node.Self.TlsMinVersion)
+	io.WriteString(hash, strings.Join(node.Self.TlsCipherSuites, ","))
+	io.WriteString(hash, strings.Join(node.Self.TlsCurves, ","))
+	io.WriteString(hash, fmt.Sprintf("%t", node.Self.OcspStapling))
+	io.WriteString(hash, fmt.Sprintf("%t", settings.Auth.AdminCertificate))
+	io.WriteString(hash, settings.Auth.AdminCertificateCas)
 
 	io.WriteString(hash, strconv.Itoa(settings.Router.ReadTimeout))
 	io.WriteString(hash, strconv.Itoa(settings.Router.ReadHeaderTimeout))
@@ -1,5 +1,26 @@
 package service
 
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
 	Http = "http"
+
+	MaintenanceRetry = 300
+
+	DefaultServerGroup = "default"
+
+	MirrorBodyLimit = 1048576
+
+	LabelTemplate = "{label}"
+)
+
+var (
+	CorsSimpleMethods   = set.NewSet("GET", "HEAD", "POST")
+	DefaultStripHeaders = []string{
+		"Server",
+		"X-Powered-By",
+		"X-AspNet-Version",
+	}
 )
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/hydeant/pritunl-zero/errortypes"
+)
+
+var labelRe = regexp.MustCompile("^[a-z0-9-]+$")
+
+type Domain struct {
+	Domain string `bson:"domain" json:"domain"`
+	Host   string `bson:"host" json:"host"`
+	Path   string `bson:"path" json:"path"`
+}
+
+// Wildcard domains begin with "*." and match any host below the parent
+func (d *Domain) Wildcard() bool {
+	return strings.HasPrefix(d.Domain, "*.")
+}
+
+func (d *Domain) Parent() string {
+	if d.Wildcard() {
+		return d.Domain[2:]
+	}
+	return d.Domain
+}
+
+// Wildcard label is a single DNS label, it is copied into the upstream
+// host and path
+func ValidLabel(label string) bool {
+	return labelRe.MatchString(label)
+}
+
+// Match host against domain, the label is the part of the host matched
+// by the wildcard and is empty for exact domains
+func (d *Domain) MatchLabel(host string) (label string, ok bool) {
+	if !d.Wildcard() {
+		ok = host == d.Domain
+		return
+	}
+
+	suffix := d.Domain[1:]
+	if len(host) <= len(suffix) || !strings.HasSuffix(host, suffix) {
+		return
+	}
+
+	label = host[:len(host)-len(suffix)]
+	if !ValidLabel(label) {
+		label = ""
+		return
+	}
+	ok = true
+
+	return
+}
+
+func (d *Domain) FormatHost(label string) string {
+	return strings.Replace(d.Host, LabelTemplate, label, -1)
+}
+
+func (d *Domain) FormatPath(label string) string {
+	return strings.TrimRight(
+		strings.Replace(d.Path, LabelTemplate, label, -1), "/")
+}
+
+func validateDomains(domains []*Domain) (errData *errortypes.ErrorData) {
+	for _, domain := range domains {
+		domain.Domain = strings.ToLower(strings.TrimSpace(domain.Domain))
+		domain.Host = strings.TrimSpace(domain.Host)
+		domain.Path = strings.TrimSpace(domain.Path)
+
+		if strings.Contains(domain.Parent(), "*") ||
+			(domain.Wildcard() && !strings.Contains(domain.Parent(), ".")) {
+
+			errData = &errortypes.ErrorData{
+				Error: "domain_wildcard_invalid",
+				Message: "Wildcard domains must begin with '*.' " +
+					"followed by at least two labels",
+			}
+			return
+		}
+
+		if !domain.Wildcard() && (strings.Contains(
+			domain.Host, LabelTemplate) || strings.Contains(
+			domain.Path, LabelTemplate)) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "domain_label_invalid",
+				Message: "Label template requires a wildcard domain",
+			}
+			return
+		}
+
+		if domain.Path != "" && !strings.HasPrefix(domain.Path, "/") {
+			errData = &errortypes.ErrorData{
+				Error:   "domain_path_invalid",
+				Message: "Domain path must begin with '/'",
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/errortypes"
+)
+
+type ServerGroup struct {
+	Name    string               `bson:"name" json:"name"`
+	Weight  int                  `bson:"weight" json:"weight"`
+	Roles   []string             `bson:"roles" json:"roles"`
+	Users   []primitive.ObjectID `bson:"users" json:"users"`
+	Servers []*Server            `bson:"servers" json:"servers"`
+}
+
+func (g *ServerGroup) forced(userId primitive.ObjectID,
+	userRoles set.Set) bool {
+
+	for _, usrId := range g.Users {
+		if usrId == userId {
+			return true
+		}
+	}
+
+	for _, role := range g.Roles {
+		if userRoles.Contains(role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns index of the server group assigned to the user or -1 for the
+// default servers. Assignment is sticky by hashing the service and user id.
+func (s *Service) SelectServerGroup(userId primitive.ObjectID,
+	roles []string) int {
+
+	if len(s.ServerGroups) == 0 || userId.IsZero() {
+		return -1
+	}
+
+	userRoles := set.NewSet()
+	for _, role := range roles {
+		userRoles.Add(role)
+	}
+
+	for i, group := range s.ServerGroups {
+		if group.forced(userId, userRoles) {
+			return i
+		}
+	}
+
+	hash := fnv.New32a()
+	hash.Write(s.Id[:])
+	hash.Write(userId[:])
+	bucket := int(binary.BigEndian.Uint32(hash.Sum(nil)) % 100)
+
+	weight := 0
+	for i, group := range s.ServerGroups {
+		weight += group.Weight
+		if bucket < weight {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func validateServers(servers []*Server) (errData *errortypes.ErrorData) {
+	for _, server := range servers {
+		if server.Protocol != "http" && server.Protocol != "https" {
+			errData = &errortypes.ErrorData{
+				Error:   "service_protocol_invalid",
+				Message: "Invalid service server protocol",
+			}
+			return
+		}
+
+		if server.Hostname == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "service_hostname_invalid",
+				Message: "Invalid service server hostname",
+			}
+			return
+		}
+
+		if server.Port == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "service_port_invalid",
+				Message: "Invalid service server port",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func validateServerGroups(groups []*ServerGroup) (
+	errData *errortypes.ErrorData) {
+
+	names := set.NewSet(DefaultServerGroup)
+	weight := 0
+
+	for _, group := range groups {
+		if group.Name == "" || names.Contains(group.Name) {
+			errData = &errortypes.ErrorData{
+				Error:   "server_group_name_invalid",
+				Message: "Server group name must be set and unique",
+			}
+			return
+		}
+		names.Add(group.Name)
+
+		if group.Weight < 0 || group.Weight > 100 {
+			errData = &errortypes.ErrorData{
+				Error:   "server_group_weight_invalid",
+				Message: "Server group weight must be between 0 and 100",
+			}
+			return
+		}
+		weight += group.Weight
+
+		if group.Roles == nil {
+			group.Roles = []string{}
+		}
+
+		if group.Users == nil {
+			group.Users = []primitive.ObjectID{}
+		}
+
+		if group.Servers == nil || len(group.Servers) == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "server_group_servers_invalid",
+				Message: "Server group requires at least one server",
+			}
+			return
+		}
+
+		errData = validateServers(group.Servers)
+		if errData != nil {
+			return
+		}
+	}
+
+	if weight > 100 {
+		errData = &errortypes.ErrorData{
+			Error:   "server_group_weight_invalid",
+			Message: "Server group weights cannot exceed 100",
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/event"
+)
+
+type MaintenanceWindow struct {
+	Start time.Time `bson:"start" json:"start"`
+	End   time.Time `bson:"end" json:"end"`
+}
+
+func (s *Service) maintenanceWindow(now time.Time) *MaintenanceWindow {
+	for _, window := range s.MaintenanceWindows {
+		if !now.Before(window.Start) && now.Before(window.End) {
+			return window
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) MaintenanceScheduled(now time.Time) bool {
+	return s.Maintenance || s.maintenanceWindow(now) != nil
+}
+
+func (s *Service) MaintenanceRetryAfter(now time.Time) int {
+	if !s.Maintenance {
+		window := s.maintenanceWindow(now)
+		if window != nil {
+			retry := int(window.End.Sub(now).Seconds())
+			if retry < 1 {
+				retry = 1
+			}
+			return retry
+		}
+	}
+
+	return MaintenanceRetry
+}
+
+func (s *Service) SyncMaintenance(db *database.Database, now time.Time) (
+	err error) {
+
+	active := s.MaintenanceScheduled(now)
+	if active == s.MaintenanceActive {
+		return
+	}
+
+	s.MaintenanceActive = active
+	err = s.CommitFields(db, set.NewSet("maintenance_active"))
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"service_id":   s.Id.Hex(),
+		"service_name": s.Name,
+		"maintenance":  active,
+	}).Info("service: Service maintenance changed")
+
+	err = event.Publish(db, "service_maintenance", s.Id.Hex())
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "service.change")
+
+	return
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"crypto/x509"
+	"encoding/hex"
 	"net"
+	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
@@ -15,36 +19,54 @@ import (
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
-type Domain struct {
-	Domain string `bson:"domain" json:"domain"`
-	Host   string `bson:"host" json:"host"`
-}
-
 type Server struct {
 	Protocol string `bson:"protocol" json:"protocol"`
 	Hostname string `bson:"hostname" json:"hostname"`
 	Port     int    `bson:"port" json:"port"`
 }
 
+type Header struct {
+	Key   string `bson:"key" json:"key"`
+	Value string `bson:"value" json:"value"`
+}
+
 type WhitelistPath struct {
 	Path     string `bson:"path" json:"path"`
 	extMatch int
 }
 
 type Service struct {
-	Id                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name               string             `bson:"name" json:"name"`
-	Type               string             `bson:"type" json:"type"`
-	ShareSession       bool               `bson:"share_session" json:"share_session"`
-	LogoutPath         string             `bson:"logout_path" json:"logout_path"`
-	WebSockets         bool               `bson:"websockets" json:"websockets"`
-	DisableCsrfCheck   bool               `bson:"disable_csrf_check" json:"disable_csrf_check"`
-	ClientAuthority    primitive.ObjectID `bson:"client_authority,omitempty" json:"client_authority"`
-	Domains            []*Domain          `bson:"domains" json:"domains"`
-	Roles              []string           `bson:"roles" json:"roles"`
-	Servers            []*Server          `bson:"servers" json:"servers"`
-	WhitelistNetworks  []string           `bson:"whitelist_networks" json:"whitelist_networks"`
-	WhitelistPaths     []*WhitelistPath   `bson:"whitelist_paths" json:"whitelist_paths"`
+	Id                 primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name               string               `bson:"name" json:"name"`
+	Type               string               `bson:"type" json:"type"`
+	ShareSession       bool                 `bson:"share_session" json:"share_session"`
+	LogoutPath         string               `bson:"logout_path" json:"logout_path"`
+	WebSockets         bool                 `bson:"websockets" json:"websockets"`
+	DisableCsrfCheck   bool                 `bson:"disable_csrf_check" json:"disable_csrf_check"`
+	ClientAuthority    primitive.ObjectID   `bson:"client_authority,omitempty" json:"client_authority"`
+	Domains            []*Domain            `bson:"domains" json:"domains"`
+	Roles              []string             `bson:"roles" json:"roles"`
+	Servers            []*Server            `bson:"servers" json:"servers"`
+	ServerGroups       []*ServerGroup       `bson:"server_groups" json:"server_groups"`
+	MirrorServer       *Server              `bson:"mirror_server" json:"mirror_server"`
+	MirrorPercent      float64              `bson:"mirror_percent" json:"mirror_percent"`
+	MirrorBodyLimit    int64                `bson:"mirror_body_limit" json:"mirror_body_limit"`
+	WhitelistNetworks  []string             `bson:"whitelist_networks" json:"whitelist_networks"`
+	WhitelistPaths     []*WhitelistPath     `bson:"whitelist_paths" json:"whitelist_paths"`
+	UpstreamCerts      string               `bson:"upstream_certs" json:"upstream_certs"`
+	UpstreamPins       []string             `bson:"upstream_pins" json:"upstream_pins"`
+	ResponseHeaders    []*Header            `bson:"response_headers" json:"response_headers"`
+	StripHeaders       []string             `bson:"strip_headers" json:"strip_headers"`
+	CorsOrigins        []string             `bson:"cors_origins" json:"cors_origins"`
+	CorsMethods        []string             `bson:"cors_methods" json:"cors_methods"`
+	CorsHeaders        []string             `bson:"cors_headers" json:"cors_headers"`
+	CorsCredentials    bool                 `bson:"cors_credentials" json:"cors_credentials"`
+	CorsMaxAge         int                  `bson:"cors_max_age" json:"cors_max_age"`
+	Maintenance        bool                 `bson:"maintenance" json:"maintenance"`
+	MaintenanceActive  bool                 `bson:"maintenance_active" json:"maintenance_active"`
+	MaintenanceWindows []*MaintenanceWindow `bson:"maintenance_windows" json:"maintenance_windows"`
+	MaintenancePage    string               `bson:"maintenance_page" json:"maintenance_page"`
+	MaintenanceRole    string               `bson:"maintenance_role" json:"maintenance_role"`
 	logoutPathExtMatch int
 }
 
@@ -104,6 +126,67 @@ func (s *Service) MatchWhitelistPath(matchPth string) bool {
 	return false
 }
 
+func (s *Service) CorsEnabled() bool {
+	return len(s.CorsOrigins) > 0
+}
+
+func (s *Service) MatchCorsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range s.CorsOrigins {
+		// Credentialed origins are matched exactly
+		if s.CorsCredentials {
+			if allowed == origin {
+				return true
+			}
+			continue
+		}
+
+		if utils.Match(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) MatchCorsMethod(method string) bool {
+	if len(s.CorsMethods) == 0 {
+		return CorsSimpleMethods.Contains(method)
+	}
+
+	for _, allowed := range s.CorsMethods {
+		if allowed == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) UpstreamVerify() bool {
+	return s.UpstreamCerts != "" || len(s.UpstreamPins) > 0
+}
+
+func (s *Service) UpstreamRoots() (pool *x509.CertPool, err error) {
+	if s.UpstreamCerts == "" {
+		return
+	}
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(s.UpstreamCerts)) {
+		pool = nil
+		err = &errortypes.ParseError{
+			errors.New("service: Failed to parse upstream certificates"),
+		}
+		return
+	}
+
+	return
+}
+
 func (s *Service) RemoveWhitelistNetworks() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
@@ -144,30 +227,77 @@ func (s *Service) Validate(db *database.Database) (
 		s.WhitelistPaths = []*WhitelistPath{}
 	}
 
-	for _, server := range s.Servers {
-		if server.Protocol != "http" && server.Protocol != "https" {
-			errData = &errortypes.ErrorData{
-				Error:   "service_protocol_invalid",
-				Message: "Invalid service server protocol",
-			}
+	if s.UpstreamPins == nil {
+		s.UpstreamPins = []string{}
+	}
+
+	if s.ResponseHeaders == nil {
+		s.ResponseHeaders = []*Header{}
+	}
+
+	if s.StripHeaders == nil {
+		s.StripHeaders = []string{}
+	}
+
+	if s.CorsOrigins == nil {
+		s.CorsOrigins = []string{}
+	}
+
+	if s.CorsMethods == nil {
+		s.CorsMethods = []string{}
+	}
+
+	if s.CorsHeaders == nil {
+		s.CorsHeaders = []string{}
+	}
+
+	if s.MaintenanceWindows == nil {
+		s.MaintenanceWindows = []*MaintenanceWindow{}
+	}
+
+	errData = validateDomains(s.Domains)
+	if errData != nil {
+		return
+	}
+
+	errData = validateServers(s.Servers)
+	if errData != nil {
+		return
+	}
+
+	if s.ServerGroups == nil {
+		s.ServerGroups = []*ServerGroup{}
+	}
+
+	errData = validateServerGroups(s.ServerGroups)
+	if errData != nil {
+		return
+	}
+
+	if s.MirrorServer != nil && s.MirrorServer.Hostname == "" {
+		s.MirrorServer = nil
+	}
+
+	if s.MirrorServer != nil {
+		errData = validateServers([]*Server{s.MirrorServer})
+		if errData != nil {
 			return
 		}
 
-		if server.Hostname == "" {
+		if s.MirrorPercent < 0 || s.MirrorPercent > 100 {
 			errData = &errortypes.ErrorData{
-				Error:   "service_hostname_invalid",
-				Message: "Invalid service server hostname",
+				Error:   "mirror_percent_invalid",
+				Message: "Mirror percent must be between 0 and 100",
 			}
 			return
 		}
 
-		if server.Port == 0 {
-			errData = &errortypes.ErrorData{
-				Error:   "service_port_invalid",
-				Message: "Invalid service server port",
-			}
-			return
+		if s.MirrorBodyLimit <= 0 {
+			s.MirrorBodyLimit = MirrorBodyLimit
 		}
+	} else {
+		s.MirrorPercent = 0
+		s.MirrorBodyLimit = 0
 	}
 
 	for _, cidr := range s.WhitelistNetworks {
@@ -182,6 +312,110 @@ func (s *Service) Validate(db *database.Database) (
 		}
 	}
 
+	s.UpstreamCerts = strings.TrimSpace(s.UpstreamCerts)
+	if s.UpstreamCerts != "" {
+		_, err = s.UpstreamRoots()
+		if err != nil {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "upstream_certs_invalid",
+				Message: "Upstream certificates not valid PEM certificates",
+			}
+			return
+		}
+	}
+
+	pins := []string{}
+	for _, pin := range s.UpstreamPins {
+		pin = strings.ToLower(strings.Replace(
+			strings.TrimSpace(pin), ":", "", -1))
+		if pin == "" {
+			continue
+		}
+
+		pinByt, e := hex.DecodeString(pin)
+		if e != nil || len(pinByt) != 32 {
+			errData = &errortypes.ErrorData{
+				Error:   "upstream_pin_invalid",
+				Message: "Upstream pin must be a SHA-256 hex fingerprint",
+			}
+			return
+		}
+
+		pins = append(pins, pin)
+	}
+	s.UpstreamPins = pins
+
+	for _, header := range s.ResponseHeaders {
+		header.Key = http.CanonicalHeaderKey(strings.TrimSpace(header.Key))
+		header.Value = strings.TrimSpace(header.Value)
+
+		if header.Key == "" || strings.ContainsAny(header.Key, " :\r\n") ||
+			strings.ContainsAny(header.Value, "\r\n") {
+
+			errData = &errortypes.ErrorData{
+				Error:   "response_header_invalid",
+				Message: "Invalid service response header",
+			}
+			return
+		}
+	}
+
+	stripHeaders := []string{}
+	for _, key := range s.StripHeaders {
+		key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+		if key != "" {
+			stripHeaders = append(stripHeaders, key)
+		}
+	}
+	s.StripHeaders = stripHeaders
+
+	for i, method := range s.CorsMethods {
+		s.CorsMethods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+
+	for i, key := range s.CorsHeaders {
+		s.CorsHeaders[i] = http.CanonicalHeaderKey(strings.TrimSpace(key))
+	}
+
+	if s.CorsMaxAge < 0 {
+		s.CorsMaxAge = 0
+	}
+
+	if s.CorsCredentials {
+		for _, origin := range s.CorsOrigins {
+			if strings.ContainsAny(origin, "*?") {
+				errData = &errortypes.ErrorData{
+					Error: "cors_origin_invalid",
+					Message: "CORS origins cannot contain wildcards " +
+						"when credentials are allowed",
+				}
+				return
+			}
+		}
+	}
+
+	now := time.Now()
+	windows := []*MaintenanceWindow{}
+	for _, window := range s.MaintenanceWindows {
+		if window.Start.IsZero() || !window.End.After(window.Start) {
+			errData = &errortypes.ErrorData{
+				Error:   "maintenance_window_invalid",
+				Message: "Maintenance window must end after it starts",
+			}
+			return
+		}
+
+		if window.End.Before(now) {
+			continue
+		}
+
+		windows = append(windows, window)
+	}
+	s.MaintenanceWindows = windows
+
+	s.MaintenanceRole = strings.TrimSpace(s.MaintenanceRole)
+
 	s.Format()
 
 	return
@@ -190,6 +424,12 @@ func (s *Service) Validate(db *database.Database) (
 func (s *Service) Format() {
 	sort.Strings(s.Roles)
 	sort.Strings(s.WhitelistNetworks)
+	sort.Strings(s.CorsOrigins)
+
+	sort.Slice(s.MaintenanceWindows, func(i, j int) bool {
+		return s.MaintenanceWindows[i].Start.Before(
+			s.MaintenanceWindows[j].Start)
+	})
 }
 
 func (s *Service) Commit(db *database.Database) (err error) {
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+type GroupStats struct {
+	Service   primitive.ObjectID `bson:"service" json:"service"`
+	Group     string             `bson:"group" json:"group"`
+	Requests  int64              `bson:"requests" json:"requests"`
+	Errors    int64              `bson:"errors" json:"errors"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+func AddGroupStats(db *database.Database, serviceId primitive.ObjectID,
+	group string, requests, errors int64) (err error) {
+
+	coll := db.ServiceStats()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"service": serviceId,
+			"group":   group,
+		},
+		&bson.M{
+			"$inc": &bson.M{
+				"requests": requests,
+				"errors":   errors,
+			},
+			"$set": &bson.M{
+				"timestamp": time.Now(),
+			},
+		},
+		opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetGroupStats(db *database.Database, serviceId primitive.ObjectID) (
+	stats []*GroupStats, err error) {
+
+	coll := db.ServiceStats()
+	stats = []*GroupStats{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"service": serviceId,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		stat := &GroupStats{}
+		err = cursor.Decode(stat)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		stats = append(stats, stat)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ClearGroupStats(db *database.Database, serviceId primitive.ObjectID) (
+	err error) {
+
+	coll := db.ServiceStats()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"service": serviceId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
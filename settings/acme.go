@@ -3,8 +3,11 @@ package settings
 var Acme *acme
 
 type acme struct {
-	Id  string `bson:"_id"`
-	Url string `bson:"url" default:"https://acme-v01.api.letsencrypt.org"`
+	Id         string `bson:"_id"`
+	Url        string `bson:"url" default:"https://acme-v01.api.letsencrypt.org"`
+	DnsExec    string `bson:"dns_exec"`
+	DnsTimeout int    `bson:"dns_timeout" default:"120"`
+	DnsDelay   int    `bson:"dns_delay" default:"10"`
 }
 
 func newAcme() interface{} {
@@ -44,20 +44,23 @@ type SecondaryProvider struct {
 }
 
 type auth struct {
-	Id                 string               `bson:"_id"`
-	Server             string               `bson:"server" default:"https://auth.pritunl.com"`
-	Sync               int                  `bson:"sync" json:"sync" default:"1800"`
-	Providers          []*Provider          `bson:"providers"`
-	SecondaryProviders []*SecondaryProvider `bson:"secondary_providers"`
-	Window             int                  `bson:"window" json:"window" default:"60"`
-	SecondaryExpire    int                  `bson:"secondary_expire" json:"secondary_expire" default:"60"`
-	AdminExpire        int                  `bson:"admin_expire" json:"admin_expire" default:"1440"`
-	AdminMaxDuration   int                  `bson:"admin_max_duration" json:"admin_max_duration" default:"4320"`
-	ProxyExpire        int                  `bson:"proxy_expire" json:"proxy_expire" default:"1440"`
-	ProxyMaxDuration   int                  `bson:"proxy_max_duration" json:"proxy_max_duration" default:"4320"`
-	UserExpire         int                  `bson:"user_expire" json:"user_expire" default:"1440"`
-	UserMaxDuration    int                  `bson:"user_max_duration" json:"user_max_duration" default:"4320"`
-	DisaleGeo          bool                 `bson:"disable_geo" json:"disable_geo"`
+	Id                    string               `bson:"_id"`
+	Server                string               `bson:"server" default:"https://auth.pritunl.com"`
+	Sync                  int                  `bson:"sync" json:"sync" default:"1800"`
+	Providers             []*Provider          `bson:"providers"`
+	SecondaryProviders    []*SecondaryProvider `bson:"secondary_providers"`
+	Window                int                  `bson:"window" json:"window" default:"60"`
+	SecondaryExpire       int                  `bson:"secondary_expire" json:"secondary_expire" default:"60"`
+	AdminExpire           int                  `bson:"admin_expire" json:"admin_expire" default:"1440"`
+	AdminMaxDuration      int                  `bson:"admin_max_duration" json:"admin_max_duration" default:"4320"`
+	ProxyExpire           int                  `bson:"proxy_expire" json:"proxy_expire" default:"1440"`
+	ProxyMaxDuration      int                  `bson:"proxy_max_duration" json:"proxy_max_duration" default:"4320"`
+	UserExpire            int                  `bson:"user_expire" json:"user_expire" default:"1440"`
+	UserMaxDuration       int                  `bson:"user_max_duration" json:"user_max_duration" default:"4320"`
+	DisaleGeo             bool                 `bson:"disable_geo" json:"disable_geo"`
+	AdminCertificate      bool                 `bson:"admin_certificate" json:"admin_certificate"`
+	AdminCertificateCas   string               `bson:"admin_certificate_cas" json:"admin_certificate_cas"`
+	AdminCertificateMatch string               `bson:"admin_certificate_match" json:"admin_certificate_match" default:"email"`
 }
 
 func (a *auth) GetProvider(id primitive.ObjectID) *Provider {
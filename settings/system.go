@@ -21,11 +21,14 @@ type system struct {
 	UserCookieAuthKey              []byte `bson:"user_cookie_auth_key"`
 	UserCookieCryptoKey            []byte `bson:"user_cookie_crypto_key"`
 	AcmeKeyAlgorithm               string `bson:"acme_key_algorithm" default:"rsa"`
+	CertificateExpiryWarn          []int  `bson:"certificate_expiry_warn" default:"30,14,7,3,1"`
+	CertificateExpiryDays          int    `bson:"certificate_expiry_days" default:"30"`
+	CertificateExpiryWebhook       string `bson:"certificate_expiry_webhook"`
 	SshPubKeyLen                   int    `bson:"ssh_pub_key_len" default:"5000"`
 	SshHostTokenLen                int    `bson:"ssh_host_token_len" default:"10"`
 	HsmResponseTimeout             int    `bson:"hsm_response_timeout" default:"10"`
 	DisableBastionHostCertificates bool   `bson:"disable_bastion_host_certificates"`
-	BastionDockerImage             string `bson:"bastion_docker_image" default:"docker.io/pritunl/pritunl-bastion"`
+	RecordingRetention             int    `bson:"recording_retention" default:"90"`
 }
 
 func newSystem() interface{} {
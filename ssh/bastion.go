@@ -0,0 +1,74 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+// Issue a certificate for a recorded bastion session to connect to the
+// host, the certificate is stored with the user certificates to allow
+// revocation
+func NewBastionSessionCertificate(db *database.Database,
+	authr *authority.Authority, usr *user.User, pubKey string) (
+	cert *Certificate, err error) {
+
+	pubKey = strings.TrimSpace(pubKey)
+
+	cert = &Certificate{
+		Id:                     primitive.NewObjectID(),
+		UserId:                 usr.Id,
+		AuthorityIds:           []primitive.ObjectID{},
+		Timestamp:              time.Now(),
+		PubKey:                 pubKey,
+		Hosts:                  []*Host{},
+		CertificateAuthorities: []string{},
+		Certificates:           []string{},
+		CertificatesInfo:       []*Info{},
+		Bastion:                true,
+	}
+
+	// Source address is checked when connecting to the bastion, the
+	// session certificate is used from the bastion address
+	crt, certStr, err := authr.CreateCertificate(db, usr, pubKey, "")
+	if err != nil {
+		return
+	}
+
+	if crt == nil {
+		err = &errortypes.AuthenticationError{
+			errors.New("ssh: Authority certificate unavailable"),
+		}
+		return
+	}
+
+	info := &Info{
+		Expires:    time.Unix(int64(crt.ValidBefore), 0),
+		Serial:     fmt.Sprintf("%d", crt.Serial),
+		Principals: crt.ValidPrincipals,
+		Extensions: []string{},
+	}
+
+	for permission := range crt.Permissions.Extensions {
+		info.Extensions = append(info.Extensions, permission)
+	}
+
+	err = cert.addCertificate(authr, info, certStr)
+	if err != nil {
+		return
+	}
+
+	err = cert.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
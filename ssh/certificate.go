@@ -11,7 +11,6 @@ import (
 	"github.com/hydeant/pritunl-zero/agent"
 	"github.com/hydeant/pritunl-zero/authority"
 	"github.com/hydeant/pritunl-zero/database"
-	"github.com/hydeant/pritunl-zero/settings"
 	"github.com/hydeant/pritunl-zero/user"
 	"github.com/hydeant/pritunl-zero/utils"
 )
@@ -42,6 +41,38 @@ type Certificate struct {
 	Certificates           []string             `bson:"certificates" json:"-"`
 	CertificatesInfo       []*Info              `bson:"certificates_info" json:"certificates_info"`
 	Agent                  *agent.Agent         `bson:"agent" json:"agent"`
+	Service                bool                 `bson:"service" json:"service"`
+	Bastion                bool                 `bson:"bastion" json:"bastion"`
+}
+
+// Add a signed certificate from the authority
+func (c *Certificate) addCertificate(authr *authority.Authority,
+	info *Info, certStr string) (err error) {
+
+	c.CertificateAuthorities = append(
+		c.CertificateAuthorities,
+		authr.GetCertAuthorities()...,
+	)
+
+	c.CertificateAuthorities = append(
+		c.CertificateAuthorities,
+		authr.GetBastionCertAuthorities()...,
+	)
+
+	hst, err := newHost(authr)
+	if err != nil {
+		return
+	}
+
+	if hst != nil {
+		c.Hosts = append(c.Hosts, hst)
+	}
+
+	c.AuthorityIds = append(c.AuthorityIds, authr.Id)
+	c.Certificates = append(c.Certificates, certStr)
+	c.CertificatesInfo = append(c.CertificatesInfo, info)
+
+	return
 }
 
 func (c *Certificate) Commit(db *database.Database) (err error) {
@@ -151,8 +182,8 @@ func GetCertificates(db *database.Database, userId primitive.ObjectID,
 }
 
 func NewCertificate(db *database.Database, authrs []*authority.Authority,
-	usr *user.User, agnt *agent.Agent, pubKey string) (cert *Certificate,
-	err error) {
+	usr *user.User, agnt *agent.Agent, pubKey, remoteAddr string) (
+	cert *Certificate, err error) {
 
 	cert = &Certificate{
 		Id:                     primitive.NewObjectID(),
@@ -172,7 +203,8 @@ func NewCertificate(db *database.Database, authrs []*authority.Authority,
 			continue
 		}
 
-		crt, certStr, e := authr.CreateCertificate(db, usr, pubKey)
+		crt, certStr, e := authr.CreateCertificate(
+			db, usr, pubKey, remoteAddr)
 		if e != nil {
 			err = e
 			return
@@ -193,45 +225,10 @@ func NewCertificate(db *database.Database, authrs []*authority.Authority,
 			info.Extensions = append(info.Extensions, permission)
 		}
 
-		certAuthr := authr.GetCertAuthority()
-		if certAuthr != "" {
-			cert.CertificateAuthorities = append(
-				cert.CertificateAuthorities,
-				certAuthr,
-			)
-		}
-
-		certAuthr = authr.GetBastionCertAuthority()
-		if certAuthr != "" {
-			cert.CertificateAuthorities = append(
-				cert.CertificateAuthorities,
-				certAuthr,
-			)
-		}
-
-		matches, e := authr.GetMatches()
-		if e != nil {
-			err = e
+		err = cert.addCertificate(authr, info, certStr)
+		if err != nil {
 			return
 		}
-
-		if (authr.HostDomain != "" || len(matches) > 0) &&
-			(authr.StrictHostChecking || authr.JumpProxy() != "") {
-
-			hst := &Host{
-				Domain:             authr.GetHostDomain(),
-				ProxyHost:          authr.JumpProxy(),
-				Matches:            matches,
-				StrictHostChecking: authr.StrictHostChecking,
-				StrictBastionChecking: authr.ProxyHosting &&
-					!settings.System.DisableBastionHostCertificates,
-			}
-			cert.Hosts = append(cert.Hosts, hst)
-		}
-
-		cert.AuthorityIds = append(cert.AuthorityIds, authr.Id)
-		cert.Certificates = append(cert.Certificates, certStr)
-		cert.CertificatesInfo = append(cert.CertificatesInfo, info)
 	}
 
 	return
@@ -0,0 +1,131 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/settings"
+)
+
+// Host configuration for the authority, nil when the authority does not
+// require client configuration
+func newHost(authr *authority.Authority) (hst *Host, err error) {
+	matches, err := authr.GetMatches()
+	if err != nil {
+		return
+	}
+
+	if (authr.HostDomain != "" || len(matches) > 0) &&
+		(authr.StrictHostChecking || authr.JumpProxy() != "") {
+
+		hst = &Host{
+			Domain:             authr.GetHostDomain(),
+			ProxyHost:          authr.JumpProxy(),
+			Matches:            matches,
+			StrictHostChecking: authr.StrictHostChecking,
+			StrictBastionChecking: authr.ProxyHosting &&
+				!settings.System.DisableBastionHostCertificates,
+		}
+	}
+
+	return
+}
+
+// Render an OpenSSH client configuration with the host and bastion rules
+// for the authorities
+func RenderConfig(authrs []*authority.Authority) (
+	conf string, err error) {
+
+	lines := []string{
+		"# Pritunl Zero SSH configuration",
+	}
+
+	for _, authr := range authrs {
+		if !authr.ConfigSafe() {
+			logrus.WithFields(logrus.Fields{
+				"authority_id": authr.Id.Hex(),
+			}).Warn("ssh: Skipping authority with invalid ssh config values")
+			continue
+		}
+
+		hst, e := newHost(authr)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if hst == nil {
+			continue
+		}
+
+		lines = append(lines,
+			"",
+			fmt.Sprintf("# Authority %s", authr.Name),
+		)
+
+		bastionDomain := authr.GetBastionDomain()
+		if bastionDomain != "" {
+			lines = append(lines, fmt.Sprintf("Host %s", bastionDomain))
+			if hst.StrictBastionChecking {
+				lines = append(lines, "    StrictHostKeyChecking yes")
+			}
+			lines = append(lines, "    ProxyJump none", "")
+		}
+
+		if len(hst.Matches) == 0 {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"Host %s", strings.Join(hst.Matches, " ")))
+		if hst.ProxyHost != "" {
+			lines = append(lines, fmt.Sprintf(
+				"    ProxyJump %s", hst.ProxyHost))
+		}
+		if hst.StrictHostChecking {
+			lines = append(lines, "    StrictHostKeyChecking yes")
+		}
+	}
+
+	conf = strings.Join(lines, "\n") + "\n"
+
+	return
+}
+
+// Render an OpenSSH known hosts file with the host and bastion certificate
+// authorities
+func RenderKnownHosts(authrs []*authority.Authority) (knownHosts string) {
+	lines := []string{
+		"# Pritunl Zero SSH known hosts",
+	}
+	linesSet := set.NewSet()
+
+	for _, authr := range authrs {
+		if !authr.ConfigSafe() {
+			logrus.WithFields(logrus.Fields{
+				"authority_id": authr.Id.Hex(),
+			}).Warn("ssh: Skipping authority with invalid ssh config values")
+			continue
+		}
+
+		certAuthrs := authr.GetCertAuthorities()
+		certAuthrs = append(certAuthrs, authr.GetMatchCertAuthorities()...)
+		certAuthrs = append(certAuthrs, authr.GetBastionCertAuthorities()...)
+
+		for _, certAuthr := range certAuthrs {
+			if linesSet.Contains(certAuthr) {
+				continue
+			}
+			linesSet.Add(certAuthr)
+
+			lines = append(lines, certAuthr)
+		}
+	}
+
+	knownHosts = strings.Join(lines, "\n") + "\n"
+
+	return
+}
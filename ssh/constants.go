@@ -4,4 +4,5 @@ const (
 	Approved    = "approved"
 	Unavailable = "unavailable"
 	Denied      = "denied"
+	Pending     = "pending"
 )
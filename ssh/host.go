@@ -6,18 +6,57 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/hydeant/pritunl-zero/agent"
 	"github.com/hydeant/pritunl-zero/authority"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/hosttoken"
+	"github.com/hydeant/pritunl-zero/node"
 	"github.com/hydeant/pritunl-zero/settings"
 )
 
+// Get the permitted bootstrap tokens by authority for authorities not
+// already matched by an authority host token
+func getBootstrapTokens(db *database.Database,
+	authrs []*authority.Authority, tokens []string, hostname,
+	remoteAddr string) (
+	bootstrapTokens map[primitive.ObjectID]*hosttoken.Token, err error) {
+
+	bootstrapTokens = map[primitive.ObjectID]*hosttoken.Token{}
+
+	tokns, err := hosttoken.GetTokens(db, tokens)
+	if err != nil {
+		return
+	}
+
+	authrIds := set.NewSet()
+	for _, authr := range authrs {
+		authrIds.Add(authr.Id)
+	}
+
+	for _, tokn := range tokns {
+		if authrIds.Contains(tokn.AuthorityId) ||
+			bootstrapTokens[tokn.AuthorityId] != nil ||
+			!tokn.Permitted(hostname, remoteAddr) {
+
+			continue
+		}
+
+		bootstrapTokens[tokn.AuthorityId] = tokn
+	}
+
+	return
+}
+
+// Create the host certificates, single use bootstrap tokens are exchanged
+// for renewal tokens that must be used for later requests
 func NewHostCertificate(db *database.Database, hostname string, port int,
 	tokens []string, r *http.Request, pubKey string) (
-	cert *Certificate, errData *errortypes.ErrorData, err error) {
+	cert *Certificate, renewalTokens []string,
+	errData *errortypes.ErrorData, err error) {
 
 	pubKey = strings.TrimSpace(pubKey)
 
@@ -55,6 +94,32 @@ func NewHostCertificate(db *database.Database, hostname string, port int,
 		return
 	}
 
+	remoteAddr := node.Self.GetRemoteAddr(r)
+	bootstrapTokens, err := getBootstrapTokens(
+		db, authrs, tokens, hostname, remoteAddr)
+	if err != nil {
+		return
+	}
+
+	if len(bootstrapTokens) > 0 {
+		authrIds := []primitive.ObjectID{}
+		for authrId := range bootstrapTokens {
+			authrIds = append(authrIds, authrId)
+		}
+
+		bootstrapAuthrs, e := authority.GetMulti(db, authrIds)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, authr := range bootstrapAuthrs {
+			if authr.HostCertificates {
+				authrs = append(authrs, authr)
+			}
+		}
+	}
+
 	if len(authrs) == 0 {
 		errData = &errortypes.ErrorData{
 			Error:   "invalid_tokens",
@@ -68,6 +133,29 @@ func NewHostCertificate(db *database.Database, hostname string, port int,
 			continue
 		}
 
+		tokn := bootstrapTokens[authr.Id]
+		if tokn != nil {
+			redeemed, e := tokn.Redeem(db, hostname, remoteAddr)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if !redeemed {
+				continue
+			}
+
+			if tokn.SingleUse {
+				renewal, e := tokn.NewRenewal(db, hostname)
+				if e != nil {
+					err = e
+					return
+				}
+
+				renewalTokens = append(renewalTokens, renewal.Token)
+			}
+		}
+
 		crt, certStr, e := authr.CreateHostCertificate(db, hostname, pubKey)
 		if e != nil {
 			err = e
@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/agent"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/policy"
+	"github.com/hydeant/pritunl-zero/settings"
+	"github.com/hydeant/pritunl-zero/user"
+)
+
+// Service certificates are issued without interaction, authorities and
+// policies that require interactive authentication or approval are denied
+func servicePermitted(authr *authority.Authority,
+	policies []*policy.Policy) (errData *errortypes.ErrorData) {
+
+	if !authr.ServiceCertificates {
+		errData = &errortypes.ErrorData{
+			Error:   "service_certificates_disabled",
+			Message: "Authority does not permit service certificates",
+		}
+		return
+	}
+
+	if authr.RequireApproval {
+		errData = &errortypes.ErrorData{
+			Error:   "service_certificate_approval",
+			Message: "Authority requires approval",
+		}
+		return
+	}
+
+	for _, polcy := range policies {
+		if polcy.Disabled {
+			continue
+		}
+
+		if polcy.AuthorityRequireApproval {
+			errData = &errortypes.ErrorData{
+				Error:   "service_certificate_approval",
+				Message: "Authority policy requires approval",
+			}
+			return
+		}
+
+		if polcy.AuthorityDeviceSecondary ||
+			!polcy.AuthoritySecondary.IsZero() ||
+			polcy.AuthorityRequireSmartCard {
+
+			errData = &errortypes.ErrorData{
+				Error: "service_certificate_policy",
+				Message: "Authority policy requires interactive " +
+					"authentication",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// Issue a certificate to a service account without a challenge, policies
+// that require interactive authentication will deny the certificate
+func NewServiceCertificate(db *database.Database, authrId primitive.ObjectID,
+	usr *user.User, r *http.Request, pubKey, remoteAddr string,
+	principals []string, expire int) (cert *Certificate,
+	errData *errortypes.ErrorData, err error) {
+
+	pubKey = strings.TrimSpace(pubKey)
+
+	if len(pubKey) > settings.System.SshPubKeyLen {
+		err = errortypes.ParseError{
+			errors.New("ssh: Public key too long"),
+		}
+		return
+	}
+
+	if usr.Type != user.Api {
+		errData = &errortypes.ErrorData{
+			Error:   "user_type_invalid",
+			Message: "Service certificates require an API user",
+		}
+		return
+	}
+
+	authr, err := authority.Get(db, authrId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "authority_invalid",
+				Message: "Authority is invalid",
+			}
+		}
+		return
+	}
+
+	if !authr.UserHasAccess(usr) {
+		errData = &errortypes.ErrorData{
+			Error:   "authority_invalid",
+			Message: "Authority is invalid",
+		}
+		return
+	}
+
+	policies, err := policy.GetAuthoritiesRoles(
+		db, []primitive.ObjectID{authr.Id}, usr.Roles)
+	if err != nil {
+		return
+	}
+
+	errData = servicePermitted(authr, policies)
+	if errData != nil {
+		return
+	}
+
+	for _, polcy := range policies {
+		errData, err = polcy.ValidateUser(db, usr, r)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	for _, principal := range principals {
+		if principal == "" || len(principal) > 256 {
+			errData = &errortypes.ErrorData{
+				Error:   "principal_invalid",
+				Message: "Principal is invalid",
+			}
+			return
+		}
+	}
+
+	agnt, err := agent.Parse(db, r)
+	if err != nil {
+		return
+	}
+
+	cert = &Certificate{
+		Id:                     primitive.NewObjectID(),
+		UserId:                 usr.Id,
+		AuthorityIds:           []primitive.ObjectID{},
+		Timestamp:              time.Now(),
+		PubKey:                 pubKey,
+		Hosts:                  []*Host{},
+		CertificateAuthorities: []string{},
+		Certificates:           []string{},
+		CertificatesInfo:       []*Info{},
+		Agent:                  agnt,
+		Service:                true,
+	}
+
+	crt, certStr, err := authr.CreateServiceCertificate(
+		db, usr, pubKey, remoteAddr, principals, expire)
+	if err != nil {
+		if _, ok := err.(*errortypes.AuthenticationError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "principals_invalid",
+				Message: "Principals are not permitted by authority",
+			}
+		}
+		return
+	}
+
+	if crt == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "certificate_unavailable",
+			Message: "Authority certificate unavailable",
+		}
+		return
+	}
+
+	info := &Info{
+		Expires:    time.Unix(int64(crt.ValidBefore), 0),
+		Serial:     fmt.Sprintf("%d", crt.Serial),
+		Principals: crt.ValidPrincipals,
+		Extensions: []string{},
+	}
+
+	for permission := range crt.Permissions.Extensions {
+		info.Extensions = append(info.Extensions, permission)
+	}
+
+	err = cert.addCertificate(authr, info, certStr)
+	if err != nil {
+		return
+	}
+
+	err = cert.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
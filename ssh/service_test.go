@@ -0,0 +1,94 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/policy"
+)
+
+func TestServicePermitted(t *testing.T) {
+	tests := []struct {
+		name     string
+		authr    *authority.Authority
+		policies []*policy.Policy
+		errCode  string
+	}{
+		{
+			"permitted",
+			&authority.Authority{
+				ServiceCertificates: true,
+			},
+			[]*policy.Policy{},
+			"",
+		},
+		{
+			"disabled",
+			&authority.Authority{},
+			[]*policy.Policy{},
+			"service_certificates_disabled",
+		},
+		{
+			"authority_approval",
+			&authority.Authority{
+				ServiceCertificates: true,
+				RequireApproval:     true,
+				ApproverRoles:       []string{"approver"},
+			},
+			[]*policy.Policy{},
+			"service_certificate_approval",
+		},
+		{
+			"policy_approval",
+			&authority.Authority{
+				ServiceCertificates: true,
+			},
+			[]*policy.Policy{
+				&policy.Policy{
+					AuthorityRequireApproval: true,
+				},
+			},
+			"service_certificate_approval",
+		},
+		{
+			"policy_approval_disabled",
+			&authority.Authority{
+				ServiceCertificates: true,
+			},
+			[]*policy.Policy{
+				&policy.Policy{
+					Disabled:                 true,
+					AuthorityRequireApproval: true,
+				},
+			},
+			"",
+		},
+		{
+			"policy_secondary",
+			&authority.Authority{
+				ServiceCertificates: true,
+			},
+			[]*policy.Policy{
+				&policy.Policy{
+					AuthoritySecondary: primitive.NewObjectID(),
+				},
+			},
+			"service_certificate_policy",
+		},
+	}
+
+	for _, test := range tests {
+		errData := servicePermitted(test.authr, test.policies)
+
+		errCode := ""
+		if errData != nil {
+			errCode = errData.Error
+		}
+
+		if errCode != test.errCode {
+			t.Errorf("%s: Expected error '%s' got '%s'",
+				test.name, test.errCode, errCode)
+		}
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"github.com/hydeant/pritunl-zero/bastion"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/node"
-	"github.com/hydeant/pritunl-zero/settings"
-	"github.com/hydeant/pritunl-zero/utils"
 )
 
 func bastionEnabled() bool {
@@ -20,17 +18,33 @@ func bastionEnabled() bool {
 		strings.Contains(node.Self.Type, node.Bastion)
 }
 
+// Remove bastion containers left from previous versions which would conflict
+// with the bastion server ports
 func bastionInit() (err error) {
-	logrus.WithFields(logrus.Fields{
-		"docker_image": settings.System.BastionDockerImage,
-	}).Info("sync: Pulling bastion server docker image")
+	if !bastion.DockerAvailable() {
+		return
+	}
 
-	_, err = utils.ExecCombinedOutputLogged(nil, "docker",
-		"pull", settings.System.BastionDockerImage)
-	if err != nil {
+	containers, e := bastion.DockerGetRunning()
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": e,
+		}).Warning("sync: Failed to check for legacy bastion containers")
 		return
 	}
 
+	for containerId, authrId := range containers {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": authrId.Hex(),
+			"container_id": containerId,
+		}).Info("sync: Removing legacy bastion server container")
+
+		err = bastion.DockerRemove(containerId)
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -49,15 +63,12 @@ func bastionSync() (err error) {
 	}
 
 	curAuthrs := set.NewSet()
-	curContainers := []string{}
 
 	for _, authr := range authrs {
 		curAuthrs.Add(authr.Id)
 	}
 
 	for _, bast := range bastion.GetAll() {
-		curContainers = append(curContainers, bast.Container)
-
 		if !curAuthrs.Contains(bast.Authority) {
 			e := bast.Stop()
 			if e != nil {
@@ -68,35 +79,6 @@ func bastionSync() (err error) {
 		}
 	}
 
-	containers, err := bastion.DockerGetRunning()
-	if err != nil {
-		return
-	}
-
-	for containerId, authrId := range containers {
-		match := false
-		for _, containerId2 := range curContainers {
-			if bastion.DockerMatchContainer(containerId, containerId2) {
-				match = true
-				break
-			}
-		}
-
-		if !match {
-			logrus.WithFields(logrus.Fields{
-				"authority_id": authrId.Hex(),
-				"container_id": containerId,
-			}).Info("sync: Removing unknown bastion server")
-
-			e := bastion.DockerRemove(containerId)
-			if e != nil {
-				logrus.WithFields(logrus.Fields{
-					"error": e,
-				}).Error("sync: Failed to remove bastion server")
-			}
-		}
-	}
-
 	for _, authr := range authrs {
 		bast := bastion.Get(authr.Id)
 		if bast == nil || !bast.State() {
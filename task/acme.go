@@ -14,6 +14,22 @@ var acmeRenew = &Task{
 	Handler: acmeRenewHandler,
 }
 
+var acmeAuto = &Task{
+	Name:    "acme_auto",
+	Hours:   AllHours,
+	Mins:    []int{5, 20, 35, 50},
+	Handler: acmeAutoHandler,
+}
+
+func acmeAutoHandler(db *database.Database) (err error) {
+	err = acme.SyncAuto(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func acmeRenewHandler(db *database.Database) (err error) {
 	certs, err := certificate.GetAll(db)
 	if err != nil {
@@ -49,4 +65,5 @@ func acmeRenewHandler(db *database.Database) (err error) {
 
 func init() {
 	register(acmeRenew)
+	register(acmeAuto)
 }
@@ -0,0 +1,26 @@
+package task
+
+import (
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+var authorityRotate = &Task{
+	Name:    "authority_rotate",
+	Hours:   AllHours,
+	Mins:    []int{10, 25, 40, 55},
+	Handler: authorityRotateHandler,
+}
+
+func authorityRotateHandler(db *database.Database) (err error) {
+	err = authority.RotateCheck(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	register(authorityRotate)
+}
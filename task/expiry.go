@@ -0,0 +1,26 @@
+package task
+
+import (
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/expiry"
+)
+
+var certificateExpiry = &Task{
+	Name:    "certificate_expiry",
+	Hours:   []int{8},
+	Mins:    []int{15},
+	Handler: certificateExpiryHandler,
+}
+
+func certificateExpiryHandler(db *database.Database) (err error) {
+	err = expiry.Check(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	register(certificateExpiry)
+}
@@ -0,0 +1,26 @@
+package task
+
+import (
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/database"
+)
+
+var hsmHeartbeat = &Task{
+	Name:    "hsm_heartbeat",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: hsmHeartbeatHandler,
+}
+
+func hsmHeartbeatHandler(db *database.Database) (err error) {
+	err = authority.HsmCheck(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	register(hsmHeartbeat)
+}
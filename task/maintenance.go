@@ -0,0 +1,44 @@
+package task
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/service"
+)
+
+var maintenance = &Task{
+	Name:    "service_maintenance",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: maintenanceHandler,
+}
+
+func maintenanceHandler(db *database.Database) (err error) {
+	srvcs, err := service.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	for _, srvc := range srvcs {
+		err = srvc.SyncMaintenance(db, now)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"service_id":   srvc.Id.Hex(),
+				"service_name": srvc.Name,
+				"error":        err,
+			}).Error("task: Failed to sync service maintenance")
+			err = nil
+			continue
+		}
+	}
+
+	return
+}
+
+func init() {
+	register(maintenance)
+}
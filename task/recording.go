@@ -0,0 +1,26 @@
+package task
+
+import (
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/recording"
+)
+
+var recordingClean = &Task{
+	Name:    "recording_clean",
+	Hours:   AllHours,
+	Mins:    []int{30},
+	Handler: recordingCleanHandler,
+}
+
+func recordingCleanHandler(db *database.Database) (err error) {
+	err = recording.RemoveExpired(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	register(recordingClean)
+}
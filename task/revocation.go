@@ -0,0 +1,26 @@
+package task
+
+import (
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/revocation"
+)
+
+var revocationClean = &Task{
+	Name:    "revocation_clean",
+	Hours:   AllHours,
+	Mins:    []int{45},
+	Handler: revocationCleanHandler,
+}
+
+func revocationCleanHandler(db *database.Database) (err error) {
+	err = revocation.RemoveExpired(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	register(revocationClean)
+}
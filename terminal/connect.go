@@ -0,0 +1,336 @@
+package terminal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/settings"
+	"golang.org/x/crypto/ssh"
+)
+
+type Client struct {
+	client  *ssh.Client
+	bastion *ssh.Client
+	session *ssh.Session
+}
+
+// Start a shell with a pty, the host merges stderr into the pty and any
+// remaining stderr output must be read separately from stdout
+func (c *Client) Start(columns, rows int) (stdin io.WriteCloser,
+	stdout, stderr io.Reader, err error) {
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to open session"),
+		}
+		return
+	}
+	c.session = session
+
+	err = session.RequestPty("xterm-256color", rows, columns,
+		ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		})
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to request pty"),
+		}
+		return
+	}
+
+	stdin, err = session.StdinPipe()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to open stdin"),
+		}
+		return
+	}
+
+	stdout, err = session.StdoutPipe()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to open stdout"),
+		}
+		return
+	}
+
+	stderr, err = session.StderrPipe()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to open stderr"),
+		}
+		return
+	}
+
+	err = session.Shell()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to start shell"),
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Client) Resize(columns, rows int) (err error) {
+	if c.session == nil {
+		return
+	}
+
+	err = c.session.WindowChange(rows, columns)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to resize terminal"),
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Client) Wait() {
+	if c.session != nil {
+		c.session.Wait()
+	}
+}
+
+func (c *Client) Close() {
+	if c.session != nil {
+		c.session.Close()
+	}
+	c.client.Close()
+	if c.bastion != nil {
+		c.bastion.Close()
+	}
+}
+
+func generateKey() (privKey, pubKey string, err error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "terminal: Failed to generate key"),
+		}
+		return
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to marshal key"),
+		}
+		return
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to parse key"),
+		}
+		return
+	}
+
+	privKey = strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyBytes,
+	})))
+	pubKey = strings.TrimSpace(string(
+		ssh.MarshalAuthorizedKey(signer.PublicKey())))
+
+	return
+}
+
+func hostKeyCallback(trusted []ssh.PublicKey, strict bool) (
+	callback ssh.HostKeyCallback) {
+
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			authBytes := auth.Marshal()
+
+			for _, key := range trusted {
+				if string(key.Marshal()) == string(authBytes) {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+
+	if !strict {
+		checker.HostKeyFallback = ssh.InsecureIgnoreHostKey()
+	}
+
+	callback = checker.CheckHostKey
+	return
+}
+
+// Parse jump proxy in the format [user@]host[:port]
+func parseJumpProxy(jumpProxy string) (jumpUser, jumpAddr string) {
+	jumpUser = "bastion"
+	jumpAddr = jumpProxy
+
+	n := strings.LastIndex(jumpProxy, "@")
+	if n != -1 {
+		jumpUser = jumpProxy[:n]
+		jumpAddr = jumpProxy[n+1:]
+	}
+
+	if _, _, err := net.SplitHostPort(jumpAddr); err != nil {
+		jumpAddr = net.JoinHostPort(jumpAddr, "22")
+	}
+
+	return
+}
+
+func connect(authr *authority.Authority, term *Terminal, certStr string) (
+	client *Client, err error) {
+
+	key, err := ssh.ParsePrivateKey([]byte(term.privateKey))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to parse key"),
+		}
+		return
+	}
+
+	certKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to parse certificate"),
+		}
+		return
+	}
+
+	cert, ok := certKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("terminal: Certificate invalid"),
+		}
+		return
+	}
+
+	signer, err := ssh.NewCertSigner(cert, key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to load certificate"),
+		}
+		return
+	}
+
+	trusted := []ssh.PublicKey{}
+	for _, pubKey := range authr.TrustedPublicKeys() {
+		trustedKey, _, _, _, e := ssh.ParseAuthorizedKey([]byte(pubKey))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "terminal: Failed to parse authority key"),
+			}
+			return
+		}
+
+		trusted = append(trusted, trustedKey)
+	}
+
+	newConfig := func(username string,
+		strict bool) *ssh.ClientConfig {
+
+		return &ssh.ClientConfig{
+			User: username,
+			Auth: []ssh.AuthMethod{
+				ssh.PublicKeys(signer),
+			},
+			HostKeyCallback: hostKeyCallback(trusted, strict),
+			Timeout:         10 * time.Second,
+		}
+	}
+
+	addr := net.JoinHostPort(term.Host, strconv.Itoa(term.Port))
+	jumpProxy := authr.JumpProxy()
+
+	if jumpProxy == "" {
+		sshClient, e := ssh.Dial("tcp", addr,
+			newConfig(term.HostUser, authr.StrictHostChecking))
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "terminal: Failed to connect to host"),
+			}
+			return
+		}
+
+		client = &Client{
+			client: sshClient,
+		}
+		return
+	}
+
+	jumpUser, jumpAddr := parseJumpProxy(jumpProxy)
+	strictBastion := authr.ProxyHosting &&
+		!settings.System.DisableBastionHostCertificates
+
+	// Recorded sessions are proxied by the bastion to the host
+	if authr.ProxyHosting && authr.ProxyRecording {
+		sshClient, e := ssh.Dial("tcp", jumpAddr, newConfig(
+			fmt.Sprintf("%s@%s", term.HostUser, addr), strictBastion))
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "terminal: Failed to connect to bastion"),
+			}
+			return
+		}
+
+		client = &Client{
+			client: sshClient,
+		}
+		return
+	}
+
+	bastion, err := ssh.Dial("tcp", jumpAddr,
+		newConfig(jumpUser, strictBastion))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to connect to bastion"),
+		}
+		return
+	}
+
+	conn, err := bastion.Dial("tcp", addr)
+	if err != nil {
+		bastion.Close()
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to forward to host"),
+		}
+		return
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr,
+		newConfig(term.HostUser, authr.StrictHostChecking))
+	if err != nil {
+		conn.Close()
+		bastion.Close()
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "terminal: Failed to connect to host"),
+		}
+		return
+	}
+
+	client = &Client{
+		client:  ssh.NewClient(sshConn, chans, reqs),
+		bastion: bastion,
+	}
+
+	return
+}
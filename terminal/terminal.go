@@ -0,0 +1,225 @@
+// Browser SSH terminal sessions.
+package terminal
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/challenge"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/ssh"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+// Terminal id is a hash of the token and the private key is encrypted
+// with the token, the private key is only decrypted when claimed
+type Terminal struct {
+	Id          string             `bson:"_id"`
+	Token       string             `bson:"-"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	AuthorityId primitive.ObjectID `bson:"authority_id"`
+	ChallengeId string             `bson:"challenge_id"`
+	Host        string             `bson:"host"`
+	Port        int                `bson:"port"`
+	HostUser    string             `bson:"host_user"`
+	PrivateKey  string             `bson:"private_key"`
+	Timestamp   time.Time          `bson:"timestamp"`
+	privateKey  string
+}
+
+func (t *Terminal) Insert(db *database.Database) (err error) {
+	coll := db.SshTerminals()
+
+	_, err = coll.InsertOne(db, t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Check if the terminal challenge is waiting for a second approver
+func (t *Terminal) Pending(db *database.Database) (
+	pending bool, err error) {
+
+	chal, err := challenge.GetChallenge(db, t.ChallengeId)
+	if err != nil {
+		return
+	}
+
+	pending = chal.State == ssh.Pending
+	return
+}
+
+// Get the approved certificate for the terminal authority
+func (t *Terminal) certificate(db *database.Database) (
+	certStr string, err error) {
+
+	chal, err := challenge.GetChallenge(db, t.ChallengeId)
+	if err != nil {
+		return
+	}
+
+	if chal.State != ssh.Approved || chal.CertificateId.IsZero() {
+		err = &errortypes.AuthenticationError{
+			errors.New("terminal: Terminal challenge not approved"),
+		}
+		return
+	}
+
+	cert, err := ssh.GetCertificate(db, chal.CertificateId)
+	if err != nil {
+		return
+	}
+
+	for i, authrId := range cert.AuthorityIds {
+		if authrId == t.AuthorityId && i < len(cert.Certificates) {
+			certStr = cert.Certificates[i]
+			return
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("terminal: Authority certificate unavailable"),
+	}
+	return
+}
+
+// Create a terminal with a challenge for a new ephemeral key, the
+// certificate is only issued from the terminal authority
+func New(db *database.Database, userId, authrId primitive.ObjectID,
+	host string, port int, hostUser string) (
+	term *Terminal, chal *challenge.Challenge, err error) {
+
+	privKey, pubKey, err := generateKey()
+	if err != nil {
+		return
+	}
+
+	// Terminal sessions connect from the user node, the browser address
+	// would conflict with a source address restriction
+	chal, err = challenge.NewAuthorityChallenge(db, pubKey, "", authrId)
+	if err != nil {
+		return
+	}
+
+	token, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+
+	encKey, err := encryptKey(token, privKey)
+	if err != nil {
+		return
+	}
+
+	term = &Terminal{
+		Id:          tokenHash(token),
+		Token:       token,
+		UserId:      userId,
+		AuthorityId: authrId,
+		ChallengeId: chal.Id,
+		Host:        host,
+		Port:        port,
+		HostUser:    hostUser,
+		PrivateKey:  encKey,
+		Timestamp:   time.Now(),
+	}
+
+	err = term.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Get(db *database.Database, token string) (
+	term *Terminal, err error) {
+
+	coll := db.SshTerminals()
+	term = &Terminal{}
+
+	err = coll.FindOneId(tokenHash(token), term)
+	if err != nil {
+		return
+	}
+
+	term.Token = token
+
+	return
+}
+
+// Restart the expiry of terminals for the challenge, used while the
+// challenge waits for a second approver
+func Refresh(db *database.Database, chalId string) (err error) {
+	coll := db.SshTerminals()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"challenge_id": chalId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"timestamp": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Remove the terminal and return it with the decrypted private key,
+// terminals can only be connected once
+func Claim(db *database.Database, token string,
+	userId primitive.ObjectID) (term *Terminal, err error) {
+
+	coll := db.SshTerminals()
+	term = &Terminal{}
+
+	err = coll.FindOneAndDelete(db, &bson.M{
+		"_id":     tokenHash(token),
+		"user_id": userId,
+	}).Decode(term)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	term.Token = token
+
+	term.privateKey, err = decryptKey(token, term.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Connect to the terminal host with the approved certificate
+func (t *Terminal) Connect(db *database.Database) (
+	client *Client, err error) {
+
+	authr, err := authority.Get(db, t.AuthorityId)
+	if err != nil {
+		return
+	}
+
+	certStr, err := t.certificate(db)
+	if err != nil {
+		return
+	}
+
+	client, err = connect(authr, t, certStr)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package terminal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+// Terminal records are stored by a hash of the token given to the browser
+// and the private key is encrypted with a key derived from the token, the
+// key can't be recovered from the database alone
+func tokenHash(token string) string {
+	hash := hmac.New(sha256.New, []byte(token))
+	hash.Write([]byte("terminal-id"))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func tokenCipher(token string) (aead cipher.AEAD, err error) {
+	hash := hmac.New(sha256.New, []byte(token))
+	hash.Write([]byte("terminal-key"))
+
+	block, err := aes.NewCipher(hash.Sum(nil))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to load cipher"),
+		}
+		return
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to load cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+func encryptKey(token, privKey string) (encKey string, err error) {
+	aead, err := tokenCipher(token)
+	if err != nil {
+		return
+	}
+
+	nonce, err := utils.RandBytes(aead.NonceSize())
+	if err != nil {
+		return
+	}
+
+	data := aead.Seal(nonce, nonce, []byte(privKey), nil)
+	encKey = base64.StdEncoding.EncodeToString(data)
+
+	return
+}
+
+func decryptKey(token, encKey string) (privKey string, err error) {
+	aead, err := tokenCipher(token)
+	if err != nil {
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "terminal: Failed to decode key"),
+		}
+		return
+	}
+
+	if len(data) < aead.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("terminal: Encrypted key invalid"),
+		}
+		return
+	}
+
+	nonce := data[:aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[aead.NonceSize():], nil)
+	if err != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(err, "terminal: Failed to decrypt key"),
+		}
+		return
+	}
+
+	privKey = string(plain)
+
+	return
+}
@@ -0,0 +1,47 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptKey(t *testing.T) {
+	privKey, _, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "terminal-token"
+
+	encKey, err := encryptKey(token, privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(encKey, "PRIVATE KEY") {
+		t.Fatal("Private key stored unencrypted")
+	}
+
+	decKey, err := decryptKey(token, encKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decKey != privKey {
+		t.Error("Decrypted key does not match")
+	}
+
+	_, err = decryptKey("other-token", encKey)
+	if err == nil {
+		t.Error("Key decrypted with wrong token")
+	}
+
+	_, err = decryptKey(token, "aW52YWxpZA==")
+	if err == nil {
+		t.Error("Invalid key decrypted")
+	}
+
+	if tokenHash(token) == token || tokenHash(token) != tokenHash(token) {
+		t.Error("Token hash invalid")
+	}
+}
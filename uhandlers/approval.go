@@ -0,0 +1,206 @@
+package uhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hydeant/pritunl-zero/approval"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/authorizer"
+	"github.com/hydeant/pritunl-zero/challenge"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/terminal"
+	"github.com/hydeant/pritunl-zero/user"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+func sshApprovalsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	apprvls, err := approval.GetPending(db, usr)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, apprvls)
+}
+
+// Get the approval and answer the request
+func sshApprovalAnswer(c *gin.Context, db *database.Database,
+	usr *user.User, approve bool) (apprvl *approval.Approval,
+	errData *errortypes.ErrorData, err error) {
+
+	apprvlId, ok := utils.ParseObjectId(c.Param("approval_id"))
+	if !ok {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_invalid",
+			Message: "Approval request is invalid",
+		}
+		return
+	}
+
+	apprvl, err = approval.Get(db, apprvlId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "approval_invalid",
+				Message: "Approval request is invalid",
+			}
+		}
+		return
+	}
+
+	if approve {
+		errData, err = apprvl.Approve(db, usr)
+	} else {
+		errData, err = apprvl.Deny(db, usr)
+	}
+	if err != nil || errData != nil {
+		return
+	}
+
+	return
+}
+
+// Audit the answer for both the requester and approver
+func sshApprovalAudit(c *gin.Context, db *database.Database,
+	usr *user.User, apprvl *approval.Approval, typ string) (err error) {
+
+	err = audit.New(
+		db,
+		c.Request,
+		apprvl.UserId,
+		typ,
+		audit.Fields{
+			"approval_id":       apprvl.Id,
+			"ssh_key":           apprvl.PubKey,
+			"approver_id":       usr.Id,
+			"approver_username": usr.Username,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		typ,
+		audit.Fields{
+			"approval_id":        apprvl.Id,
+			"ssh_key":            apprvl.PubKey,
+			"requester_id":       apprvl.UserId,
+			"requester_username": apprvl.Username,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func sshApprovalPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	apprvl, errData, err := sshApprovalAnswer(c, db, usr, true)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	chal, err := challenge.Grant(db, apprvl)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			errData := &errortypes.ErrorData{
+				Error:   "approval_expired",
+				Message: "Request has expired",
+			}
+			c.JSON(400, errData)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	err = sshApprovalAudit(c, db, usr, apprvl, audit.SshApprovalGrant)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	// Browser terminals are connected after the approval
+	err = terminal.Refresh(db, chal.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, apprvl)
+}
+
+func sshApprovalDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	apprvl, errData, err := sshApprovalAnswer(c, db, usr, false)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = challenge.Reject(db, apprvl)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = sshApprovalAudit(c, db, usr, apprvl, audit.SshApprovalDeny)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, apprvl)
+}
@@ -0,0 +1,109 @@
+package uhandlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+// Dispatch events forwarded to user sessions, other events are only
+// sent to the management node
+var userEvents = set.NewSet(
+	"ssh_approval.change",
+)
+
+func eventGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	socket := &event.WebSocket{}
+
+	defer func() {
+		socket.Close()
+		event.WebSocketsLock.Lock()
+		event.WebSockets.Remove(socket)
+		event.WebSocketsLock.Unlock()
+	}()
+
+	event.WebSocketsLock.Lock()
+	event.WebSockets.Add(socket)
+	event.WebSocketsLock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	socket.Cancel = cancel
+
+	conn, err := event.Upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "uhandlers: Failed to upgrade request"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	socket.Conn = conn
+
+	conn.SetReadDeadline(time.Now().Add(pingWait))
+	conn.SetPongHandler(func(x string) (err error) {
+		conn.SetReadDeadline(time.Now().Add(pingWait))
+		return
+	})
+
+	lst, err := event.SubscribeListener(db, []string{"dispatch"})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	socket.Listener = lst
+
+	ticker := time.NewTicker(pingInterval)
+	socket.Ticker = ticker
+	sub := lst.Listen()
+
+	go func() {
+		defer func() {
+			recover()
+		}()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				conn.Close()
+				break
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-sub:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, []byte{},
+					time.Now().Add(writeTimeout))
+				return
+			}
+
+			typ, _ := msg.Data["type"].(string)
+			if !userEvents.Contains(typ) {
+				continue
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err = conn.WriteJSON(msg)
+			if err != nil {
+				return
+			}
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, []byte{},
+				time.Now().Add(writeTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
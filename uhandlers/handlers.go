@@ -55,6 +55,8 @@ func Register(engine *gin.Engine) {
 
 	authGroup.GET("/csrf", csrfGet)
 
+	csrfGroup.GET("/event", eventGet)
+
 	csrfGroup.GET("/device", devicesGet)
 	csrfGroup.PUT("/device/:device_id", devicePut)
 	csrfGroup.DELETE("/device/:device_id", deviceDelete)
@@ -75,6 +77,16 @@ func Register(engine *gin.Engine) {
 	dbGroup.POST("/ssh/challenge", sshChallengePost)
 	dbGroup.PUT("/ssh/challenge", sshChallengePut)
 	dbGroup.POST("/ssh/host", sshHostPost)
+	csrfGroup.POST("/ssh/service", sshServicePost)
+	csrfGroup.GET("/ssh/approval", sshApprovalsGet)
+	csrfGroup.PUT("/ssh/approval/:approval_id", sshApprovalPut)
+	csrfGroup.DELETE("/ssh/approval/:approval_id", sshApprovalDelete)
+	authGroup.GET("/ssh/config", sshConfigGet)
+	authGroup.GET("/ssh/known_hosts", sshKnownHostsGet)
+
+	csrfGroup.GET("/terminal", terminalAuthoritiesGet)
+	csrfGroup.POST("/terminal", terminalPost)
+	csrfGroup.GET("/terminal/:terminal_id", terminalGet)
 
 	engine.GET("/robots.txt", middlewear.RobotsGet)
 
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -32,6 +31,7 @@ func hsmGet(c *gin.Context) {
 
 	db := c.MustGet("db").(*database.Database)
 	authr := c.MustGet("authority").(*authority.Authority)
+	token := c.MustGet("hsm_token").(string)
 
 	socket := &event.WebSocket{}
 
@@ -76,9 +76,7 @@ func hsmGet(c *gin.Context) {
 	socket.Ticker = ticker
 	sub := lst.Listen()
 
-	authr.HsmStatus = authority.Connected
-	authr.HsmTimestamp = time.Now()
-	authr.CommitFields(db, set.NewSet("hsm_status", "hsm_timestamp"))
+	authr.SetHsmAgentStatus(db, token, authority.Connected)
 	event.PublishDispatch(db, "authority.change")
 
 	go func() {
@@ -102,8 +100,7 @@ func hsmGet(c *gin.Context) {
 					"error": e,
 				}).Error("uhandlers: Socket hsm listen error")
 
-				authr.HsmStatus = authority.Disconnected
-				authr.CommitFields(db, set.NewSet("hsm_status"))
+				authr.SetHsmAgentStatus(db, token, authority.Disconnected)
 				event.PublishDispatch(db, "authority.change")
 
 				conn.Close()
@@ -150,6 +147,11 @@ func hsmGet(c *gin.Context) {
 				return
 			}
 
+			// Requests are encrypted for a single agent
+			if msgToken, _ := msg.Data["token"].(string); msgToken != token {
+				continue
+			}
+
 			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
 			err = conn.WriteJSON(msg.Data)
 			if err != nil {
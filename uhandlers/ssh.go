@@ -4,17 +4,24 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/approval"
 	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/authority"
 	"github.com/hydeant/pritunl-zero/authorizer"
 	"github.com/hydeant/pritunl-zero/challenge"
 	"github.com/hydeant/pritunl-zero/database"
 	"github.com/hydeant/pritunl-zero/device"
 	"github.com/hydeant/pritunl-zero/errortypes"
 	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/inventory"
+	"github.com/hydeant/pritunl-zero/node"
 	"github.com/hydeant/pritunl-zero/secondary"
 	"github.com/hydeant/pritunl-zero/ssh"
 	"github.com/hydeant/pritunl-zero/u2flib"
+	"github.com/hydeant/pritunl-zero/user"
 	"github.com/hydeant/pritunl-zero/utils"
 )
 
@@ -58,6 +65,40 @@ func sshGet(c *gin.Context) {
 	c.Redirect(302, redirect)
 }
 
+// Audit the approved challenge, challenges left pending for a second
+// approver are audited as approval requests
+func sshChallengeAnswered(c *gin.Context, db *database.Database,
+	usr *user.User, chal *challenge.Challenge) {
+
+	typ := audit.SshApprove
+	fields := audit.Fields{
+		"ssh_key": chal.PubKey,
+	}
+	status := 200
+
+	if chal.State == ssh.Pending {
+		typ = audit.SshApprovalRequest
+		fields["approval_id"] = chal.ApprovalId
+		status = 202
+	}
+
+	err := audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		typ,
+		fields,
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.Publish(db, "ssh_challenge", chal.Id)
+
+	c.Status(status)
+}
+
 func sshValidatePut(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
@@ -143,23 +184,7 @@ func sshValidatePut(c *gin.Context) {
 		return
 	}
 
-	err = audit.New(
-		db,
-		c.Request,
-		usr.Id,
-		audit.SshApprove,
-		audit.Fields{
-			"ssh_key": chal.PubKey,
-		},
-	)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
-
-	event.Publish(db, "ssh_challenge", chal.Id)
-
-	c.Status(200)
+	sshChallengeAnswered(c, db, usr, chal)
 }
 
 func sshValidateDelete(c *gin.Context) {
@@ -286,23 +311,7 @@ func sshSecondaryPut(c *gin.Context) {
 		return
 	}
 
-	err = audit.New(
-		db,
-		c.Request,
-		usr.Id,
-		audit.SshApprove,
-		audit.Fields{
-			"ssh_key": chal.PubKey,
-		},
-	)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
-
-	event.Publish(db, "ssh_challenge", chal.Id)
-
-	c.Status(200)
+	sshChallengeAnswered(c, db, usr, chal)
 }
 
 func sshU2fSignGet(c *gin.Context) {
@@ -426,23 +435,7 @@ func sshU2fSignPost(c *gin.Context) {
 		return
 	}
 
-	err = audit.New(
-		db,
-		c.Request,
-		usr.Id,
-		audit.SshApprove,
-		audit.Fields{
-			"ssh_key": chal.PubKey,
-		},
-	)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
-
-	event.Publish(db, "ssh_challenge", chal.Id)
-
-	c.Status(200)
+	sshChallengeAnswered(c, db, usr, chal)
 }
 
 func sshChallengePut(c *gin.Context) {
@@ -518,6 +511,23 @@ func sshChallengePut(c *gin.Context) {
 		case ssh.Denied:
 			c.Status(401)
 			return true
+		case ssh.Pending:
+			apprvl, err := approval.Get(db, chal.ApprovalId)
+			if err != nil {
+				switch err.(type) {
+				case *database.NotFoundError:
+					c.Status(401)
+					break
+				default:
+					utils.AbortWithError(c, 500, err)
+				}
+				return true
+			}
+
+			if apprvl.Expired() {
+				c.Status(401)
+				return true
+			}
 		}
 
 		return false
@@ -570,7 +580,8 @@ func sshChallengePost(c *gin.Context) {
 		return
 	}
 
-	chal, err := challenge.NewChallenge(db, data.PublicKey)
+	chal, err := challenge.NewChallenge(db, data.PublicKey,
+		node.Self.GetRemoteAddr(c.Request))
 	if err != nil {
 		switch err.(type) {
 		case *database.NotFoundError:
@@ -596,8 +607,24 @@ type sshHostData struct {
 	PublicKey string   `json:"public_key"`
 }
 
+type sshServiceData struct {
+	AuthorityId primitive.ObjectID `json:"authority_id"`
+	PublicKey   string             `json:"public_key"`
+	Principals  []string           `json:"principals"`
+	Expire      int                `json:"expire"`
+}
+
+type sshServiceCertificateData struct {
+	Id                     primitive.ObjectID `json:"id"`
+	Certificates           []string           `json:"certificates"`
+	CertificateAuthorities []string           `json:"certificate_authorities"`
+	Hosts                  []*ssh.Host        `json:"hosts"`
+	CertificatesInfo       []*ssh.Info        `json:"certificates_info"`
+}
+
 type sshHostCertificateData struct {
 	Certificates []string `json:"certificates"`
+	Tokens       []string `json:"tokens,omitempty"`
 }
 
 func sshHostPost(c *gin.Context) {
@@ -612,7 +639,7 @@ func sshHostPost(c *gin.Context) {
 
 	hostname := domainRe.ReplaceAllString(data.Hostname, "")
 
-	cert, errData, err := ssh.NewHostCertificate(db, hostname,
+	cert, renewalTokens, errData, err := ssh.NewHostCertificate(db, hostname,
 		data.Port, data.Tokens, c.Request, data.PublicKey)
 	if err != nil {
 		switch err.(type) {
@@ -630,9 +657,179 @@ func sshHostPost(c *gin.Context) {
 		return
 	}
 
+	// Certificate was issued and tokens were redeemed, the response must
+	// be sent for the host to receive the renewal tokens
+	_, err = inventory.Sync(db, hostname,
+		node.Self.GetRemoteAddr(c.Request), cert)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hostname": hostname,
+			"error":    err,
+		}).Error("uhandlers: Failed to update host inventory")
+	} else {
+		event.PublishDispatch(db, "host.change")
+	}
+
 	resp := &sshHostCertificateData{
 		Certificates: cert.Certificates,
+		Tokens:       renewalTokens,
+	}
+
+	c.JSON(200, resp)
+}
+
+func sshServicePost(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	data := &sshServiceData{}
+
+	if !authr.IsApi() {
+		utils.AbortWithStatus(c, 401)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	cert, errData, err := ssh.NewServiceCertificate(db, data.AuthorityId,
+		usr, c.Request, data.PublicKey, node.Self.GetRemoteAddr(c.Request),
+		data.Principals, data.Expire)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		err = audit.New(
+			db,
+			c.Request,
+			usr.Id,
+			audit.SshDeny,
+			audit.Fields{
+				"authority_id": data.AuthorityId,
+				"ssh_key":      data.PublicKey,
+				"error":        errData.Error,
+				"message":      errData.Message,
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		c.JSON(400, errData)
+		return
+	}
+
+	serials := []string{}
+	principals := []string{}
+	for _, info := range cert.CertificatesInfo {
+		serials = append(serials, info.Serial)
+		principals = append(principals, info.Principals...)
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.SshServiceApprove,
+		audit.Fields{
+			"authority_id":   data.AuthorityId,
+			"certificate_id": cert.Id,
+			"ssh_key":        cert.PubKey,
+			"serials":        serials,
+			"principals":     principals,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	resp := &sshServiceCertificateData{
+		Id:                     cert.Id,
+		Certificates:           cert.Certificates,
+		CertificateAuthorities: cert.CertificateAuthorities,
+		Hosts:                  cert.Hosts,
+		CertificatesInfo:       cert.CertificatesInfo,
 	}
 
 	c.JSON(200, resp)
 }
+
+// Get the authorities available to the user
+func sshUserAuthorities(db *database.Database, usr *user.User) (
+	authrs []*authority.Authority, err error) {
+
+	allAuthrs, err := authority.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	authrs = []*authority.Authority{}
+	for _, authr := range allAuthrs {
+		if authr.UserHasAccess(usr) {
+			authrs = append(authrs, authr)
+		}
+	}
+
+	return
+}
+
+func sshConfigGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrs, err := sshUserAuthorities(db, usr)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	conf, err := ssh.RenderConfig(authrs)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\"config\"")
+	c.Data(200, "text/plain; charset=utf-8", []byte(conf))
+}
+
+func sshKnownHostsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrs, err := sshUserAuthorities(db, usr)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	knownHosts := ssh.RenderKnownHosts(authrs)
+
+	c.Header("Content-Disposition",
+		"attachment; filename=\"known_hosts\"")
+	c.Data(200, "text/plain; charset=utf-8", []byte(knownHosts))
+}
@@ -0,0 +1,505 @@
+package uhandlers
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/hydeant/pritunl-zero/audit"
+	"github.com/hydeant/pritunl-zero/authority"
+	"github.com/hydeant/pritunl-zero/authorizer"
+	"github.com/hydeant/pritunl-zero/database"
+	"github.com/hydeant/pritunl-zero/demo"
+	"github.com/hydeant/pritunl-zero/device"
+	"github.com/hydeant/pritunl-zero/errortypes"
+	"github.com/hydeant/pritunl-zero/event"
+	"github.com/hydeant/pritunl-zero/secondary"
+	"github.com/hydeant/pritunl-zero/ssh"
+	"github.com/hydeant/pritunl-zero/terminal"
+	"github.com/hydeant/pritunl-zero/utils"
+)
+
+type terminalData struct {
+	AuthorityId primitive.ObjectID `json:"authority_id"`
+	Host        string             `json:"host"`
+	Port        int                `json:"port"`
+	HostUser    string             `json:"host_user"`
+}
+
+type terminalAuthority struct {
+	Id   primitive.ObjectID `json:"id"`
+	Name string             `json:"name"`
+}
+
+type terminalResp struct {
+	Id        string                   `json:"id"`
+	Secondary *secondary.SecondaryData `json:"secondary,omitempty"`
+	Pending   bool                     `json:"pending,omitempty"`
+}
+
+type terminalMessage struct {
+	Type    string `json:"type"`
+	Columns int    `json:"columns,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func terminalAuthoritiesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrs, err := authority.GetAll(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	terminalAuthrs := []*terminalAuthority{}
+	for _, authrty := range authrs {
+		if authrty.UserHasAccess(usr) {
+			terminalAuthrs = append(terminalAuthrs, &terminalAuthority{
+				Id:   authrty.Id,
+				Name: authrty.Name,
+			})
+		}
+	}
+
+	c.JSON(200, terminalAuthrs)
+}
+
+func terminalPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	data := &terminalData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	authrty, err := authority.Get(db, data.AuthorityId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			errData := &errortypes.ErrorData{
+				Error:   "authority_invalid",
+				Message: "Authority is invalid",
+			}
+			c.JSON(400, errData)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if !authrty.UserHasAccess(usr) {
+		errData := &errortypes.ErrorData{
+			Error:   "authority_invalid",
+			Message: "Authority is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	data.Host = strings.Trim(strings.TrimSpace(data.Host), "[]")
+	if data.Host == "" || domainRe.MatchString(
+		strings.Replace(data.Host, ":", "", -1)) ||
+		!authrty.HostPermitted(data.Host) {
+
+		errData := &errortypes.ErrorData{
+			Error:   "host_invalid",
+			Message: "Host is invalid or not permitted by authority",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	if data.Port == 0 {
+		data.Port = 22
+	}
+	if data.Port < 1 || data.Port > 65535 {
+		errData := &errortypes.ErrorData{
+			Error:   "port_invalid",
+			Message: "Port is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	data.HostUser = strings.TrimSpace(data.HostUser)
+	if data.HostUser == "" || strings.ContainsAny(data.HostUser, "@: \t") {
+		errData := &errortypes.ErrorData{
+			Error:   "host_user_invalid",
+			Message: "Host user is invalid",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	term, chal, err := terminal.New(db, usr.Id, authrty.Id, data.Host,
+		data.Port, data.HostUser)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	deviceAuth, secProviderId, err, errData := chal.Approve(
+		db, usr, c.Request, false, false)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	if deviceAuth {
+		deviceCount, err := device.Count(db, usr.Id)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if deviceCount == 0 {
+			errData := &errortypes.ErrorData{
+				Error:   "secondary_device_unavailable",
+				Message: "Secondary authentication device not available",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		secd, err := secondary.NewChallenge(db, usr.Id,
+			secondary.AuthorityDevice, chal.Id, secondary.DeviceProvider)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		secData, err := secd.GetData()
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		c.JSON(201, &terminalResp{
+			Id:        term.Token,
+			Secondary: secData,
+		})
+		return
+	} else if !secProviderId.IsZero() {
+		secd, err := secondary.NewChallenge(
+			db, usr.Id, secondary.Authority, chal.Id, secProviderId)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		secData, err := secd.GetData()
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		c.JSON(201, &terminalResp{
+			Id:        term.Token,
+			Secondary: secData,
+		})
+		return
+	}
+
+	typ := audit.SshApprove
+	fields := audit.Fields{
+		"ssh_key": chal.PubKey,
+	}
+	pending := chal.State == ssh.Pending
+
+	if pending {
+		typ = audit.SshApprovalRequest
+		fields["approval_id"] = chal.ApprovalId
+
+		err = terminal.Refresh(db, chal.Id)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		typ,
+		fields,
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, &terminalResp{
+		Id:      term.Token,
+		Pending: pending,
+	})
+}
+
+func terminalGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	term, err := terminal.Get(db, c.Param("terminal_id"))
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if term.UserId != usr.Id {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	pending, err := term.Pending(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if pending {
+		errData := &errortypes.ErrorData{
+			Error:   "approval_pending",
+			Message: "Terminal is waiting for a second approver",
+		}
+		c.JSON(409, errData)
+		return
+	}
+
+	term, err = terminal.Claim(db, term.Token, usr.Id)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	client, err := term.Connect(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer client.Close()
+
+	fields := audit.Fields{
+		"authority_id": term.AuthorityId,
+		"host":         term.Host,
+		"port":         term.Port,
+		"host_user":    term.HostUser,
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.SshTerminalStart,
+		fields,
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	conn, err := event.Upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "uhandlers: Failed to upgrade terminal request"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer conn.Close()
+
+	writeLock := sync.Mutex{}
+	writeMessage := func(typ int, data []byte) (err error) {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		err = conn.WriteMessage(typ, data)
+		return
+	}
+
+	stdin, stdout, stderr, err := client.Start(80, 24)
+	if err != nil {
+		msg, _ := json.Marshal(&terminalMessage{
+			Type:    "error",
+			Message: "Failed to start terminal session",
+		})
+		writeMessage(websocket.TextMessage, msg)
+		return
+	}
+
+	start := time.Now()
+
+	conn.SetReadDeadline(time.Now().Add(pingWait))
+	conn.SetPongHandler(func(x string) (err error) {
+		conn.SetReadDeadline(time.Now().Add(pingWait))
+		return
+	})
+
+	go func() {
+		defer client.Close()
+
+		for {
+			typ, message, e := conn.ReadMessage()
+			if e != nil {
+				return
+			}
+
+			switch typ {
+			case websocket.BinaryMessage:
+				_, e = stdin.Write(message)
+				if e != nil {
+					return
+				}
+				break
+			case websocket.TextMessage:
+				msg := &terminalMessage{}
+				e = json.Unmarshal(message, msg)
+				if e != nil {
+					continue
+				}
+
+				if msg.Type == "resize" && msg.Columns > 0 &&
+					msg.Rows > 0 {
+
+					client.Resize(msg.Columns, msg.Rows)
+				}
+				break
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	done := make(chan bool)
+	defer func() {
+		ticker.Stop()
+		close(done)
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				writeLock.Lock()
+				e := conn.WriteControl(websocket.PingMessage, []byte{},
+					time.Now().Add(writeTimeout))
+				writeLock.Unlock()
+				if e != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	copyOutput := func(reader io.Reader) {
+		buf := make([]byte, 32*1024)
+		for {
+			n, e := reader.Read(buf)
+			if n > 0 {
+				e2 := writeMessage(websocket.BinaryMessage, buf[:n])
+				if e2 != nil {
+					client.Close()
+					return
+				}
+			}
+			if e != nil {
+				return
+			}
+		}
+	}
+
+	// Streams are read separately, an unread stream will block the
+	// session once the channel window is full
+	outputWaiter := sync.WaitGroup{}
+	outputWaiter.Add(2)
+
+	go func() {
+		defer outputWaiter.Done()
+		copyOutput(stdout)
+	}()
+
+	go func() {
+		defer outputWaiter.Done()
+		copyOutput(stderr)
+	}()
+
+	outputWaiter.Wait()
+	client.Wait()
+
+	writeLock.Lock()
+	conn.WriteControl(websocket.CloseMessage, []byte{},
+		time.Now().Add(writeTimeout))
+	writeLock.Unlock()
+
+	fields["duration"] = int(time.Since(start).Seconds())
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.SshTerminalEnd,
+		fields,
+	)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"user_id": usr.Id.Hex(),
+			"error":   err,
+		}).Error("uhandlers: Failed to audit terminal session end")
+	}
+}
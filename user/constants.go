@@ -1,6 +1,8 @@
 package user
 
 import (
+	"regexp"
+
 	"github.com/dropbox/godropbox/container/set"
 )
 
@@ -15,7 +17,8 @@ const (
 )
 
 var (
-	types = set.NewSet(
+	attributeName = regexp.MustCompile(`^[a-z0-9_]+$`)
+	types         = set.NewSet(
 		Local,
 		Api,
 		Azure,
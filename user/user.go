@@ -34,6 +34,7 @@ type User struct {
 	Disabled        bool               `bson:"disabled" json:"disabled"`
 	ActiveUntil     time.Time          `bson:"active_until" json:"active_until"`
 	Permissions     []string           `bson:"permissions" json:"permissions"`
+	Attributes      map[string]string  `bson:"attributes" json:"attributes"`
 }
 
 func (u *User) Validate(db *database.Database) (
@@ -47,6 +48,10 @@ func (u *User) Validate(db *database.Database) (
 		u.Permissions = []string{}
 	}
 
+	if u.Attributes == nil {
+		u.Attributes = map[string]string{}
+	}
+
 	if !types.Contains(u.Type) {
 		errData = &errortypes.ErrorData{
 			Error:   "user_type_invalid",
@@ -71,6 +76,16 @@ func (u *User) Validate(db *database.Database) (
 		return
 	}
 
+	for name := range u.Attributes {
+		if !attributeName.MatchString(name) {
+			errData = &errortypes.ErrorData{
+				Error:   "user_attribute_invalid",
+				Message: "User attribute name is not valid",
+			}
+			return
+		}
+	}
+
 	u.Format()
 
 	return
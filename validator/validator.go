@@ -11,6 +11,7 @@ import (
 	"github.com/hydeant/pritunl-zero/errortypes"
 	"github.com/hydeant/pritunl-zero/event"
 	"github.com/hydeant/pritunl-zero/policy"
+	"github.com/hydeant/pritunl-zero/revocation"
 	"github.com/hydeant/pritunl-zero/service"
 	"github.com/hydeant/pritunl-zero/user"
 )
@@ -28,6 +29,11 @@ func ValidateAdmin(db *database.Database, usr *user.User,
 			return
 		}
 
+		_, err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
@@ -109,6 +115,11 @@ func ValidateUser(db *database.Database, usr *user.User,
 			return
 		}
 
+		_, err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
@@ -179,6 +190,11 @@ func ValidateProxy(db *database.Database, usr *user.User,
 			return
 		}
 
+		_, err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
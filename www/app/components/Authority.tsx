@@ -643,7 +643,7 @@ export default class Authority extends React.Component<Props, State> {
 					/>
 					<PageSwitch
 						label="Automatic bastion server"
-						help="Enable automatic bastion servers on nodes using Docker containers. This should be disabled for most configurations."
+						help="Enable automatic bastion servers on nodes with the bastion node type. This should be disabled for most configurations."
 						checked={authority.proxy_hosting}
 						onToggle={(): void => {
 							this.toggle('proxy_hosting');
@@ -6,6 +6,7 @@ cp node_modules/normalize.css/normalize.css dist/static/
 cp node_modules/@blueprintjs/core/lib/css/blueprint.css dist/static/
 cp node_modules/@blueprintjs/datetime/lib/css/blueprint-datetime.css dist/static/
 cp node_modules/@blueprintjs/icons/lib/css/blueprint-icons.css dist/static/
+cp node_modules/xterm/css/xterm.css dist/static/
 cp node_modules/@blueprintjs/icons/resources/icons/icons-16.eot dist/static/
 cp node_modules/@blueprintjs/icons/resources/icons/icons-16.ttf dist/static/
 cp node_modules/@blueprintjs/icons/resources/icons/icons-16.woff dist/static/
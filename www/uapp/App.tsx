@@ -3,15 +3,18 @@ import * as React from 'react';
 import * as ReactDOM from 'react-dom';
 import * as Blueprint from '@blueprintjs/core';
 import * as StateActions from './actions/StateActions';
+import * as ApprovalActions from './actions/ApprovalActions';
 import Main from './components/Main';
 import * as Alert from './Alert';
 import * as Csrf from './Csrf';
+import * as Event from './Event';
 
 document.body.className = 'bp3-dark';
 
 Csrf.load().then((): void => {
 	Blueprint.FocusStyleManager.onlyShowFocusOnTabs();
 	Alert.init();
+	Event.init();
 
 	let query = window.location.search.substring(1);
 
@@ -25,6 +28,8 @@ Csrf.load().then((): void => {
 		}
 	}
 
+	ApprovalActions.sync();
+
 	ReactDOM.render(
 		<div><Main/></div>,
 		document.getElementById('app'),
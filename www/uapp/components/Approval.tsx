@@ -0,0 +1,163 @@
+/// <reference path="../References.d.ts"/>
+import * as React from 'react';
+import * as Blueprint from '@blueprintjs/core';
+import * as ApprovalTypes from '../types/ApprovalTypes';
+import * as ApprovalActions from '../actions/ApprovalActions';
+import * as MiscUtils from '../utils/MiscUtils';
+import * as Alert from '../Alert';
+import ConfirmButton from './ConfirmButton';
+
+interface Props {
+	approval: ApprovalTypes.ApprovalRo;
+}
+
+interface State {
+	disabled: boolean;
+}
+
+const css = {
+	card: {
+		position: 'relative',
+		padding: '5px 7px 2px 7px',
+		marginBottom: '10px',
+	} as React.CSSProperties,
+	info: {
+		marginTop: '5px',
+	} as React.CSSProperties,
+	icon: {
+		marginTop: '5px',
+	} as React.CSSProperties,
+	name: {
+		margin: '7px 3px 0 7px',
+	} as React.CSSProperties,
+	key: {
+		wordBreak: 'break-all',
+	} as React.CSSProperties,
+	item: {
+		marginBottom: '3px',
+	} as React.CSSProperties,
+	buttons: {
+		marginBottom: '3px',
+	} as React.CSSProperties,
+	button: {
+		marginLeft: '5px',
+	} as React.CSSProperties,
+};
+
+export default class Approval extends React.Component<Props, State> {
+	constructor(props: any, context: any) {
+		super(props, context);
+		this.state = {
+			disabled: false,
+		};
+	}
+
+	onApprove = (): void => {
+		this.setState({
+			...this.state,
+			disabled: true,
+		});
+		ApprovalActions.approve(this.props.approval.id).then((): void => {
+			Alert.success('Request approved');
+			ApprovalActions.sync();
+		}).catch((): void => {
+			this.setState({
+				...this.state,
+				disabled: false,
+			});
+		});
+	}
+
+	onDeny = (): void => {
+		this.setState({
+			...this.state,
+			disabled: true,
+		});
+		ApprovalActions.deny(this.props.approval.id).then((): void => {
+			Alert.success('Request denied');
+			ApprovalActions.sync();
+		}).catch((): void => {
+			this.setState({
+				...this.state,
+				disabled: false,
+			});
+		});
+	}
+
+	render(): JSX.Element {
+		let approval = this.props.approval;
+		let agent = approval.agent || {};
+
+		let location = [agent.city, agent.region_code,
+			agent.country_code].filter((val): boolean => !!val).join(', ');
+
+		let pubKey = approval.pub_key || '';
+		let pubKeySplit = pubKey.split(' ');
+		if (pubKeySplit.length > 1) {
+			pubKey = pubKeySplit[0] + ' ...' + pubKeySplit[1].slice(-16);
+		}
+
+		return <div
+			className="bp3-card"
+			style={css.card}
+		>
+			<div className="layout horizontal">
+				<Blueprint.Icon
+					icon="key"
+					iconSize={20}
+					style={css.icon}
+				/>
+				<div className="flex" style={css.name}>
+					{approval.username}
+				</div>
+				<div style={css.buttons}>
+					<ConfirmButton
+						className="bp3-minimal bp3-intent-danger bp3-icon-cross"
+						progressClassName="bp3-intent-danger"
+						confirmMsg="Confirm deny request"
+						disabled={this.state.disabled}
+						onConfirm={this.onDeny}
+					/>
+					<button
+						className="bp3-button bp3-intent-success bp3-icon-tick"
+						style={css.button}
+						disabled={this.state.disabled}
+						onClick={this.onApprove}
+					>Approve</button>
+				</div>
+			</div>
+			<div className="layout vertical" style={css.info}>
+				<div style={css.item}>
+					Key: <span className="bp3-text-muted" style={css.key}>
+						{pubKey}
+					</span>
+				</div>
+				<div style={css.item}>
+					Address: <span className="bp3-text-muted">
+						{approval.remote_addr}
+					</span>
+				</div>
+				<div style={css.item} hidden={!location}>
+					Location: <span className="bp3-text-muted">
+						{location}
+					</span>
+				</div>
+				<div style={css.item} hidden={!agent.operating_system}>
+					Platform: <span className="bp3-text-muted">
+						{agent.operating_system}
+					</span>
+				</div>
+				<div style={css.item}>
+					Requested: <span className="bp3-text-muted">
+						{MiscUtils.formatDateMid(approval.timestamp)}
+					</span>
+				</div>
+				<div style={css.item}>
+					Expires: <span className="bp3-text-muted">
+						{MiscUtils.formatDateMid(approval.expires)}
+					</span>
+				</div>
+			</div>
+		</div>;
+	}
+}
@@ -0,0 +1,123 @@
+/// <reference path="../References.d.ts"/>
+import * as React from 'react';
+import * as Blueprint from '@blueprintjs/core';
+import * as ApprovalTypes from '../types/ApprovalTypes';
+import ApprovalsStore from '../stores/ApprovalsStore';
+import * as ApprovalActions from '../actions/ApprovalActions';
+import Approval from './Approval';
+import * as Constants from '../Constants';
+
+interface Props {
+	onClose: () => void;
+}
+
+interface State {
+	approvals: ApprovalTypes.ApprovalsRo;
+	initialized: boolean;
+}
+
+const css = {
+	items: {
+		marginTop: '15px',
+	} as React.CSSProperties,
+	state: {
+		marginBottom: '5px',
+	} as React.CSSProperties,
+	stateIcon: {
+		marginBottom: '10px',
+	} as React.CSSProperties,
+	title: {
+		textAlign: 'center',
+	} as React.CSSProperties,
+	close: {
+		position: 'absolute',
+		top: '7px',
+		right: '7px',
+		width: '36px',
+	} as React.CSSProperties,
+};
+
+export default class Approvals extends React.Component<Props, State> {
+	timeout: number;
+
+	constructor(props: any, context: any) {
+		super(props, context);
+		this.state = {
+			approvals: ApprovalsStore.approvals,
+			initialized: false,
+		};
+	}
+
+	componentDidMount(): void {
+		ApprovalsStore.addChangeListener(this.onChange);
+		ApprovalActions.sync();
+
+		this.timeout = window.setTimeout((): void => {
+			this.setState({
+				...this.state,
+				initialized: true,
+			});
+		}, Constants.loadDelay);
+	}
+
+	componentWillUnmount(): void {
+		ApprovalsStore.removeChangeListener(this.onChange);
+
+		if (this.timeout) {
+			window.clearTimeout(this.timeout);
+		}
+	}
+
+	onChange = (): void => {
+		this.setState({
+			...this.state,
+			approvals: ApprovalsStore.approvals,
+		});
+	}
+
+	render(): JSX.Element {
+		let approvalsDom: JSX.Element[] = [];
+
+		this.state.approvals.forEach(
+				(approval: ApprovalTypes.ApprovalRo): void => {
+			approvalsDom.push(<Approval
+				key={approval.id}
+				approval={approval}
+			/>);
+		});
+
+		return <div>
+			<button
+				className="bp3-button bp3-minimal bp3-intent-danger"
+				style={css.close}
+				onClick={this.props.onClose}
+			>
+				<Blueprint.Icon icon="cross" iconSize={26}/>
+			</button>
+			<h4 style={css.title}>
+				SSH Approvals
+			</h4>
+			<div
+				className="layout vertical center-justified wrap"
+				style={css.items}
+			>
+				{approvalsDom}
+				<div
+					className="bp3-non-ideal-state"
+					style={css.state}
+					hidden={!!approvalsDom.length || !this.state.initialized}
+				>
+					<div
+						className="bp3-non-ideal-state-visual bp3-non-ideal-state-icon"
+						style={css.stateIcon}
+					>
+						<Blueprint.Icon icon="endorsed" iconSize={80}/>
+					</div>
+					<h4 className="bp3-non-ideal-state-title">
+						No pending requests
+					</h4>
+				</div>
+			</div>
+		</div>;
+	}
+}
@@ -5,9 +5,13 @@ import LoadingBar from './LoadingBar';
 import Session from './Session';
 import Validate from './Validate';
 import Devices from './Devices';
+import Approvals from './Approvals';
+import Terminal from './Terminal';
 
 interface State {
 	devicesOpen: boolean;
+	approvalsOpen: boolean;
+	terminalOpen: boolean;
 	sshToken: string;
 	sshDevice: string;
 }
@@ -37,6 +41,8 @@ export default class Main extends React.Component<{}, State> {
 		super(props, context);
 		this.state = {
 			devicesOpen: false,
+			approvalsOpen: false,
+			terminalOpen: false,
 			sshToken: StateStore.sshToken,
 			sshDevice: StateStore.sshDevice,
 		};
@@ -61,6 +67,20 @@ export default class Main extends React.Component<{}, State> {
 	render(): JSX.Element {
 		let bodyElm: JSX.Element;
 
+		if (this.state.terminalOpen && !this.state.sshToken) {
+			return <div>
+				<LoadingBar style={css.loading} intent="primary"/>
+				<Terminal
+					onClose={(): void => {
+						this.setState({
+							...this.state,
+							terminalOpen: false,
+						});
+					}}
+				/>
+			</div>;
+		}
+
 		if (this.state.sshToken) {
 			bodyElm = <Validate token={this.state.sshToken}/>;
 		} else if (this.state.devicesOpen || this.state.sshDevice) {
@@ -72,6 +92,15 @@ export default class Main extends React.Component<{}, State> {
 					});
 				}}
 			/>;
+		} else if (this.state.approvalsOpen) {
+			bodyElm = <Approvals
+				onClose={(): void => {
+					this.setState({
+						...this.state,
+						approvalsOpen: false,
+					});
+				}}
+			/>;
 		} else {
 			bodyElm = <Session
 				onDevices={(): void => {
@@ -80,6 +109,18 @@ export default class Main extends React.Component<{}, State> {
 						devicesOpen: true,
 					});
 				}}
+				onApprovals={(): void => {
+					this.setState({
+						...this.state,
+						approvalsOpen: true,
+					});
+				}}
+				onTerminal={(): void => {
+					this.setState({
+						...this.state,
+						terminalOpen: true,
+					});
+				}}
 			/>;
 		}
 
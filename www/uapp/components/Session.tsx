@@ -1,8 +1,15 @@
 /// <reference path="../References.d.ts"/>
 import * as React from 'react';
+import ApprovalsStore from '../stores/ApprovalsStore';
 
 interface Props {
 	onDevices: () => void;
+	onApprovals: () => void;
+	onTerminal: () => void;
+}
+
+interface State {
+	approvals: number;
 }
 
 const css = {
@@ -22,8 +29,35 @@ const css = {
 	} as React.CSSProperties,
 };
 
-export default class Session extends React.Component<Props, {}> {
+export default class Session extends React.Component<Props, State> {
+	constructor(props: any, context: any) {
+		super(props, context);
+		this.state = {
+			approvals: ApprovalsStore.approvals.length,
+		};
+	}
+
+	componentDidMount(): void {
+		ApprovalsStore.addChangeListener(this.onChange);
+	}
+
+	componentWillUnmount(): void {
+		ApprovalsStore.removeChangeListener(this.onChange);
+	}
+
+	onChange = (): void => {
+		this.setState({
+			...this.state,
+			approvals: ApprovalsStore.approvals.length,
+		});
+	}
+
 	render(): JSX.Element {
+		let approvalsLabel = 'SSH Approvals';
+		if (this.state.approvals) {
+			approvalsLabel += ' (' + this.state.approvals + ')';
+		}
+
 		return <div>
 			<div className="bp3-non-ideal-state" style={css.body}>
 				<h4 className="bp3-non-ideal-state-title">
@@ -51,6 +85,20 @@ export default class Session extends React.Component<Props, {}> {
 				>
 					Security Devices
 				</button>
+				<button
+					className="bp3-button bp3-large bp3-intent-primary bp3-icon-endorsed"
+					style={css.button}
+					onClick={this.props.onApprovals}
+				>
+					{approvalsLabel}
+				</button>
+				<button
+					className="bp3-button bp3-large bp3-intent-primary bp3-icon-console"
+					style={css.button}
+					onClick={this.props.onTerminal}
+				>
+					SSH Terminal
+				</button>
 				<a
 					className="bp3-button bp3-large bp3-intent-warning bp3-icon-delete"
 					style={css.button}
@@ -0,0 +1,830 @@
+/// <reference path="../References.d.ts"/>
+import * as React from 'react';
+import * as Blueprint from '@blueprintjs/core';
+import * as SuperAgent from 'superagent';
+import * as XTerm from 'xterm';
+import {FitAddon} from 'xterm-addon-fit';
+import * as Csrf from '../Csrf';
+import * as Alert from '../Alert';
+import Loader from '../Loader';
+import EventDispatcher from '../dispatcher/EventDispatcher';
+import * as ApprovalTypes from '../types/ApprovalTypes';
+import * as GlobalTypes from '../types/GlobalTypes';
+
+interface Authority {
+	id: string;
+	name: string;
+}
+
+interface Secondary {
+	token: string;
+	label: string;
+	push: boolean;
+	phone: boolean;
+	passcode: boolean;
+	sms: boolean;
+	device: boolean;
+	device_register: boolean;
+}
+
+interface SecondaryState {
+	push: boolean;
+	phone: boolean;
+	passcode: boolean;
+	sms: boolean;
+}
+
+interface TerminalMessage {
+	type: string;
+	columns?: number;
+	rows?: number;
+	message?: string;
+}
+
+interface Props {
+	onClose: () => void;
+}
+
+interface State {
+	disabled: boolean;
+	authorities: Authority[];
+	authorityId: string;
+	host: string;
+	port: string;
+	hostUser: string;
+	terminalId: string;
+	pending: boolean;
+	connected: boolean;
+	passcode: string;
+	secondary: Secondary;
+	secondaryState: SecondaryState;
+}
+
+const css = {
+	card: {
+		padding: '20px 15px',
+		minWidth: '260px',
+		maxWidth: '320px',
+		margin: '0 auto',
+		position: 'absolute',
+		top: '50%',
+		left: '50%',
+		width: '100%',
+		transform: 'translate(-50%, -50%)',
+	} as React.CSSProperties,
+	body: {
+		padding: 0,
+	} as React.CSSProperties,
+	description: {
+		opacity: 0.7,
+		padding: '0 10px',
+	} as React.CSSProperties,
+	title: {
+		textAlign: 'center',
+	} as React.CSSProperties,
+	label: {
+		width: '100%',
+		maxWidth: '280px',
+		margin: '0 auto 10px auto',
+	} as React.CSSProperties,
+	buttons: {
+		marginTop: '15px',
+	} as React.CSSProperties,
+	secondaryButton: {
+		margin: '5px auto',
+		padding: '8px 15px',
+		width: '75%',
+	} as React.CSSProperties,
+	secondaryInput: {
+		margin: '5px auto',
+		width: '75%',
+	} as React.CSSProperties,
+	close: {
+		position: 'absolute',
+		top: '7px',
+		right: '7px',
+		width: '36px',
+	} as React.CSSProperties,
+	terminal: {
+		position: 'absolute',
+		top: 0,
+		left: 0,
+		right: 0,
+		bottom: 0,
+		backgroundColor: '#000',
+	} as React.CSSProperties,
+	terminalBody: {
+		position: 'absolute',
+		top: '40px',
+		left: '5px',
+		right: '5px',
+		bottom: '5px',
+	} as React.CSSProperties,
+	terminalClose: {
+		position: 'absolute',
+		top: '2px',
+		right: '5px',
+	} as React.CSSProperties,
+};
+
+const u2fErrorCodes: {[index: number]: string} = {
+	0: 'ok',
+	1: 'other',
+	2: 'bad request',
+	3: 'configuration unsupported',
+	4: 'device ineligible',
+	5: 'timed out',
+};
+
+export default class Terminal extends React.Component<Props, State> {
+	alertKey: string;
+	eventToken: string;
+	socket: WebSocket;
+	term: XTerm.Terminal;
+	fit: FitAddon;
+	termElem: HTMLDivElement;
+
+	constructor(props: any, context: any) {
+		super(props, context);
+		this.state = {
+			disabled: false,
+			authorities: [],
+			authorityId: '',
+			host: '',
+			port: '22',
+			hostUser: '',
+			terminalId: null,
+			pending: false,
+			connected: false,
+			passcode: '',
+			secondary: null,
+			secondaryState: null,
+		};
+	}
+
+	componentDidMount(): void {
+		window.addEventListener('resize', this.onResize);
+
+		this.eventToken = EventDispatcher.register(
+				(action: GlobalTypes.Dispatch): void => {
+			if (action.type === ApprovalTypes.CHANGE && this.state.pending) {
+				this.connect();
+			}
+		});
+
+		let loader = new Loader().loading();
+
+		SuperAgent
+			.get('/terminal')
+			.set('Accept', 'application/json')
+			.set('Csrf-Token', Csrf.token)
+			.end((err: any, res: SuperAgent.Response): void => {
+				loader.done();
+
+				if (res && res.status === 401) {
+					window.location.href = '/login';
+					return;
+				}
+
+				if (err) {
+					Alert.errorRes(res, 'Failed to load authorities');
+					return;
+				}
+
+				let authorities: Authority[] = res.body || [];
+
+				this.setState({
+					...this.state,
+					authorities: authorities,
+					authorityId: authorities.length ? authorities[0].id : '',
+				});
+			});
+	}
+
+	componentWillUnmount(): void {
+		window.removeEventListener('resize', this.onResize);
+		EventDispatcher.unregister(this.eventToken);
+		this.disconnect();
+	}
+
+	onResize = (): void => {
+		if (!this.term) {
+			return;
+		}
+
+		this.fit.fit();
+	}
+
+	sendMessage(msg: TerminalMessage): void {
+		if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+			this.socket.send(JSON.stringify(msg));
+		}
+	}
+
+	disconnect(): void {
+		if (this.socket) {
+			this.socket.close();
+			this.socket = null;
+		}
+
+		if (this.term) {
+			this.term.dispose();
+			this.term = null;
+			this.fit = null;
+		}
+	}
+
+	open(socket: WebSocket): void {
+		Alert.dismiss(this.alertKey);
+
+		let term = new XTerm.Terminal({
+			cursorBlink: true,
+		});
+		let fit = new FitAddon();
+		term.loadAddon(fit);
+		term.open(this.termElem);
+		fit.fit();
+		term.focus();
+
+		this.term = term;
+		this.fit = fit;
+
+		term.onData((data: string): void => {
+			if (socket.readyState === WebSocket.OPEN) {
+				socket.send(new TextEncoder().encode(data));
+			}
+		});
+
+		term.onResize((size: {cols: number, rows: number}): void => {
+			this.sendMessage({
+				type: 'resize',
+				columns: size.cols,
+				rows: size.rows,
+			});
+		});
+
+		this.sendMessage({
+			type: 'resize',
+			columns: term.cols,
+			rows: term.rows,
+		});
+	}
+
+	connect = (): void => {
+		if (!this.state.terminalId || this.socket) {
+			return;
+		}
+
+		let url = '';
+		let location = window.location;
+
+		if (location.protocol === 'https:') {
+			url += 'wss';
+		} else {
+			url += 'ws';
+		}
+
+		url += '://' + location.host + '/terminal/' + this.state.terminalId +
+			'?csrf_token=' + Csrf.token;
+
+		let opened = false;
+		let socket = new WebSocket(url);
+		socket.binaryType = 'arraybuffer';
+		this.socket = socket;
+
+		socket.addEventListener('open', (): void => {
+			opened = true;
+
+			this.setState({
+				...this.state,
+				pending: false,
+				connected: true,
+			}, (): void => {
+				this.open(socket);
+			});
+		});
+
+		socket.addEventListener('message', (evt: MessageEvent): void => {
+			if (evt.data instanceof ArrayBuffer) {
+				if (this.term) {
+					this.term.write(new Uint8Array(evt.data));
+				}
+				return;
+			}
+
+			let msg: TerminalMessage = JSON.parse(evt.data);
+			if (msg.type === 'error') {
+				Alert.error(msg.message);
+			}
+		});
+
+		socket.addEventListener('close', (): void => {
+			if (this.socket !== socket) {
+				return;
+			}
+			this.socket = null;
+
+			// Terminal is still waiting for a second approver
+			if (!opened && this.state.pending) {
+				return;
+			}
+
+			if (!opened) {
+				Alert.error('Failed to connect to terminal');
+			} else if (this.term) {
+				this.term.write('\r\n\r\nConnection closed\r\n');
+			}
+
+			this.setState({
+				...this.state,
+				disabled: false,
+				pending: false,
+				terminalId: null,
+			});
+		});
+	}
+
+	onConnected(res: SuperAgent.Response): void {
+		if (res.status === 202 || (res.body && res.body.pending)) {
+			this.alertKey = Alert.info(
+				'Waiting for a second user to approve the request', 0);
+
+			this.setState({
+				...this.state,
+				secondary: null,
+				pending: true,
+			});
+			return;
+		}
+
+		this.setState({
+			...this.state,
+			secondary: null,
+			pending: false,
+		}, this.connect);
+	}
+
+	onSecondary(secondary: Secondary): void {
+		this.setState({
+			...this.state,
+			secondary: secondary,
+			secondaryState: {
+				push: true,
+				phone: true,
+				passcode: true,
+				sms: true,
+			},
+		});
+
+		if (secondary.device) {
+			this.deviceSign(secondary.token);
+		}
+	}
+
+	onSubmit = (): void => {
+		Alert.dismiss(this.alertKey);
+
+		this.disconnect();
+		this.setState({
+			...this.state,
+			disabled: true,
+			connected: false,
+			pending: false,
+			terminalId: null,
+		});
+
+		let loader = new Loader().loading();
+
+		SuperAgent
+			.post('/terminal')
+			.send({
+				authority_id: this.state.authorityId,
+				host: this.state.host,
+				port: parseInt(this.state.port, 10) || 0,
+				host_user: this.state.hostUser,
+			})
+			.set('Accept', 'application/json')
+			.set('Csrf-Token', Csrf.token)
+			.end((err: any, res: SuperAgent.Response): void => {
+				loader.done();
+
+				if (res && res.status === 401) {
+					window.location.href = '/login';
+					return;
+				}
+
+				if (err) {
+					Alert.errorRes(res, 'Failed to create terminal');
+					this.setState({
+						...this.state,
+						disabled: false,
+					});
+					return;
+				}
+
+				this.setState({
+					...this.state,
+					terminalId: res.body.id,
+				}, (): void => {
+					if (res.status === 201) {
+						this.onSecondary(res.body.secondary);
+					} else {
+						this.onConnected(res);
+					}
+				});
+			});
+	}
+
+	onCancel = (): void => {
+		Alert.dismiss(this.alertKey);
+		this.disconnect();
+
+		this.setState({
+			...this.state,
+			disabled: false,
+			connected: false,
+			pending: false,
+			terminalId: null,
+			secondary: null,
+		});
+	}
+
+	u2fSigned = (resp: any): void => {
+		Alert.dismiss(this.alertKey);
+
+		if (resp.errorCode) {
+			let errorMsg = 'U2F error code ' + resp.errorCode;
+			let u2fMsg = u2fErrorCodes[resp.errorCode as number];
+			if (u2fMsg) {
+				errorMsg += ': ' + u2fMsg;
+			}
+			Alert.error(errorMsg);
+			this.onCancel();
+
+			return
+		}
+
+		let loader = new Loader().loading();
+
+		SuperAgent
+			.post('/ssh/u2f/sign')
+			.send({
+				token: this.state.secondary.token,
+				response: resp,
+			})
+			.set('Accept', 'application/json')
+			.set('Csrf-Token', Csrf.token)
+			.end((err: any, res: SuperAgent.Response): void => {
+				loader.done();
+
+				if (err) {
+					Alert.errorRes(res, 'Failed to complete device sign');
+					this.onCancel();
+					return;
+				}
+
+				if (res.status === 201) {
+					this.onSecondary(res.body);
+					return;
+				}
+
+				this.onConnected(res);
+			});
+	}
+
+	deviceSign(token: string): void {
+		let loader = new Loader().loading();
+
+		SuperAgent
+			.get('/ssh/u2f/sign')
+			.query({
+				token: token,
+			})
+			.set('Accept', 'application/json')
+			.set('Csrf-Token', Csrf.token)
+			.end((err: any, res: SuperAgent.Response): void => {
+				loader.done();
+
+				if (err) {
+					Alert.errorRes(res, 'Failed to request device sign');
+					this.onCancel();
+					return;
+				}
+
+				this.alertKey = Alert.info(
+					'Insert your security key and tap the button', 30000);
+
+				(window as any).u2f.sign(res.body.appId,
+					res.body.challenge, res.body.registeredKeys,
+					this.u2fSigned, 30);
+			});
+	}
+
+	secondarySubmit(factor: string): void {
+		let passcode = '';
+		if (factor === 'passcode') {
+			passcode = this.state.passcode;
+		}
+
+		SuperAgent
+			.put('/ssh/secondary')
+			.send({
+				token: this.state.secondary.token,
+				factor: factor,
+				passcode: passcode
+			})
+			.set('Accept', 'application/json')
+			.set('Csrf-Token', Csrf.token)
+			.end((err: any, res: SuperAgent.Response): void => {
+				this.setState({
+					...this.state,
+					passcode: '',
+					secondaryState: {
+						...this.state.secondaryState,
+						passcode: true,
+					},
+				});
+
+				if (res && res.status === 404) {
+					Alert.error('Terminal request has expired', 0);
+					this.onCancel();
+					return;
+				} else if (err) {
+					Alert.errorRes(res, 'Failed to authenticate terminal', 0);
+					return;
+				} else if (res.status === 206 && factor === 'sms') {
+					Alert.info('Text message sent', 0);
+					return;
+				}
+
+				this.onConnected(res);
+			});
+	}
+
+	secondaryFactor(factor: string, label: string,
+			hidden: boolean, disabled: boolean): JSX.Element {
+		return <button
+			className="bp3-button"
+			style={css.secondaryButton}
+			type="button"
+			hidden={hidden}
+			disabled={disabled}
+			onClick={(): void => {
+				this.setState({
+					...this.state,
+					secondaryState: {
+						...this.state.secondaryState,
+						[factor]: false,
+					},
+				});
+				this.secondarySubmit(factor);
+			}}
+		>
+			{label}
+		</button>;
+	}
+
+	secondary(): JSX.Element {
+		let secondary = this.state.secondary;
+		let secondaryState = this.state.secondaryState;
+
+		if (secondary.device) {
+			return <div>
+				<div className="bp3-non-ideal-state" style={css.body}>
+					<div className="bp3-non-ideal-state-visual bp3-non-ideal-state-icon">
+						<span className="bp3-icon bp3-icon-key"/>
+					</div>
+					<h4 className="bp3-non-ideal-state-title">
+						{secondary.label}
+					</h4>
+					<span style={css.description}>
+						Insert your security key and tap the button
+					</span>
+				</div>
+			</div>;
+		}
+
+		return <div>
+			<div className="bp3-non-ideal-state" style={css.body}>
+				<div className="bp3-non-ideal-state-visual bp3-non-ideal-state-icon">
+					<span className="bp3-icon bp3-icon-key"/>
+				</div>
+				<h4 className="bp3-non-ideal-state-title">
+					{secondary.label}
+				</h4>
+				<span style={css.description}>
+					Secondary authentication required
+				</span>
+			</div>
+			<div className="layout vertical center-justified" style={css.buttons}>
+				{this.secondaryFactor('push', 'Push',
+					!secondary.push, !secondaryState.push)}
+				{this.secondaryFactor('phone', 'Call Me',
+					!secondary.phone, !secondaryState.phone)}
+				{this.secondaryFactor('sms', 'Text Me',
+					!secondary.sms, !secondaryState.sms)}
+				<input
+					className="bp3-input"
+					style={css.secondaryInput}
+					hidden={!secondary.passcode}
+					disabled={!secondaryState.passcode}
+					type="text"
+					autoCapitalize="off"
+					spellCheck={false}
+					placeholder="Passcode"
+					value={this.state.passcode || ''}
+					onChange={(evt): void => {
+						this.setState({
+							...this.state,
+							passcode: evt.target.value,
+						});
+					}}
+					onKeyPress={(evt): void => {
+						if (evt.key === 'Enter') {
+							this.setState({
+								...this.state,
+								secondaryState: {
+									...this.state.secondaryState,
+									passcode: false,
+								},
+							});
+							this.secondarySubmit('passcode');
+						}
+					}}
+				/>
+				{this.secondaryFactor('passcode', 'Submit',
+					!secondary.passcode, !secondaryState.passcode)}
+				<button
+					className="bp3-button bp3-intent-danger"
+					style={css.secondaryButton}
+					type="button"
+					onClick={this.onCancel}
+				>
+					Cancel
+				</button>
+			</div>
+		</div>;
+	}
+
+	pending(): JSX.Element {
+		return <div>
+			<div className="bp3-non-ideal-state" style={css.body}>
+				<div className="bp3-non-ideal-state-visual bp3-non-ideal-state-icon">
+					<span className="bp3-icon bp3-icon-time"/>
+				</div>
+				<h4 className="bp3-non-ideal-state-title">
+					Waiting for Approval
+				</h4>
+				<span style={css.description}>
+					The terminal will connect once a second user approves
+					the request
+				</span>
+			</div>
+			<div className="layout vertical center-justified" style={css.buttons}>
+				<button
+					className="bp3-button bp3-intent-danger"
+					style={css.secondaryButton}
+					type="button"
+					onClick={this.onCancel}
+				>
+					Cancel
+				</button>
+			</div>
+		</div>;
+	}
+
+	form(): JSX.Element {
+		let authoritiesSelect: JSX.Element[] = [];
+		for (let authr of this.state.authorities) {
+			authoritiesSelect.push(
+				<option key={authr.id} value={authr.id}>{authr.name}</option>,
+			);
+		}
+
+		return <div>
+			<button
+				className="bp3-button bp3-minimal bp3-intent-danger"
+				style={css.close}
+				onClick={this.props.onClose}
+			>
+				<Blueprint.Icon icon="cross" iconSize={26}/>
+			</button>
+			<h4 style={css.title}>
+				SSH Terminal
+			</h4>
+			<div className="layout vertical" style={css.buttons}>
+				<label className="bp3-label" style={css.label}>
+					Authority
+					<div className="bp3-select bp3-fill">
+						<select
+							disabled={this.state.disabled}
+							value={this.state.authorityId}
+							onChange={(evt): void => {
+								this.setState({
+									...this.state,
+									authorityId: evt.target.value,
+								});
+							}}
+						>
+							{authoritiesSelect}
+						</select>
+					</div>
+				</label>
+				<label className="bp3-label" style={css.label}>
+					Host
+					<input
+						className="bp3-input bp3-fill"
+						type="text"
+						autoCapitalize="off"
+						spellCheck={false}
+						placeholder="Host"
+						disabled={this.state.disabled}
+						value={this.state.host}
+						onChange={(evt): void => {
+							this.setState({
+								...this.state,
+								host: evt.target.value,
+							});
+						}}
+					/>
+				</label>
+				<label className="bp3-label" style={css.label}>
+					Port
+					<input
+						className="bp3-input bp3-fill"
+						type="text"
+						placeholder="Port"
+						disabled={this.state.disabled}
+						value={this.state.port}
+						onChange={(evt): void => {
+							this.setState({
+								...this.state,
+								port: evt.target.value,
+							});
+						}}
+					/>
+				</label>
+				<label className="bp3-label" style={css.label}>
+					User
+					<input
+						className="bp3-input bp3-fill"
+						type="text"
+						autoCapitalize="off"
+						spellCheck={false}
+						placeholder="User"
+						disabled={this.state.disabled}
+						value={this.state.hostUser}
+						onChange={(evt): void => {
+							this.setState({
+								...this.state,
+								hostUser: evt.target.value,
+							});
+						}}
+						onKeyPress={(evt): void => {
+							if (evt.key === 'Enter') {
+								this.onSubmit();
+							}
+						}}
+					/>
+				</label>
+				<button
+					className="bp3-button bp3-intent-success bp3-icon-console"
+					style={css.secondaryButton}
+					disabled={this.state.disabled || !this.state.authorityId}
+					onClick={this.onSubmit}
+				>Connect</button>
+			</div>
+		</div>;
+	}
+
+	render(): JSX.Element {
+		if (this.state.connected) {
+			return <div style={css.terminal}>
+				<button
+					className="bp3-button bp3-minimal bp3-intent-danger bp3-icon-cross"
+					style={css.terminalClose}
+					onClick={this.onCancel}
+				>Disconnect</button>
+				<div
+					style={css.terminalBody}
+					ref={(elem): void => {
+						this.termElem = elem;
+					}}
+				/>
+			</div>;
+		}
+
+		let bodyElm: JSX.Element;
+		if (this.state.secondary) {
+			bodyElm = this.secondary();
+		} else if (this.state.pending) {
+			bodyElm = this.pending();
+		} else {
+			bodyElm = this.form();
+		}
+
+		return <div className="bp3-card bp3-elevation-2" style={css.card}>
+			{bodyElm}
+		</div>;
+	}
+}
@@ -0,0 +1,58 @@
+/// <reference path="../References.d.ts"/>
+import Dispatcher from '../dispatcher/Dispatcher';
+import EventEmitter from '../EventEmitter';
+import * as ApprovalTypes from '../types/ApprovalTypes';
+import * as GlobalTypes from '../types/GlobalTypes';
+
+class ApprovalsStore extends EventEmitter {
+	_approvals: ApprovalTypes.ApprovalsRo = Object.freeze([]);
+	_map: {[key: string]: number} = {};
+	_token = Dispatcher.register((this._callback).bind(this));
+
+	get approvals(): ApprovalTypes.ApprovalsRo {
+		return this._approvals;
+	}
+
+	approval(id: string): ApprovalTypes.ApprovalRo {
+		let i = this._map[id];
+		if (i === undefined) {
+			return null;
+		}
+		return this._approvals[i];
+	}
+
+	emitChange(): void {
+		this.emitDefer(GlobalTypes.CHANGE);
+	}
+
+	addChangeListener(callback: () => void): void {
+		this.on(GlobalTypes.CHANGE, callback);
+	}
+
+	removeChangeListener(callback: () => void): void {
+		this.removeListener(GlobalTypes.CHANGE, callback);
+	}
+
+	_sync(approvals: ApprovalTypes.Approval[]): void {
+		approvals = approvals || [];
+
+		this._map = {};
+		for (let i = 0; i < approvals.length; i++) {
+			approvals[i] = Object.freeze(approvals[i]);
+			this._map[approvals[i].id] = i;
+		}
+
+		this._approvals = Object.freeze(approvals);
+		this.emitChange();
+	}
+
+	_callback(action: ApprovalTypes.ApprovalDispatch): void {
+		switch (action.type) {
+			case ApprovalTypes.SYNC:
+				this._sync(action.data.approvals);
+				break;
+		}
+	}
+}
+
+export default new ApprovalsStore();
@@ -0,0 +1,40 @@
+/// <reference path="../References.d.ts"/>
+export const SYNC = 'ssh_approval.sync';
+export const CHANGE = 'ssh_approval.change';
+
+export interface Agent {
+	operating_system?: string;
+	browser?: string;
+	ip?: string;
+	isp?: string;
+	country_code?: string;
+	region_code?: string;
+	city?: string;
+}
+
+export interface Approval {
+	id: string;
+	user_id?: string;
+	username?: string;
+	authority_ids?: string[];
+	pub_key?: string;
+	remote_addr?: string;
+	agent?: Agent;
+	state?: string;
+	timestamp?: string;
+	expires?: string;
+}
+
+export type Approvals = Approval[];
+
+export type ApprovalRo = Readonly<Approval>;
+export type ApprovalsRo = ReadonlyArray<ApprovalRo>;
+
+export interface ApprovalDispatch {
+	type: string;
+	data?: {
+		id?: string;
+		approval?: Approval;
+		approvals?: Approvals;
+	};
+}